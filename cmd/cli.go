@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/chackett/dating-service/datingservice"
+	"strings"
+	"time"
+)
+
+// runCommand executes an administrative subcommand against the dating service rather than starting the web server.
+//
+//	main set-role -email alice@example.com -role moderator
+//	main set-plan -email alice@example.com -plan premium -expires 2025-01-01T00:00:00Z
+//	main grant-boosts -email alice@example.com -credits 3
+//	main canonicalise-genders
+//	main coarsen-locations
+func runCommand(ctx context.Context, ds *datingservice.DateService, args []string) error {
+	if len(args) == 0 {
+		return errors.New("no command given")
+	}
+
+	switch args[0] {
+	case "set-role":
+		fs := flag.NewFlagSet("set-role", flag.ContinueOnError)
+		email := fs.String("email", "", "email address of the user to update")
+		role := fs.String("role", "", "role to assign: user, moderator, admin or service")
+		err := fs.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+		if *email == "" || *role == "" {
+			return errors.New("set-role requires -email and -role")
+		}
+
+		err = ds.SetUserRole(ctx, 0, *email, *role)
+		if err != nil {
+			return fmt.Errorf("set role: %w", err)
+		}
+		fmt.Printf("%s is now %s\n", *email, *role)
+		return nil
+	case "set-plan":
+		fs := flag.NewFlagSet("set-plan", flag.ContinueOnError)
+		email := fs.String("email", "", "email address of the user to update")
+		plan := fs.String("plan", "", "plan to assign: free or premium")
+		expires := fs.String("expires", "", "RFC 3339 time a premium plan lapses, or empty for never")
+		err := fs.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+		if *email == "" || *plan == "" {
+			return errors.New("set-plan requires -email and -plan")
+		}
+
+		var expiresAt *time.Time
+		if *expires != "" {
+			t, err := time.Parse(time.RFC3339, *expires)
+			if err != nil {
+				return fmt.Errorf("parse -expires: %w", err)
+			}
+			expiresAt = &t
+		}
+
+		err = ds.SetUserPlanByEmail(ctx, 0, *email, *plan, expiresAt)
+		if err != nil {
+			return fmt.Errorf("set plan: %w", err)
+		}
+		fmt.Printf("%s is now on %s\n", *email, *plan)
+		return nil
+	case "grant-boosts":
+		fs := flag.NewFlagSet("grant-boosts", flag.ContinueOnError)
+		email := fs.String("email", "", "email address of the user to credit")
+		credits := fs.Int("credits", 0, "number of boost credits to add")
+		err := fs.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+		if *email == "" || *credits < 1 {
+			return errors.New("grant-boosts requires -email and a positive -credits")
+		}
+
+		err = ds.GrantBoostCreditsByEmail(ctx, 0, *email, *credits)
+		if err != nil {
+			return fmt.Errorf("grant boosts: %w", err)
+		}
+		fmt.Printf("granted %d boost credits to %s\n", *credits, *email)
+		return nil
+	case "canonicalise-genders":
+		result, err := ds.CanonicaliseGenders(ctx)
+		if err != nil {
+			return fmt.Errorf("canonicalise genders: %w", err)
+		}
+		fmt.Printf("rewrote %d stored genders\n", result.Changed)
+		if len(result.Unknown) > 0 {
+			fmt.Printf("left %d not in the taxonomy: %s\n", len(result.Unknown), strings.Join(result.Unknown, ", "))
+		}
+		return nil
+	case "coarsen-locations":
+		changed, err := ds.CoarsenLocations(ctx)
+		if err != nil {
+			return fmt.Errorf("coarsen locations: %w", err)
+		}
+		fmt.Printf("coarsened the locations of %d users\n", changed)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
@@ -1,5 +1,7 @@
 package main
 
+import "time"
+
 // Config defines application configuration, to be populated via envars
 type Config struct {
 	// ServicePort defines the port the web service is to be exposed on
@@ -9,4 +11,118 @@ type Config struct {
 	DBHost      string `env:"DB_HOST"`
 	DBPort      int    `env:"DB_PORT"`
 	DBName      string `env:"DB_NAME"`
+
+	// PasswordHashAlgorithm is used for new password hashes, either "bcrypt" or "argon2id". Hashes made under another
+	// algorithm or weaker parameters are upgraded at the user's next login.
+	PasswordHashAlgorithm string `env:"PASSWORD_HASH_ALGORITHM" envDefault:"bcrypt"`
+	BcryptCost            int    `env:"BCRYPT_COST" envDefault:"12"`
+	Argon2MemoryKiB       int    `env:"ARGON2_MEMORY_KIB" envDefault:"65536"`
+	Argon2Iterations      int    `env:"ARGON2_ITERATIONS" envDefault:"3"`
+	Argon2Parallelism     int    `env:"ARGON2_PARALLELISM" envDefault:"2"`
+
+	// Login throttling: each failure doubles the wait before the next attempt, starting at LoginBackoffBase. After the
+	// max failures for an account or IP, it is locked out for LoginLockoutDuration.
+	LoginMaxAccountFailures int           `env:"LOGIN_MAX_ACCOUNT_FAILURES" envDefault:"5"`
+	LoginMaxIPFailures      int           `env:"LOGIN_MAX_IP_FAILURES" envDefault:"20"`
+	LoginBackoffBase        time.Duration `env:"LOGIN_BACKOFF_BASE" envDefault:"1s"`
+	LoginLockoutDuration    time.Duration `env:"LOGIN_LOCKOUT_DURATION" envDefault:"15m"`
+
+	// TOTPEncryptionKey is a base64 encoded 32 byte key used to encrypt two-factor secrets at rest. Two-factor
+	// authentication can't be enrolled without it.
+	TOTPEncryptionKey string `env:"TOTP_ENCRYPTION_KEY"`
+	// TOTPIssuer is the name shown against codes in authenticator apps.
+	TOTPIssuer string `env:"TOTP_ISSUER" envDefault:"Dating Service"`
+
+	// SessionMode is "database" for opaque tokens looked up in the sessions table, or "signed" for stateless access
+	// tokens verified without the DB.
+	SessionMode string `env:"SESSION_MODE" envDefault:"database"`
+	// AccessTokenFormat is "jwt" or "paseto", and AccessTokenAlgorithm "hs256" or "ed25519". PASETO only supports
+	// ed25519.
+	AccessTokenFormat    string `env:"ACCESS_TOKEN_FORMAT" envDefault:"jwt"`
+	AccessTokenAlgorithm string `env:"ACCESS_TOKEN_ALGORITHM" envDefault:"hs256"`
+	// AccessTokenKeys is the key ring, as comma separated "id:base64key" pairs. Keep retired keys listed until tokens
+	// they signed have expired.
+	AccessTokenKeys []string `env:"ACCESS_TOKEN_KEYS"`
+	// AccessTokenKeyID names the key in the ring new tokens are signed with.
+	AccessTokenKeyID string        `env:"ACCESS_TOKEN_KEY_ID"`
+	AccessTokenTTL   time.Duration `env:"ACCESS_TOKEN_TTL" envDefault:"15m"`
+	// TokenRevocationRefresh is how often the revocation denylist is reloaded from the DB.
+	TokenRevocationRefresh time.Duration `env:"TOKEN_REVOCATION_REFRESH" envDefault:"30s"`
+
+	// An external OIDC identity provider users can sign in with, enabled by setting OIDCIssuerURL. Users start at
+	// /auth/oidc/{OIDCProviderName}, and OIDCRedirectURL must point at /auth/oidc/{OIDCProviderName}/callback.
+	OIDCProviderName string   `env:"OIDC_PROVIDER_NAME" envDefault:"oidc"`
+	OIDCIssuerURL    string   `env:"OIDC_ISSUER_URL"`
+	OIDCClientID     string   `env:"OIDC_CLIENT_ID"`
+	OIDCClientSecret string   `env:"OIDC_CLIENT_SECRET"`
+	OIDCRedirectURL  string   `env:"OIDC_REDIRECT_URL"`
+	OIDCScopes       []string `env:"OIDC_SCOPES" envDefault:"openid,email,profile"`
+	// OIDCMockEnabled runs a built-in mock provider on OIDCMockPort, registered as the "mock" provider. It signs
+	// anyone in as any email, so is for development only.
+	OIDCMockEnabled bool `env:"OIDC_MOCK_ENABLED"`
+	OIDCMockPort    int  `env:"OIDC_MOCK_PORT" envDefault:"9096"`
+	// OIDCMockVerifiedEmails makes the mock provider claim the emails it signs in as are verified.
+	OIDCMockVerifiedEmails bool `env:"OIDC_MOCK_VERIFIED_EMAILS"`
+
+	// FreeDailyLikes is how many likes users without premium get per day, reset at midnight in their timezone.
+	FreeDailyLikes int `env:"FREE_DAILY_LIKES" envDefault:"20"`
+	// Super likes are limited per day on both plans.
+	FreeDailySuperLikes    int `env:"FREE_DAILY_SUPER_LIKES" envDefault:"1"`
+	PremiumDailySuperLikes int `env:"PREMIUM_DAILY_SUPER_LIKES" envDefault:"5"`
+	// FreeDailyUndos is how many swipes users without premium may undo per day, and UndoWindow how long after a swipe
+	// it can be undone.
+	FreeDailyUndos int           `env:"FREE_DAILY_UNDOS" envDefault:"1"`
+	UndoWindow     time.Duration `env:"UNDO_WINDOW" envDefault:"5m"`
+	// PassCooldown is how long until a passed candidate can appear in discover again. 0 keeps them out forever.
+	PassCooldown time.Duration `env:"PASS_COOLDOWN" envDefault:"720h"`
+	// BoostDuration is how long a boost lasts. Premium includes PremiumWeeklyBoosts boosts a week, beyond which boosts
+	// spend credits.
+	BoostDuration       time.Duration `env:"BOOST_DURATION" envDefault:"30m"`
+	PremiumWeeklyBoosts int           `env:"PREMIUM_WEEKLY_BOOSTS" envDefault:"1"`
+
+	// DistanceJitterKey is a base64 secret keying the jitter on distances shown to users. If unset, a random key is
+	// used, so jitter changes on every restart and differs between replicas.
+	DistanceJitterKey string `env:"DISTANCE_JITTER_KEY"`
+	// LocationUpdateInterval is the least time between a user's location changes. 0 disables the limit.
+	LocationUpdateInterval time.Duration `env:"LOCATION_UPDATE_INTERVAL" envDefault:"10m"`
+	// LocationStorage is "exact" to store coordinates as given, or "geohash" to store only a geohash of
+	// LocationGeohashPrecision characters.
+	LocationStorage          string `env:"LOCATION_STORAGE" envDefault:"exact"`
+	LocationGeohashPrecision int    `env:"LOCATION_GEOHASH_PRECISION" envDefault:"5"`
+	// CitiesFile is a GeoNames cities dump, such as cities15000.txt, to name cities from. If unset, a small bundled
+	// dataset of major cities is used.
+	CitiesFile string `env:"CITIES_FILE"`
+
+	// Genders are the genders users may choose from, stored as written here. GenderAliases are "alias:gender" pairs,
+	// letting other names map to one of them. Both are matched case-insensitively, so new genders need no code change.
+	// After changing either, run the canonicalise-genders command to bring stored values into line.
+	Genders       []string `env:"GENDERS" envDefault:"Woman,Man,Non-binary,Genderqueer,Genderfluid,Agender,Two-spirit"`
+	GenderAliases []string `env:"GENDER_ALIASES" envDefault:"female:Woman,male:Man,nonbinary:Non-binary,enby:Non-binary"`
+
+	// RateLimitStore is where rate limit buckets are kept: "memory" for a single replica, or "database" to share limits
+	// between replicas.
+	RateLimitStore string `env:"RATE_LIMIT_STORE" envDefault:"memory"`
+
+	// BlobStore selects where photos are kept, either "local" or "s3".
+	BlobStore string `env:"BLOB_STORE" envDefault:"local"`
+	// BlobLocalDir is the directory photos are written to when using the local store. They are served under /media.
+	BlobLocalDir string `env:"BLOB_LOCAL_DIR" envDefault:"./data/media"`
+	// BlobPublicURL is the public prefix photo URLs are built from. Defaults to this service's /media for the local
+	// store, and the bucket URL for S3.
+	BlobPublicURL string `env:"BLOB_PUBLIC_URL"`
+	S3Endpoint    string `env:"S3_ENDPOINT"`
+	S3Region      string `env:"S3_REGION"`
+	S3Bucket      string `env:"S3_BUCKET"`
+	S3AccessKey   string `env:"S3_ACCESS_KEY"`
+	S3SecretKey   string `env:"S3_SECRET_KEY"`
+
+	// Mailer selects how email is delivered: "log" writes it to stdout, "file" writes .eml files to MailDir and "smtp"
+	// sends it for real.
+	Mailer   string `env:"MAILER" envDefault:"log"`
+	MailFrom string `env:"MAIL_FROM" envDefault:"no-reply@dating-service.local"`
+	MailDir  string `env:"MAIL_DIR" envDefault:"./data/mail"`
+	SMTPHost string `env:"SMTP_HOST"`
+	SMTPPort int    `env:"SMTP_PORT" envDefault:"587"`
+	SMTPUser string `env:"SMTP_USER"`
+	SMTPPass string `env:"SMTP_PASS"`
 }
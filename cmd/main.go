@@ -1,12 +1,27 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
 	"github.com/caarlos0/env"
 	"github.com/chackett/dating-service/datingservice"
 	"github.com/chackett/dating-service/httpserver"
+	"github.com/chackett/dating-service/pkg/blobstore"
+	"github.com/chackett/dating-service/pkg/geocode"
+	"github.com/chackett/dating-service/pkg/mailer"
+	"github.com/chackett/dating-service/pkg/oidc"
+	"github.com/chackett/dating-service/pkg/oidcmock"
+	"github.com/chackett/dating-service/pkg/ratelimit"
+	"github.com/chackett/dating-service/pkg/security"
 	"github.com/chackett/dating-service/repository"
 	"log/slog"
+	"net/http"
 	"os"
+	"strings"
+	// Embedded so user timezones resolve even where the OS has no zone database, e.g. minimal container images.
+	_ "time/tzdata"
 )
 
 func main() {
@@ -15,30 +30,310 @@ func main() {
 	cfg := &Config{}
 	err := env.Parse(cfg)
 	if err != nil {
-		logger.Error("Error parsing env config variables: %w", err)
+		logger.Error("Error parsing env config variables", "err", err)
 		os.Exit(1)
 	}
 
 	repo, err := repository.New(cfg.DBUser, cfg.DBPass, cfg.DBHost, cfg.DBPort, cfg.DBName)
 	if err != nil {
-		logger.Error("unable to instantiate repository: %w", err)
+		logger.Error("unable to instantiate repository", "err", err)
 		os.Exit(1)
 	}
 
-	ds, err := datingservice.New(repo)
+	photoStore, mediaDir, err := newPhotoStore(cfg)
 	if err != nil {
-		logger.Error("unable to instantiate dating service: %w", err)
+		logger.Error("unable to instantiate photo store", "err", err)
 		os.Exit(1)
 	}
 
-	server, err := httpserver.New(cfg.ServicePort, ds)
+	m, err := newMailer(cfg)
 	if err != nil {
-		logger.Error("unable to instantiate http server: %w", err)
+		logger.Error("unable to instantiate mailer", "err", err)
+		os.Exit(1)
+	}
+
+	totpKey, err := base64.StdEncoding.DecodeString(cfg.TOTPEncryptionKey)
+	if err != nil {
+		logger.Error("unable to decode totp encryption key", "err", err)
+		os.Exit(1)
+	}
+
+	accessTokens, err := newAccessTokenCodec(cfg)
+	if err != nil {
+		logger.Error("unable to instantiate access token codec", "err", err)
+		os.Exit(1)
+	}
+
+	oidcProviders, err := newOIDCProviders(cfg, logger)
+	if err != nil {
+		logger.Error("unable to instantiate oidc providers", "err", err)
+		os.Exit(1)
+	}
+
+	jitterKey, err := newDistanceJitterKey(cfg, logger)
+	if err != nil {
+		logger.Error("unable to load distance jitter key", "err", err)
+		os.Exit(1)
+	}
+	coarsePrecision := 0
+	switch cfg.LocationStorage {
+	case "exact":
+	case "geohash":
+		coarsePrecision = cfg.LocationGeohashPrecision
+	default:
+		logger.Error("unknown location storage", "location_storage", cfg.LocationStorage)
+		os.Exit(1)
+	}
+
+	geocoder, err := newGeocoder(cfg)
+	if err != nil {
+		logger.Error("unable to load cities", "err", err)
+		os.Exit(1)
+	}
+
+	genderAliases, err := parseGenderAliases(cfg.GenderAliases)
+	if err != nil {
+		logger.Error("unable to parse gender aliases", "err", err)
+		os.Exit(1)
+	}
+
+	dsCfg := datingservice.Config{
+		PasswordHasher: security.PasswordHasher{
+			Algorithm:  cfg.PasswordHashAlgorithm,
+			BcryptCost: cfg.BcryptCost,
+			Argon2: security.Argon2Params{
+				MemoryKiB:   uint32(cfg.Argon2MemoryKiB),
+				Iterations:  uint32(cfg.Argon2Iterations),
+				Parallelism: uint8(cfg.Argon2Parallelism),
+			},
+		},
+		LoginThrottle: datingservice.LoginThrottleConfig{
+			MaxAccountFailures: cfg.LoginMaxAccountFailures,
+			MaxIPFailures:      cfg.LoginMaxIPFailures,
+			BackoffBase:        cfg.LoginBackoffBase,
+			LockoutDuration:    cfg.LoginLockoutDuration,
+		},
+		TOTPEncryptionKey: totpKey,
+		TOTPIssuer:        cfg.TOTPIssuer,
+		SessionMode:       cfg.SessionMode,
+		AccessTokens:      accessTokens,
+		AccessTokenTTL:    cfg.AccessTokenTTL,
+		OIDCProviders:     oidcProviders,
+		FreeDailyLikes:    cfg.FreeDailyLikes,
+
+		FreeDailySuperLikes:    cfg.FreeDailySuperLikes,
+		PremiumDailySuperLikes: cfg.PremiumDailySuperLikes,
+		FreeDailyUndos:         cfg.FreeDailyUndos,
+		UndoWindow:             cfg.UndoWindow,
+		PassCooldown:           cfg.PassCooldown,
+		BoostDuration:          cfg.BoostDuration,
+		PremiumWeeklyBoosts:    cfg.PremiumWeeklyBoosts,
+
+		DistanceJitterKey:       jitterKey,
+		LocationUpdateInterval:  cfg.LocationUpdateInterval,
+		CoarseLocationPrecision: coarsePrecision,
+		Geocoder:                geocoder,
+		Genders:                 cfg.Genders,
+		GenderAliases:           genderAliases,
+	}
+
+	ds, err := datingservice.New(repo, photoStore, m, dsCfg)
+	if err != nil {
+		logger.Error("unable to instantiate dating service", "err", err)
+		os.Exit(1)
+	}
+
+	// Any arguments are treated as an administrative command, which runs once instead of serving HTTP.
+	if len(os.Args) > 1 {
+		err = runCommand(context.Background(), ds, os.Args[1:])
+		if err != nil {
+			logger.Error("run command", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	go ds.RunTokenRevocationRefresh(context.Background(), cfg.TokenRevocationRefresh)
+
+	var limiter ratelimit.Store
+	switch cfg.RateLimitStore {
+	case "memory":
+		limiter = ratelimit.NewMemoryStore()
+	case "database":
+		limiter = repo.RateLimitStore()
+	default:
+		logger.Error("unknown rate limit store", "store", cfg.RateLimitStore)
+		os.Exit(1)
+	}
+
+	server, err := httpserver.New(cfg.ServicePort, ds, mediaDir, limiter)
+	if err != nil {
+		logger.Error("unable to instantiate http server", "err", err)
 		os.Exit(1)
 	}
 
 	err = server.Serve()
 	if err != nil {
-		logger.Error("start webserver", err)
+		logger.Error("start webserver", "err", err)
+	}
+}
+
+// newAccessTokenCodec creates the codec for signed access tokens from the configured key ring. It returns nil when the
+// signed session mode isn't in use.
+func newAccessTokenCodec(cfg *Config) (*security.AccessTokenCodec, error) {
+	if cfg.SessionMode != datingservice.SessionModeSigned {
+		return nil, nil
+	}
+
+	keys := make(map[string][]byte, len(cfg.AccessTokenKeys))
+	for _, entry := range cfg.AccessTokenKeys {
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("access token key %q should be in the form id:base64key", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("decode access token key %q: %w", id, err)
+		}
+		keys[id] = key
+	}
+
+	return security.NewAccessTokenCodec(cfg.AccessTokenFormat, cfg.AccessTokenAlgorithm, keys, cfg.AccessTokenKeyID)
+}
+
+// newDistanceJitterKey decodes the configured distance jitter key, or makes a random one if none is set.
+func newDistanceJitterKey(cfg *Config, logger *slog.Logger) ([]byte, error) {
+	if cfg.DistanceJitterKey != "" {
+		return base64.StdEncoding.DecodeString(cfg.DistanceJitterKey)
+	}
+	logger.Warn("no distance jitter key configured, using a random one which won't survive a restart")
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// newGeocoder loads the configured cities file, or the bundled cities if there is none.
+func newGeocoder(cfg *Config) (*geocode.Geocoder, error) {
+	if cfg.CitiesFile == "" {
+		return geocode.NewBundled()
+	}
+	f, err := os.Open(cfg.CitiesFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return geocode.Load(f)
+}
+
+// parseGenderAliases reads "alias:gender" pairs into a map of alias to gender.
+func parseGenderAliases(entries []string) (map[string]string, error) {
+	aliases := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		alias, gender, ok := strings.Cut(entry, ":")
+		if !ok || alias == "" || gender == "" {
+			return nil, fmt.Errorf("gender alias %q should be in the form alias:gender", entry)
+		}
+		aliases[alias] = gender
+	}
+	return aliases, nil
+}
+
+// newOIDCProviders creates the configured identity providers. If the mock provider is enabled, it is started here on
+// its own port.
+func newOIDCProviders(cfg *Config, logger *slog.Logger) ([]*oidc.Provider, error) {
+	var providers []*oidc.Provider
+
+	if cfg.OIDCIssuerURL != "" {
+		p, err := oidc.NewProvider(oidc.ProviderConfig{
+			Name:         cfg.OIDCProviderName,
+			IssuerURL:    cfg.OIDCIssuerURL,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       cfg.OIDCScopes,
+		}, nil)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+
+	if cfg.OIDCMockEnabled {
+		mock, err := oidcmock.New(fmt.Sprintf("http://localhost:%d", cfg.OIDCMockPort), cfg.OIDCMockVerifiedEmails)
+		if err != nil {
+			return nil, fmt.Errorf("create mock oidc provider: %w", err)
+		}
+		go func() {
+			logger.Warn("mock oidc provider enabled, anyone can sign in as any email", "issuer", mock.Issuer())
+			err := http.ListenAndServe(fmt.Sprintf(":%d", cfg.OIDCMockPort), mock)
+			if err != nil {
+				logger.Error("mock oidc provider stopped", "err", err)
+			}
+		}()
+
+		p, err := oidc.NewProvider(oidc.ProviderConfig{
+			Name:        "mock",
+			IssuerURL:   mock.Issuer(),
+			ClientID:    "dating-service",
+			RedirectURL: fmt.Sprintf("http://localhost:%d/auth/oidc/mock/callback", cfg.ServicePort),
+		}, nil)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+
+	return providers, nil
+}
+
+// newPhotoStore creates the blob store configured for photos. When photos are kept locally, the directory is returned
+// too, so the web server can serve them.
+func newPhotoStore(cfg *Config) (blobstore.Store, string, error) {
+	switch cfg.BlobStore {
+	case "local":
+		publicURL := cfg.BlobPublicURL
+		if publicURL == "" {
+			publicURL = fmt.Sprintf("http://localhost:%d/media", cfg.ServicePort)
+		}
+		store, err := blobstore.NewLocalStore(cfg.BlobLocalDir, publicURL)
+		if err != nil {
+			return nil, "", err
+		}
+		return store, store.Root(), nil
+	case "s3":
+		store, err := blobstore.NewS3Store(blobstore.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PublicURL: cfg.BlobPublicURL,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		return store, "", nil
+	default:
+		return nil, "", fmt.Errorf("unknown blob store %q", cfg.BlobStore)
+	}
+}
+
+// newMailer creates the configured mail sender.
+func newMailer(cfg *Config) (mailer.Mailer, error) {
+	switch cfg.Mailer {
+	case "log":
+		return mailer.NewLogMailer(), nil
+	case "file":
+		return mailer.NewFileMailer(cfg.MailDir, cfg.MailFrom)
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("SMTP_HOST is required for the smtp mailer")
+		}
+		return mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPass, cfg.MailFrom), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", cfg.Mailer)
 	}
 }
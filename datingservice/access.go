@@ -0,0 +1,68 @@
+package datingservice
+
+import (
+	"github.com/chackett/dating-service/repository"
+)
+
+// Permission is a capability that a route can require of the caller's session.
+type Permission string
+
+const (
+	// PermissionProfile covers the regular app experience: managing your own profile, discovering and swiping.
+	PermissionProfile Permission = "profile"
+	// PermissionReportsRead allows viewing the moderation queue and report details.
+	PermissionReportsRead Permission = "reports:read"
+	// PermissionReportsResolve allows warning, suspending or dismissing in response to reports.
+	PermissionReportsResolve Permission = "reports:resolve"
+	// PermissionUsersBan allows permanently banning an account.
+	PermissionUsersBan Permission = "users:ban"
+	// PermissionUsersManage allows changing roles and other account level settings.
+	PermissionUsersManage Permission = "users:manage"
+)
+
+// rolePermissions is the static ACL mapping each role onto the permissions it grants.
+var rolePermissions = map[string][]Permission{
+	repository.RoleUser: {
+		PermissionProfile,
+	},
+	repository.RoleModerator: {
+		PermissionProfile,
+		PermissionReportsRead,
+		PermissionReportsResolve,
+	},
+	repository.RoleAdmin: {
+		PermissionProfile,
+		PermissionReportsRead,
+		PermissionReportsResolve,
+		PermissionUsersBan,
+		PermissionUsersManage,
+	},
+	// Service accounts are used by internal tooling rather than people, so have no profile access.
+	repository.RoleService: {
+		PermissionReportsRead,
+	},
+}
+
+// IsValidRole reports whether role is one the ACL knows about.
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RoleHasPermissions reports whether role grants every one of the given permissions.
+func RoleHasPermissions(role string, perms ...Permission) bool {
+	granted := rolePermissions[role]
+	for _, p := range perms {
+		found := false
+		for _, g := range granted {
+			if g == p {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,193 @@
+package datingservice
+
+import (
+	"context"
+	"fmt"
+	"github.com/chackett/dating-service/pkg/security"
+	"github.com/chackett/dating-service/repository"
+	"sync"
+	"time"
+)
+
+const (
+	// SessionModeDatabase issues opaque tokens backed by a row in the sessions table, looked up on every request.
+	SessionModeDatabase = "database"
+	// SessionModeSigned issues signed access tokens carrying the user's ID and role, verified without touching the DB.
+	SessionModeSigned = "signed"
+)
+
+// tokenDenylist is an in-memory copy of the active token revocations, so signed tokens can be checked against it
+// without a DB round-trip. It is refreshed from the DB periodically to pick up revocations made by other instances.
+type tokenDenylist struct {
+	mu sync.RWMutex
+	// tokens maps revoked token IDs to when they expire.
+	tokens map[string]time.Time
+	// users maps user IDs to the time before which all their tokens are revoked.
+	users map[int]time.Time
+}
+
+func newTokenDenylist() *tokenDenylist {
+	return &tokenDenylist{tokens: map[string]time.Time{}, users: map[int]time.Time{}}
+}
+
+func (d *tokenDenylist) add(r repository.TokenRevocation) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if r.TokenID != nil {
+		d.tokens[*r.TokenID] = r.ExpiresAt
+	}
+	if r.UserID != nil && r.RevokedAt.After(d.users[*r.UserID]) {
+		d.users[*r.UserID] = r.RevokedAt
+	}
+}
+
+func (d *tokenDenylist) replace(revocations []repository.TokenRevocation) {
+	fresh := newTokenDenylist()
+	for _, r := range revocations {
+		fresh.add(r)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.tokens = fresh.tokens
+	d.users = fresh.users
+}
+
+func (d *tokenDenylist) isRevoked(claims security.AccessTokenClaims) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if _, ok := d.tokens[claims.ID]; ok {
+		return true
+	}
+	revokedAt, ok := d.users[claims.UserID]
+	return ok && !claims.IssuedAt.After(revokedAt)
+}
+
+// issueAccessToken signs a new access token for a user who has fully authenticated.
+func (s *DateService) issueAccessToken(user repository.User) (string, error) {
+	id, err := security.CreateSecureSessionToken(16)
+	if err != nil {
+		return "", fmt.Errorf("create token id: %w", err)
+	}
+
+	now := time.Now()
+	token, err := s.cfg.AccessTokens.Sign(security.AccessTokenClaims{
+		ID:        id,
+		UserID:    user.ID,
+		Role:      user.Role,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(s.cfg.AccessTokenTTL),
+	})
+	if err != nil {
+		return "", fmt.Errorf("sign access token: %w", err)
+	}
+	return token, nil
+}
+
+// authenticateAccessToken verifies a signed access token. Account standing isn't checked here, as that would need the
+// DB; instead suspending or banning a user revokes their tokens.
+func (s *DateService) authenticateAccessToken(token string) (SessionIdentity, error) {
+	claims, err := s.cfg.AccessTokens.Verify(token, time.Now())
+	if err != nil {
+		return SessionIdentity{}, fmt.Errorf("verify access token: %w", err)
+	}
+	if s.revocations.isRevoked(claims) {
+		return SessionIdentity{}, fmt.Errorf("verify access token: %w", security.ErrInvalidAccessToken)
+	}
+	return SessionIdentity{UserID: claims.UserID, Role: claims.Role, TokenID: claims.ID}, nil
+}
+
+// Logout ends the session a request was made with.
+func (s *DateService) Logout(ctx context.Context, session SessionIdentity) error {
+	if s.cfg.SessionMode != SessionModeSigned {
+		err := s.repo.DeleteSession(ctx, session.SessionID)
+		if err != nil {
+			return fmt.Errorf("delete session in repo: %w", err)
+		}
+		return nil
+	}
+
+	now := time.Now()
+	revocation := repository.TokenRevocation{
+		TokenID:   &session.TokenID,
+		RevokedAt: now,
+		// The token can't outlive its TTL, so nor does its revocation.
+		ExpiresAt: now.Add(s.cfg.AccessTokenTTL),
+	}
+	err := s.repo.CreateTokenRevocation(ctx, &revocation)
+	if err != nil {
+		return fmt.Errorf("store token revocation: %w", err)
+	}
+	s.revocations.add(revocation)
+	return nil
+}
+
+// revokeUserSessions ends all of a user's sessions, whichever session mode is in use.
+func (s *DateService) revokeUserSessions(ctx context.Context, userID int) error {
+	err := s.repo.RevokeUserSessions(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("revoke sessions: %w", err)
+	}
+	if s.cfg.SessionMode != SessionModeSigned {
+		return nil
+	}
+
+	revocation := s.userTokenRevocation(userID)
+	err = s.repo.CreateTokenRevocation(ctx, revocation)
+	if err != nil {
+		return fmt.Errorf("store token revocation: %w", err)
+	}
+	s.revocations.add(*revocation)
+	return nil
+}
+
+// userTokenRevocation returns the revocation denying all of a user's signed access tokens, or nil outside the signed
+// session mode, where ending sessions is enough.
+func (s *DateService) userTokenRevocation(userID int) *repository.TokenRevocation {
+	if s.cfg.SessionMode != SessionModeSigned {
+		return nil
+	}
+	now := time.Now()
+	return &repository.TokenRevocation{
+		UserID:    &userID,
+		RevokedAt: now,
+		ExpiresAt: now.Add(s.cfg.AccessTokenTTL),
+	}
+}
+
+// RefreshTokenRevocations reloads the denylist from the DB, and prunes revocations that are no longer needed.
+func (s *DateService) RefreshTokenRevocations(ctx context.Context) error {
+	err := s.repo.DeleteExpiredTokenRevocations(ctx)
+	if err != nil {
+		return err
+	}
+	revocations, err := s.repo.GetActiveTokenRevocations(ctx)
+	if err != nil {
+		return err
+	}
+	s.revocations.replace(revocations)
+	return nil
+}
+
+// RunTokenRevocationRefresh refreshes the denylist every interval until ctx is cancelled. It only has work to do in
+// the signed session mode.
+func (s *DateService) RunTokenRevocationRefresh(ctx context.Context, interval time.Duration) {
+	if s.cfg.SessionMode != SessionModeSigned {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := s.RefreshTokenRevocations(ctx)
+		if err != nil {
+			s.logger.Error("refresh token revocations", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package datingservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chackett/dating-service/repository"
+	"math"
+	"time"
+)
+
+// maxBoostHistory is how many past boosts GetBoosts returns.
+const maxBoostHistory = 20
+
+var (
+	ErrBoostActive      = errors.New("a boost is already active")
+	ErrNoBoostsLeft     = errors.New("no boosts available")
+	ErrInvalidBoostGift = errors.New("boost credits granted must be positive")
+)
+
+// BoostStats is a boost along with what it achieved. Extra impressions and likes are those above the boost's baseline,
+// and are only final once the boost has ended.
+type BoostStats struct {
+	repository.Boost
+	Active           bool `json:"active"`
+	Likes            int  `json:"likes"`
+	ExtraImpressions int  `json:"extraImpressions"`
+	ExtraLikes       int  `json:"extraLikes"`
+}
+
+// StartBoost starts a boost for the user, ranking them higher in other users' discover results for BoostDuration.
+// Premium users get PremiumWeeklyBoosts included, after which, as for everyone else, each boost spends a boost credit.
+func (s *DateService) StartBoost(ctx context.Context, userID int) (repository.Boost, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return repository.Boost{}, fmt.Errorf("get user from repo: %w", err)
+	}
+
+	premiumWeekly := 0
+	if user.HasPremium(time.Now()) {
+		premiumWeekly = s.cfg.PremiumWeeklyBoosts
+	}
+
+	boost, err := s.repo.StartBoost(ctx, userID, s.cfg.BoostDuration, premiumWeekly)
+	if errors.Is(err, repository.ErrBoostActive) {
+		return repository.Boost{}, ErrBoostActive
+	}
+	if errors.Is(err, repository.ErrNoBoostCredits) {
+		return repository.Boost{}, ErrNoBoostsLeft
+	}
+	if err != nil {
+		return repository.Boost{}, err
+	}
+	return boost, nil
+}
+
+// GetBoosts returns the user's recent boosts, newest first, with how many impressions and likes each produced beyond
+// what the user would have expected without it.
+func (s *DateService) GetBoosts(ctx context.Context, userID int) ([]BoostStats, error) {
+	boosts, err := s.repo.GetBoosts(ctx, userID, maxBoostHistory)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := make([]BoostStats, 0, len(boosts))
+	for _, boost := range boosts {
+		likes, err := s.repo.CountLikesReceivedBetween(ctx, userID, boost.StartsAt, boost.EndsAt)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, BoostStats{
+			Boost:            boost,
+			Active:           boost.EndsAt.After(now),
+			Likes:            likes,
+			ExtraImpressions: max(int(math.Round(float64(boost.Impressions)-boost.BaselineImpressions)), 0),
+			ExtraLikes:       max(int(math.Round(float64(likes)-boost.BaselineLikes)), 0),
+		})
+	}
+	return result, nil
+}
+
+// GrantBoostCredits adds boost credits to a user's balance, such as once they have bought them.
+func (s *DateService) GrantBoostCredits(ctx context.Context, actorID int, userID int, credits int) error {
+	if credits < 1 {
+		return ErrInvalidBoostGift
+	}
+
+	_, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user from repo: %w", err)
+	}
+
+	err = s.repo.AddBoostCredits(ctx, userID, credits)
+	if err != nil {
+		return err
+	}
+	return s.audit(ctx, actorID, "grant_boosts", &userID, nil, fmt.Sprintf("%d credits", credits))
+}
+
+// GrantBoostCreditsByEmail is GrantBoostCredits for the CLI, which identifies users by email.
+func (s *DateService) GrantBoostCreditsByEmail(ctx context.Context, actorID int, email string, credits int) error {
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("find user: %w", err)
+	}
+	return s.GrantBoostCredits(ctx, actorID, user.ID, credits)
+}
@@ -0,0 +1,164 @@
+package datingservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chackett/dating-service/repository"
+	"time"
+)
+
+var (
+	ErrLikeQuotaExceeded = errors.New("daily like quota used up")
+	// ErrSuperLikeQuotaExceeded is a kind of ErrLikeQuotaExceeded, so checking for the latter catches both.
+	ErrSuperLikeQuotaExceeded = errors.New("daily super like quota used up")
+	ErrInvalidPlan            = errors.New("invalid plan")
+)
+
+// LikeQuotaError is returned when a user has no likes, or super likes, left today. ResetsAt is the start of their next
+// day.
+type LikeQuotaError struct {
+	ResetsAt  time.Time
+	SuperLike bool
+}
+
+func (e *LikeQuotaError) Error() string {
+	return fmt.Sprintf("%s, resets at %s", e.sentinel(), e.ResetsAt.Format(time.RFC3339))
+}
+
+func (e *LikeQuotaError) Is(target error) bool {
+	return target == ErrLikeQuotaExceeded || target == e.sentinel()
+}
+
+func (e *LikeQuotaError) sentinel() error {
+	if e.SuperLike {
+		return ErrSuperLikeQuotaExceeded
+	}
+	return ErrLikeQuotaExceeded
+}
+
+// Entitlements describes what a user's plan allows them.
+type Entitlements struct {
+	Plan          string     `json:"plan"`
+	PlanExpiresAt *time.Time `json:"planExpiresAt,omitempty"`
+	// UnlimitedLikes is set for premium users, who have no daily quota. The quota fields are omitted for them.
+	UnlimitedLikes bool `json:"unlimitedLikes"`
+	DailyLikes     int  `json:"dailyLikes,omitempty"`
+	LikesRemaining *int `json:"likesRemaining,omitempty"`
+	// Super likes are limited on every plan.
+	DailySuperLikes     int `json:"dailySuperLikes"`
+	SuperLikesRemaining int `json:"superLikesRemaining"`
+	// DailyUndos and UndosRemaining are omitted for premium users, who may undo without limit.
+	DailyUndos     int       `json:"dailyUndos,omitempty"`
+	UndosRemaining *int      `json:"undosRemaining,omitempty"`
+	QuotaResetsAt  time.Time `json:"quotaResetsAt"`
+	Timezone       string    `json:"timezone"`
+	// BoostCredits is how many purchased boosts the user has left.
+	BoostCredits int `json:"boostCredits"`
+}
+
+// GetEntitlements returns the user's plan and how much of today's quota they have left.
+func (s *DateService) GetEntitlements(ctx context.Context, userID int) (Entitlements, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return Entitlements{}, fmt.Errorf("get user from repo: %w", err)
+	}
+
+	now := time.Now()
+	dayStart, dayEnd := user.LocalDay(now)
+	result := Entitlements{
+		Plan:            repository.PlanFree,
+		PlanExpiresAt:   user.PlanExpiresAt,
+		DailySuperLikes: s.cfg.FreeDailySuperLikes,
+		QuotaResetsAt:   dayEnd,
+		BoostCredits:    user.BoostCredits,
+		Timezone:        user.Timezone,
+	}
+
+	superUsed, err := s.repo.CountLikesSince(ctx, userID, dayStart, true)
+	if err != nil {
+		return Entitlements{}, err
+	}
+
+	if user.HasPremium(now) {
+		result.Plan = repository.PlanPremium
+		result.UnlimitedLikes = true
+		result.DailySuperLikes = s.cfg.PremiumDailySuperLikes
+		result.SuperLikesRemaining = max(result.DailySuperLikes-superUsed, 0)
+		return result, nil
+	}
+	// A lapsed premium plan's expiry is no longer of interest.
+	result.PlanExpiresAt = nil
+	result.SuperLikesRemaining = max(result.DailySuperLikes-superUsed, 0)
+
+	used, err := s.repo.CountLikesSince(ctx, userID, dayStart, false)
+	if err != nil {
+		return Entitlements{}, err
+	}
+	remaining := max(s.cfg.FreeDailyLikes-used, 0)
+	result.DailyLikes = s.cfg.FreeDailyLikes
+	result.LikesRemaining = &remaining
+
+	undosUsed, err := s.repo.CountUndosSince(ctx, userID, dayStart)
+	if err != nil {
+		return Entitlements{}, err
+	}
+	undosRemaining := max(s.cfg.FreeDailyUndos-undosUsed, 0)
+	result.DailyUndos = s.cfg.FreeDailyUndos
+	result.UndosRemaining = &undosRemaining
+	return result, nil
+}
+
+// swipeLimit returns the daily quota that applies to a swipe, or false if it is unlimited.
+func (s *DateService) swipeLimit(user repository.User, swipe repository.Swipe, now time.Time) (int, bool) {
+	premium := user.HasPremium(now)
+	switch {
+	case swipe.SuperLike && premium:
+		return s.cfg.PremiumDailySuperLikes, true
+	case swipe.SuperLike:
+		return s.cfg.FreeDailySuperLikes, true
+	case swipe.Likes && !premium:
+		return s.cfg.FreeDailyLikes, true
+	default:
+		return 0, false
+	}
+}
+
+// SetUserPlan grants or removes a plan. expiresAt is only used for premium, where nil means it never expires.
+func (s *DateService) SetUserPlan(ctx context.Context, actorID int, userID int, plan string, expiresAt *time.Time) error {
+	switch plan {
+	case repository.PlanFree:
+		expiresAt = nil
+	case repository.PlanPremium:
+		if expiresAt != nil && !expiresAt.After(time.Now()) {
+			return fmt.Errorf("%w: expiry must be in the future", ErrInvalidPlan)
+		}
+	default:
+		return fmt.Errorf("%w: unknown plan %q", ErrInvalidPlan, plan)
+	}
+
+	_, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user from repo: %w", err)
+	}
+
+	err = s.repo.SetUserPlan(ctx, userID, plan, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	detail := plan
+	if expiresAt != nil {
+		detail = fmt.Sprintf("%s until %s", plan, expiresAt.Format(time.RFC3339))
+	}
+	return s.audit(ctx, actorID, "set_plan", &userID, nil, detail)
+}
+
+// SetUserPlanByEmail is SetUserPlan for the CLI, which identifies users by email.
+func (s *DateService) SetUserPlanByEmail(ctx context.Context, actorID int, email string, plan string, expiresAt *time.Time) error {
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("find user: %w", err)
+	}
+	return s.SetUserPlan(ctx, actorID, user.ID, plan, expiresAt)
+}
@@ -0,0 +1,113 @@
+package datingservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// genderTaxonomy is the set of genders users may choose from. Values are matched case-insensitively, against either
+// a gender or one of its aliases, and always stored in their canonical form.
+type genderTaxonomy struct {
+	genders []string
+	lookup  map[string]string
+}
+
+// newGenderTaxonomy builds a taxonomy from the canonical genders and a map of aliases to them.
+func newGenderTaxonomy(genders []string, aliases map[string]string) (*genderTaxonomy, error) {
+	if len(genders) == 0 {
+		return nil, errors.New("at least one gender is needed")
+	}
+
+	t := &genderTaxonomy{
+		genders: make([]string, 0, len(genders)),
+		lookup:  make(map[string]string, len(genders)+len(aliases)),
+	}
+	for _, g := range genders {
+		g = strings.TrimSpace(g)
+		if g == "" || len(g) > maxGenderLength {
+			return nil, fmt.Errorf("gender %q must be 1-%d characters", g, maxGenderLength)
+		}
+		key := strings.ToLower(g)
+		if _, dup := t.lookup[key]; dup {
+			return nil, fmt.Errorf("duplicate gender %q", g)
+		}
+		t.lookup[key] = g
+		t.genders = append(t.genders, g)
+	}
+	for alias, g := range aliases {
+		canonical, ok := t.lookup[strings.ToLower(strings.TrimSpace(g))]
+		if !ok {
+			return nil, fmt.Errorf("alias %q is for unknown gender %q", alias, g)
+		}
+		key := strings.ToLower(strings.TrimSpace(alias))
+		if _, dup := t.lookup[key]; dup {
+			return nil, fmt.Errorf("alias %q clashes with another gender or alias", alias)
+		}
+		t.lookup[key] = canonical
+	}
+	return t, nil
+}
+
+// canonical returns the canonical form of a gender, and false if it isn't in the taxonomy.
+func (t *genderTaxonomy) canonical(gender string) (string, bool) {
+	g, ok := t.lookup[strings.ToLower(strings.TrimSpace(gender))]
+	return g, ok
+}
+
+// canonicalList returns the canonical forms of a list of genders, without duplicates.
+func (t *genderTaxonomy) canonicalList(genders []string) ([]string, error) {
+	result := make([]string, 0, len(genders))
+	for _, g := range genders {
+		canonical, ok := t.canonical(g)
+		if !ok {
+			return nil, fmt.Errorf("unknown gender %q", g)
+		}
+		if !slices.Contains(result, canonical) {
+			result = append(result, canonical)
+		}
+	}
+	return result, nil
+}
+
+// GetGenders returns the genders users may choose from, in their canonical form.
+func (s *DateService) GetGenders() []string {
+	return s.genders.genders
+}
+
+// CanonicalisedGenders reports what CanonicaliseGenders did.
+type CanonicalisedGenders struct {
+	// Changed is how many stored values, on users and in preferences, were rewritten.
+	Changed int
+	// Unknown lists stored values which aren't in the taxonomy, and so were left alone.
+	Unknown []string
+}
+
+// CanonicaliseGenders rewrites stored genders into their canonical form under the configured taxonomy, mapping
+// aliases and fixing case. It is run after changing GENDERS or GENDER_ALIASES, and is safe to run again.
+func (s *DateService) CanonicaliseGenders(ctx context.Context) (CanonicalisedGenders, error) {
+	stored, err := s.repo.GetStoredGenders(ctx)
+	if err != nil {
+		return CanonicalisedGenders{}, err
+	}
+
+	result := CanonicalisedGenders{Unknown: []string{}}
+	for _, g := range stored {
+		canonical, ok := s.genders.canonical(g)
+		if !ok {
+			result.Unknown = append(result.Unknown, g)
+			continue
+		}
+		if canonical == g {
+			continue
+		}
+		changed, err := s.repo.RenameGender(ctx, g, canonical)
+		if err != nil {
+			return result, err
+		}
+		result.Changed += changed
+	}
+	return result, nil
+}
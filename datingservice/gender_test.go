@@ -0,0 +1,66 @@
+package datingservice
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGenderTaxonomy(t *testing.T) {
+	taxonomy, err := newGenderTaxonomy([]string{"Woman", "Man", "Non-binary"},
+		map[string]string{"female": "Woman", "Male": "man", "enby": "Non-binary"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		gender string
+		want   string
+		ok     bool
+	}{
+		{gender: "Woman", want: "Woman", ok: true},
+		{gender: "woman", want: "Woman", ok: true},
+		{gender: " MAN ", want: "Man", ok: true},
+		{gender: "Female", want: "Woman", ok: true},
+		{gender: "male", want: "Man", ok: true},
+		{gender: "enby", want: "Non-binary", ok: true},
+		{gender: "Agender"},
+		{gender: ""},
+	}
+	for _, tt := range tests {
+		got, ok := taxonomy.canonical(tt.gender)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("canonical(%q) = %q, %v, want %q, %v", tt.gender, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	list, err := taxonomy.canonicalList([]string{"female", "Woman", "enby"})
+	if err != nil || !slices.Equal(list, []string{"Woman", "Non-binary"}) {
+		t.Errorf("canonicalList() = %q, %v, want [Woman Non-binary] without duplicates", list, err)
+	}
+	_, err = taxonomy.canonicalList([]string{"Woman", "Agender"})
+	if err == nil {
+		t.Error("canonicalList() accepted an unknown gender")
+	}
+}
+
+func TestNewGenderTaxonomyErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		genders []string
+		aliases map[string]string
+	}{
+		{name: "no genders"},
+		{name: "empty gender", genders: []string{"Woman", " "}},
+		{name: "duplicate ignoring case", genders: []string{"Woman", "woman"}},
+		{name: "alias for unknown gender", genders: []string{"Woman"}, aliases: map[string]string{"male": "Man"}},
+		{name: "alias clashes with gender", genders: []string{"Woman", "Man"}, aliases: map[string]string{"man": "Woman"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newGenderTaxonomy(tt.genders, tt.aliases)
+			if err == nil {
+				t.Error("newGenderTaxonomy() succeeded, want an error")
+			}
+		})
+	}
+}
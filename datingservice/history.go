@@ -0,0 +1,85 @@
+package datingservice
+
+import (
+	"context"
+	"github.com/chackett/dating-service/repository"
+	"time"
+)
+
+const (
+	// defaultSwipeHistoryPage and maxSwipeHistoryPage bound the page size of GetSwipeHistory.
+	defaultSwipeHistoryPage = 50
+	maxSwipeHistoryPage     = 100
+)
+
+// SwipeHistoryEntry is one of a user's own swipes. Expired is set on passes past the cooldown whose candidate has
+// changed their profile since, and so may be shown again.
+type SwipeHistoryEntry struct {
+	repository.Swipe
+	Expired bool `json:"expired,omitempty"`
+}
+
+// SwipeHistory is a page of a user's swipes.
+type SwipeHistory struct {
+	Results []SwipeHistoryEntry `json:"results"`
+	// NextCursor is passed back to fetch the next page, and is left out on the last one.
+	NextCursor int `json:"nextCursor,omitempty"`
+}
+
+// GetSwipeHistory returns the user's likes and passes, newest first. cursor is the NextCursor of the previous page, or 0
+// for the first.
+func (s *DateService) GetSwipeHistory(ctx context.Context, userID int, cursor int, limit int) (SwipeHistory, error) {
+	if limit <= 0 {
+		limit = defaultSwipeHistoryPage
+	}
+	limit = min(limit, maxSwipeHistoryPage)
+
+	// One extra is fetched to learn whether there is another page.
+	swipes, err := s.repo.GetSwipesByUser(ctx, userID, cursor, limit+1)
+	if err != nil {
+		return SwipeHistory{}, err
+	}
+	result := SwipeHistory{Results: make([]SwipeHistoryEntry, 0, len(swipes))}
+	if len(swipes) > limit {
+		swipes = swipes[:limit]
+		result.NextCursor = swipes[limit-1].ID
+	}
+
+	candidateIDs := make([]int, 0, len(swipes))
+	for _, swipe := range swipes {
+		candidateIDs = append(candidateIDs, swipe.CandidateID)
+	}
+	candidates, err := s.repo.GetUsersByID(ctx, candidateIDs)
+	if err != nil {
+		return SwipeHistory{}, err
+	}
+	profileUpdatedAt := make(map[int]*time.Time, len(candidates))
+	for _, c := range candidates {
+		profileUpdatedAt[c.ID] = c.ProfileUpdatedAt
+	}
+
+	passesExpireBefore := s.passesExpireBefore(time.Now())
+	for _, swipe := range swipes {
+		result.Results = append(result.Results, SwipeHistoryEntry{
+			Swipe:   swipe,
+			Expired: passExpired(swipe, profileUpdatedAt[swipe.CandidateID], passesExpireBefore),
+		})
+	}
+	return result, nil
+}
+
+// passExpired reports whether a pass no longer keeps its candidate out of discover. That is once the cooldown is over,
+// and then only if the candidate has changed their profile since the pass. It mirrors the repository's query.
+func passExpired(swipe repository.Swipe, profileUpdatedAt *time.Time, passesExpireBefore time.Time) bool {
+	return !swipe.Likes && swipe.CreatedAt.Before(passesExpireBefore) &&
+		profileUpdatedAt != nil && profileUpdatedAt.After(swipe.CreatedAt)
+}
+
+// passesExpireBefore returns the time before which passes have expired. With no cooldown it is the zero time, which no
+// pass is made before.
+func (s *DateService) passesExpireBefore(now time.Time) time.Time {
+	if s.cfg.PassCooldown == 0 {
+		return time.Time{}
+	}
+	return now.Add(-s.cfg.PassCooldown)
+}
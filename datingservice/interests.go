@@ -0,0 +1,135 @@
+package datingservice
+
+import (
+	"context"
+	"fmt"
+	"github.com/chackett/dating-service/repository"
+)
+
+const (
+	maxUserInterests     = 10
+	maxUserPromptAnswers = 3
+	maxPromptAnswerSize  = 300
+)
+
+// GetInterestCatalogue returns every interest a user may tag their profile with.
+func (s *DateService) GetInterestCatalogue(ctx context.Context) ([]repository.Interest, error) {
+	interests, err := s.repo.GetInterestCatalogue(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get interest catalogue from repo: %w", err)
+	}
+	return interests, nil
+}
+
+// GetPrompts returns the profile prompts currently on offer.
+func (s *DateService) GetPrompts(ctx context.Context) ([]repository.Prompt, error) {
+	prompts, err := s.repo.GetActivePrompts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get prompts from repo: %w", err)
+	}
+	return prompts, nil
+}
+
+// SetUserInterests replaces a user's interests. Every interest must come from the catalogue.
+func (s *DateService) SetUserInterests(ctx context.Context, userID int, interestIDs []int) error {
+	ids := make([]int, 0, len(interestIDs))
+	seen := map[int]bool{}
+	for _, id := range interestIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) > maxUserInterests {
+		return fmt.Errorf("%w: at most %d interests may be chosen", ErrInvalidProfile, maxUserInterests)
+	}
+
+	if len(ids) > 0 {
+		count, err := s.repo.CountInterests(ctx, ids)
+		if err != nil {
+			return fmt.Errorf("validate interests: %w", err)
+		}
+		if count != len(ids) {
+			return fmt.Errorf("%w: unknown interest", ErrInvalidProfile)
+		}
+	}
+
+	err := s.repo.SetUserInterests(ctx, userID, ids)
+	if err != nil {
+		return fmt.Errorf("set user interests in repo: %w", err)
+	}
+	return nil
+}
+
+// SetUserPromptAnswers replaces a user's prompt answers. They're displayed in the order given.
+func (s *DateService) SetUserPromptAnswers(ctx context.Context, userID int, answers []repository.PromptAnswer) error {
+	if len(answers) > maxUserPromptAnswers {
+		return fmt.Errorf("%w: at most %d prompts may be answered", ErrInvalidProfile, maxUserPromptAnswers)
+	}
+
+	ids := make([]int, 0, len(answers))
+	seen := map[int]bool{}
+	for _, a := range answers {
+		if seen[a.PromptID] {
+			return fmt.Errorf("%w: each prompt may only be answered once", ErrInvalidProfile)
+		}
+		seen[a.PromptID] = true
+		ids = append(ids, a.PromptID)
+
+		if a.Answer == "" || len(a.Answer) > maxPromptAnswerSize {
+			return fmt.Errorf("%w: answers must be 1-%d characters", ErrInvalidProfile, maxPromptAnswerSize)
+		}
+	}
+
+	if len(ids) > 0 {
+		count, err := s.repo.CountActivePrompts(ctx, ids)
+		if err != nil {
+			return fmt.Errorf("validate prompts: %w", err)
+		}
+		if count != len(ids) {
+			return fmt.Errorf("%w: unknown prompt", ErrInvalidProfile)
+		}
+	}
+
+	err := s.repo.SetUserPromptAnswers(ctx, userID, answers)
+	if err != nil {
+		return fmt.Errorf("set user prompt answers in repo: %w", err)
+	}
+	return nil
+}
+
+// attachProfileDetails loads interests, prompt answers and photos onto each of the given users in bulk.
+func (s *DateService) attachProfileDetails(ctx context.Context, users []repository.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	ids := make([]int, 0, len(users))
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+
+	interests, err := s.repo.GetInterestsForUsers(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("get interests from repo: %w", err)
+	}
+	prompts, err := s.repo.GetPromptAnswersForUsers(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("get prompt answers from repo: %w", err)
+	}
+	photos, err := s.repo.GetPhotosForUsers(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("get photos from repo: %w", err)
+	}
+
+	for i := range users {
+		users[i].Interests = interests[users[i].ID]
+		users[i].Prompts = prompts[users[i].ID]
+		users[i].Photos = photos[users[i].ID]
+		for j := range users[i].Photos {
+			s.resolvePhotoURLs(&users[i].Photos[j])
+		}
+	}
+	return nil
+}
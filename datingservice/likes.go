@@ -0,0 +1,95 @@
+package datingservice
+
+import (
+	"context"
+	"fmt"
+	"github.com/chackett/dating-service/repository"
+	"time"
+)
+
+const (
+	// defaultReceivedLikesPage and maxReceivedLikesPage bound the page size of GetReceivedLikes.
+	defaultReceivedLikesPage = 20
+	maxReceivedLikesPage     = 50
+)
+
+// ReceivedLike is a like from a user the recipient hasn't swiped on yet.
+type ReceivedLike struct {
+	User      repository.User `json:"user"`
+	SuperLike bool            `json:"superLike,omitempty"`
+	LikedAt   time.Time       `json:"likedAt"`
+}
+
+// ReceivedLikes is a page of the likes a user has received. Users without premium only get the count, with Blurred set.
+type ReceivedLikes struct {
+	Count   int            `json:"count"`
+	Blurred bool           `json:"blurred"`
+	Results []ReceivedLike `json:"results"`
+	// NextCursor is passed back to fetch the next page, and is left out on the last one.
+	NextCursor int `json:"nextCursor,omitempty"`
+}
+
+// GetReceivedLikes lists who has liked the user, among those the user hasn't swiped on or whose pass has expired, newest
+// first. Likes from users whose gender settings and the user's don't suit each other are left out. cursor is the
+// NextCursor of the previous page, or 0 for the first. Profiles are only shown to premium users, with private fields
+// masked.
+func (s *DateService) GetReceivedLikes(ctx context.Context, userID int, cursor int, limit int) (ReceivedLikes, error) {
+	if limit <= 0 {
+		limit = defaultReceivedLikesPage
+	}
+	limit = min(limit, maxReceivedLikesPage)
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return ReceivedLikes{}, fmt.Errorf("get user from repo: %w", err)
+	}
+
+	now := time.Now()
+	passesExpireBefore := s.passesExpireBefore(now)
+	count, err := s.repo.CountReceivedLikes(ctx, userID, passesExpireBefore)
+	if err != nil {
+		return ReceivedLikes{}, err
+	}
+	result := ReceivedLikes{Count: count, Results: []ReceivedLike{}}
+	if !user.HasPremium(now) {
+		result.Blurred = true
+		return result, nil
+	}
+
+	// One extra is fetched to learn whether there is another page.
+	likes, err := s.repo.GetReceivedLikes(ctx, userID, passesExpireBefore, cursor, limit+1)
+	if err != nil {
+		return ReceivedLikes{}, err
+	}
+	if len(likes) > limit {
+		likes = likes[:limit]
+		result.NextCursor = likes[limit-1].ID
+	}
+
+	likerIDs := make([]int, 0, len(likes))
+	for _, like := range likes {
+		likerIDs = append(likerIDs, like.UserID)
+	}
+	likers, err := s.repo.GetUsersByID(ctx, likerIDs)
+	if err != nil {
+		return ReceivedLikes{}, err
+	}
+	err = s.attachProfileDetails(ctx, likers)
+	if err != nil {
+		return ReceivedLikes{}, fmt.Errorf("load liker profile details: %w", err)
+	}
+	likersByID := map[int]repository.User{}
+	for _, liker := range likers {
+		liker.Age = liker.CalculateAge()
+		liker.MaskPrivateFields()
+		likersByID[liker.ID] = liker
+	}
+
+	for _, like := range likes {
+		liker, ok := likersByID[like.UserID]
+		if !ok {
+			continue
+		}
+		result.Results = append(result.Results, ReceivedLike{User: liker, SuperLike: like.SuperLike, LikedAt: like.CreatedAt})
+	}
+	return result, nil
+}
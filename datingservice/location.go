@@ -0,0 +1,172 @@
+package datingservice
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/chackett/dating-service/pkg/geohash"
+	"github.com/chackett/dating-service/repository"
+	"github.com/umahmood/haversine"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxCityDistanceKm is how far a user may be from the nearest known city for it to still be given as theirs.
+const maxCityDistanceKm = 100
+
+// distanceJitter is the most a shown distance is scaled up or down by, before being bucketed.
+const distanceJitter = 0.15
+
+// distanceBuckets are the distances, in km, shown to users up to 100km. Beyond that, distances are shown to the next
+// 50km, and beyond 500km to the next 100km.
+var distanceBuckets = []int{1, 2, 5, 10, 15, 20, 25, 30, 40, 50, 75, 100}
+
+// coarsenLocationsBatch is how many users CoarsenLocations reads at a time.
+const coarsenLocationsBatch = 500
+
+var (
+	ErrLocationUpdateTooSoon = errors.New("location updated too recently")
+	ErrCoarseLocationsOff    = errors.New("coarse location storage is not enabled")
+)
+
+// LocationThrottledError is returned when a user changes location again before LocationUpdateInterval has passed.
+type LocationThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LocationThrottledError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrLocationUpdateTooSoon, e.RetryAfter)
+}
+
+func (e *LocationThrottledError) Is(target error) bool {
+	return target == ErrLocationUpdateTooSoon
+}
+
+// shownDistance is the distance between two users as shown to either of them. The true distance is scaled by a jitter
+// unique to, and stable for, the pair, then rounded up to a bucket. Repeated queries therefore always give the same
+// answer, and can't be averaged or compared at bucket edges to recover the true distance.
+func (s *DateService) shownDistance(km int, userID int, otherID int) int {
+	a, b := min(userID, otherID), max(userID, otherID)
+	mac := hmac.New(sha256.New, s.cfg.DistanceJitterKey)
+	mac.Write([]byte(strconv.Itoa(a) + ":" + strconv.Itoa(b)))
+	// A uniform value in [-1, 1) from the first 8 bytes of the MAC.
+	unit := float64(binary.BigEndian.Uint64(mac.Sum(nil)[:8]))/math.MaxUint64*2 - 1
+	return bucketDistance(float64(km) * (1 + unit*distanceJitter))
+}
+
+// bucketDistance rounds a distance up to the next one users are shown.
+func bucketDistance(km float64) int {
+	for _, bucket := range distanceBuckets {
+		if km <= float64(bucket) {
+			return bucket
+		}
+	}
+	if km <= 500 {
+		return int(math.Ceil(km/50) * 50)
+	}
+	return int(math.Ceil(km/100) * 100)
+}
+
+// storedLocation returns a valid "lat,long" in the form it is stored, which is a geohash when CoarseLocationPrecision
+// is set.
+func (s *DateService) storedLocation(location string) (string, error) {
+	coord, err := repository.ParseLocation(location)
+	if err != nil {
+		return "", err
+	}
+	if s.cfg.CoarseLocationPrecision == 0 {
+		return location, nil
+	}
+	return geohash.Encode(coord.Lat, coord.Lon, s.cfg.CoarseLocationPrecision), nil
+}
+
+// CoarsenLocations converts locations and travel locations stored before CoarseLocationPrecision was set to geohashes,
+// returning how many users were changed. Users aren't told, and their location update interval isn't reset. A stored
+// location which can't be parsed is logged and left as it is.
+func (s *DateService) CoarsenLocations(ctx context.Context) (int, error) {
+	if s.cfg.CoarseLocationPrecision == 0 {
+		return 0, ErrCoarseLocationsOff
+	}
+
+	changed, afterID := 0, 0
+	for {
+		users, err := s.repo.GetUsersWithExactLocations(ctx, afterID, coarsenLocationsBatch)
+		if err != nil {
+			return changed, err
+		}
+		if len(users) == 0 {
+			return changed, nil
+		}
+		afterID = users[len(users)-1].ID
+
+		for _, user := range users {
+			fields := map[string]interface{}{}
+			if strings.Contains(user.Location, ",") {
+				location, err := s.storedLocation(user.Location)
+				if err != nil {
+					s.logger.Warn("coarsen location", "user_id", user.ID, "err", err)
+				} else {
+					fields["location"] = location
+				}
+			}
+			if user.TravelLocation != nil && strings.Contains(*user.TravelLocation, ",") {
+				location, err := s.storedLocation(*user.TravelLocation)
+				if err != nil {
+					s.logger.Warn("coarsen travel location", "user_id", user.ID, "err", err)
+				} else {
+					fields["travel_location"] = location
+				}
+			}
+			if len(fields) == 0 {
+				continue
+			}
+
+			err = s.repo.UpdateUser(ctx, user.ID, fields)
+			if err != nil {
+				return changed, err
+			}
+			changed++
+		}
+	}
+}
+
+// checkLocationUpdate refuses a change of location, either usual or travel, made within LocationUpdateInterval of the
+// user's last one.
+func (s *DateService) checkLocationUpdate(user repository.User, now time.Time) error {
+	if user.LocationUpdatedAt == nil || s.cfg.LocationUpdateInterval == 0 {
+		return nil
+	}
+	wait := user.LocationUpdatedAt.Add(s.cfg.LocationUpdateInterval).Sub(now)
+	if wait > 0 {
+		return &LocationThrottledError{RetryAfter: wait.Round(time.Second) + time.Second}
+	}
+	return nil
+}
+
+// nearestCity names the city a user is in or near, going by where they currently are. It is empty if the nearest known
+// city is too far away to say.
+func (s *DateService) nearestCity(user repository.User) string {
+	coord := user.ReadLocation()
+	if coord == (haversine.Coord{}) {
+		return ""
+	}
+	city, km := s.cfg.Geocoder.Nearest(coord.Lat, coord.Lon)
+	if km > maxCityDistanceKm {
+		return ""
+	}
+	return city.Name
+}
+
+// cityLocation resolves a city name to its coordinates, in the "lat,long" form locations are given in.
+func (s *DateService) cityLocation(name string) (string, error) {
+	city, err := s.cfg.Geocoder.Lookup(name)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%.4f,%.4f", city.Lat, city.Lon), nil
+}
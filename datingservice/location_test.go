@@ -0,0 +1,114 @@
+package datingservice
+
+import (
+	"testing"
+)
+
+func TestBucketDistance(t *testing.T) {
+	tests := []struct {
+		km   float64
+		want int
+	}{
+		{km: 0, want: 1},
+		{km: 0.2, want: 1},
+		{km: 1, want: 1},
+		{km: 1.01, want: 2},
+		{km: 3, want: 5},
+		{km: 12, want: 15},
+		{km: 31, want: 40},
+		{km: 60, want: 75},
+		{km: 99.9, want: 100},
+		{km: 100, want: 100},
+		{km: 100.1, want: 150},
+		{km: 150, want: 150},
+		{km: 151, want: 200},
+		{km: 499, want: 500},
+		{km: 500, want: 500},
+		{km: 500.5, want: 600},
+		{km: 1234, want: 1300},
+		{km: 20000, want: 20000},
+	}
+	for _, tt := range tests {
+		got := bucketDistance(tt.km)
+		if got != tt.want {
+			t.Errorf("bucketDistance(%v) = %d, want %d", tt.km, got, tt.want)
+		}
+	}
+}
+
+func TestShownDistance(t *testing.T) {
+	s := &DateService{cfg: Config{DistanceJitterKey: []byte("0123456789abcdef")}}
+	other := &DateService{cfg: Config{DistanceJitterKey: []byte("fedcba9876543210")}}
+
+	tests := []struct {
+		name    string
+		km      int
+		userID  int
+		otherID int
+	}{
+		{name: "same place", km: 0, userID: 1, otherID: 2},
+		{name: "nearby", km: 3, userID: 1, otherID: 2},
+		{name: "bucket edge", km: 10, userID: 7, otherID: 3},
+		{name: "across town", km: 27, userID: 12, otherID: 40},
+		{name: "hundreds", km: 320, userID: 5, otherID: 6},
+		{name: "far", km: 2500, userID: 100, otherID: 99},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.shownDistance(tt.km, tt.userID, tt.otherID)
+
+			if again := s.shownDistance(tt.km, tt.userID, tt.otherID); again != got {
+				t.Errorf("repeated call = %d, want %d", again, got)
+			}
+			if reversed := s.shownDistance(tt.km, tt.otherID, tt.userID); reversed != got {
+				t.Errorf("shown to the other user = %d, want %d", reversed, got)
+			}
+			if bucketDistance(float64(got)) != got {
+				t.Errorf("shownDistance = %d, which isn't a bucket", got)
+			}
+
+			lowest := bucketDistance(float64(tt.km) * (1 - distanceJitter))
+			highest := bucketDistance(float64(tt.km) * (1 + distanceJitter))
+			if got < lowest || got > highest {
+				t.Errorf("shownDistance = %d, want between %d and %d", got, lowest, highest)
+			}
+		})
+	}
+
+	// The jitter depends on the key, so differs between deployments. Over enough pairs, some must come out differently.
+	differ := false
+	for id := 1; id <= 50 && !differ; id++ {
+		differ = s.shownDistance(100, id, id+1) != other.shownDistance(100, id, id+1)
+	}
+	if !differ {
+		t.Error("shown distances don't depend on the jitter key")
+	}
+}
+
+func TestStoredLocation(t *testing.T) {
+	tests := []struct {
+		name      string
+		precision int
+		location  string
+		want      string
+		wantErr   bool
+	}{
+		{name: "exact", precision: 0, location: "57.64911,10.40744", want: "57.64911,10.40744"},
+		{name: "coarse", precision: 5, location: "57.64911,10.40744", want: "u4pru"},
+		{name: "coarse with spaces", precision: 5, location: "57.64911, 10.40744", want: "u4pru"},
+		{name: "out of range", precision: 5, location: "91,0", wantErr: true},
+		{name: "not a location", precision: 0, location: "u4pru", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &DateService{cfg: Config{CoarseLocationPrecision: tt.precision}}
+			got, err := s.storedLocation(tt.location)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("storedLocation(%q) error = %v, want error %v", tt.location, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("storedLocation(%q) = %q, want %q", tt.location, got, tt.want)
+			}
+		})
+	}
+}
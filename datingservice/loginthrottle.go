@@ -0,0 +1,162 @@
+package datingservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chackett/dating-service/repository"
+	"strings"
+	"time"
+)
+
+var ErrTooManyAttempts = errors.New("too many failed login attempts")
+
+// LoginThrottleConfig tunes brute-force protection for Login. Failures are counted both per account and per client IP.
+// Each failure doubles the wait before the next attempt is allowed, starting at BackoffBase, and once MaxFailures is
+// reached the subject is locked out for LockoutDuration.
+type LoginThrottleConfig struct {
+	MaxAccountFailures int
+	MaxIPFailures      int
+	BackoffBase        time.Duration
+	LockoutDuration    time.Duration
+}
+
+// LoginThrottledError is returned when a login attempt is refused without checking the password.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrTooManyAttempts, e.RetryAfter)
+}
+
+func (e *LoginThrottledError) Is(target error) bool {
+	return target == ErrTooManyAttempts
+}
+
+// throttleSubject identifies a counter to check and update on login.
+type throttleSubject struct {
+	scope       string
+	subject     string
+	maxFailures int
+}
+
+func (s *DateService) loginThrottleSubjects(email string, clientIP string) []throttleSubject {
+	subjects := []throttleSubject{
+		{
+			// Keyed on the address as typed rather than the user ID, so unknown emails are throttled identically to
+			// real ones and lockouts don't reveal which exist.
+			scope:       repository.ThrottleScopeAccount,
+			subject:     strings.ToLower(strings.TrimSpace(email)),
+			maxFailures: s.cfg.LoginThrottle.MaxAccountFailures,
+		},
+	}
+	if clientIP != "" {
+		subjects = append(subjects, throttleSubject{
+			scope:       repository.ThrottleScopeIP,
+			subject:     clientIP,
+			maxFailures: s.cfg.LoginThrottle.MaxIPFailures,
+		})
+	}
+	return subjects
+}
+
+// checkLoginThrottle refuses the attempt if any subject is locked out, or is still within its backoff period.
+func (s *DateService) checkLoginThrottle(ctx context.Context, subjects []throttleSubject) error {
+	now := time.Now()
+	var wait time.Duration
+
+	for _, sub := range subjects {
+		lf, err := s.repo.GetLoginFailures(ctx, sub.scope, sub.subject)
+		if err != nil {
+			return fmt.Errorf("get login failures from repo: %w", err)
+		}
+
+		if lf.LockedUntil != nil && lf.LockedUntil.After(now) {
+			wait = max(wait, lf.LockedUntil.Sub(now))
+			continue
+		}
+		if lf.Failures == 0 {
+			continue
+		}
+
+		allowedAt := lf.LastFailureAt.Add(s.backoff(lf.Failures))
+		if allowedAt.After(now) {
+			wait = max(wait, allowedAt.Sub(now))
+		}
+	}
+
+	if wait > 0 {
+		return &LoginThrottledError{RetryAfter: wait.Round(time.Second) + time.Second}
+	}
+	return nil
+}
+
+// backoff is the wait imposed after the given number of consecutive failures, doubling each time up to the lockout
+// duration.
+func (s *DateService) backoff(failures int) time.Duration {
+	d := s.cfg.LoginThrottle.BackoffBase
+	for i := 1; i < failures && d < s.cfg.LoginThrottle.LockoutDuration; i++ {
+		d *= 2
+	}
+	return min(d, s.cfg.LoginThrottle.LockoutDuration)
+}
+
+// recordLoginFailure counts a failed attempt against every subject, locking out any that reach their limit.
+func (s *DateService) recordLoginFailure(ctx context.Context, subjects []throttleSubject) {
+	for _, sub := range subjects {
+		lf, err := s.repo.IncrementLoginFailures(ctx, sub.scope, sub.subject, s.cfg.LoginThrottle.LockoutDuration)
+		if err != nil {
+			s.logger.Error("record login failure", "scope", sub.scope, "err", err)
+			continue
+		}
+
+		if lf.Failures >= sub.maxFailures && (lf.LockedUntil == nil || lf.LockedUntil.Before(time.Now())) {
+			err = s.repo.LockSubject(ctx, sub.scope, sub.subject, time.Now().Add(s.cfg.LoginThrottle.LockoutDuration))
+			if err != nil {
+				s.logger.Error("lock out subject", "scope", sub.scope, "err", err)
+				continue
+			}
+			s.logger.Info("locked out after failed logins", "scope", sub.scope, "subject", sub.subject,
+				"failures", lf.Failures)
+		}
+	}
+}
+
+// recordLoginSuccess resets the account's failure count. The IP's count is left to decay, otherwise an attacker could
+// reset it by logging in to an account of their own between guesses.
+func (s *DateService) recordLoginSuccess(ctx context.Context, subjects []throttleSubject) {
+	for _, sub := range subjects {
+		if sub.scope != repository.ThrottleScopeAccount {
+			continue
+		}
+		err := s.repo.ClearLoginFailures(ctx, sub.scope, sub.subject)
+		if err != nil {
+			s.logger.Error("clear login failures", "scope", sub.scope, "err", err)
+		}
+	}
+}
+
+// GetActiveLockouts returns the lockouts currently in force.
+func (s *DateService) GetActiveLockouts(ctx context.Context) ([]repository.Lockout, error) {
+	lockouts, err := s.repo.GetActiveLockouts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get active lockouts from repo: %w", err)
+	}
+	return lockouts, nil
+}
+
+// ClearLockout lifts a lockout early, on behalf of an admin.
+func (s *DateService) ClearLockout(ctx context.Context, adminID int, lockoutID int) error {
+	lockout, err := s.repo.GetLockoutByID(ctx, lockoutID)
+	if err != nil {
+		return fmt.Errorf("get lockout from repo: %w", err)
+	}
+
+	err = s.repo.ClearLockout(ctx, lockoutID, adminID)
+	if err != nil {
+		return fmt.Errorf("clear lockout in repo: %w", err)
+	}
+
+	return s.audit(ctx, adminID, "clear_lockout", nil, nil, fmt.Sprintf("%s %s", lockout.Scope, lockout.Subject))
+}
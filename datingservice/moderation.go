@@ -0,0 +1,280 @@
+package datingservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chackett/dating-service/repository"
+	"time"
+)
+
+const (
+	ModerationActionWarn    = "warn"
+	ModerationActionSuspend = "suspend"
+	ModerationActionBan     = "ban"
+	ModerationActionDismiss = "dismiss"
+	// ModerationActionUnsuspend and ModerationActionUnban reinstate a user, undoing an earlier suspension or ban.
+	ModerationActionUnsuspend = "unsuspend"
+	ModerationActionUnban     = "unban"
+)
+
+var (
+	ErrInvalidModerationAction = errors.New("invalid moderation action")
+	ErrReportNotOpen           = errors.New("report is not open")
+	ErrPermissionDenied        = errors.New("permission denied")
+)
+
+// ModerationAction is an admin's decision against a user, optionally in response to a report.
+type ModerationAction struct {
+	// Action is one of warn, suspend, ban, unsuspend or unban.
+	Action string `json:"action"`
+	// Days is the length of a suspension. Ignored for other actions.
+	Days     int    `json:"days,omitempty"`
+	Reason   string `json:"reason"`
+	ReportID *int   `json:"reportId,omitempty"`
+}
+
+// ReportDetail is everything an admin needs to make a decision on a report.
+type ReportDetail struct {
+	Report repository.Report `json:"report"`
+	// Reporter is shown masked, the admin only needs to know who raised it.
+	Reporter repository.User `json:"reporter"`
+	// Reported is the full profile under review, minus the password hash.
+	Reported repository.User `json:"reported"`
+	// Swipes is the interaction history between the two users, which stands in for conversation context.
+	Swipes  []repository.Swipe `json:"swipes"`
+	Matched bool               `json:"matched"`
+	// History lists any prior moderation taken against the reported user.
+	History []repository.AuditEntry `json:"history"`
+}
+
+// ReportUser places a report from one user about another into the moderation queue.
+func (s *DateService) ReportUser(ctx context.Context, reporterID int, reportedID int, reason string) (*repository.Report, error) {
+	if reporterID == reportedID {
+		return nil, errors.New("cannot report yourself")
+	}
+	if reason == "" {
+		return nil, errors.New("a reason must be provided")
+	}
+
+	_, err := s.repo.GetUserByID(ctx, reportedID)
+	if err != nil {
+		return nil, fmt.Errorf("find reported user: %w", err)
+	}
+
+	report := &repository.Report{
+		ReporterID: reporterID,
+		ReportedID: reportedID,
+		Reason:     reason,
+		Status:     repository.ReportStatusOpen,
+	}
+	err = s.repo.CreateReport(ctx, report)
+	if err != nil {
+		return nil, fmt.Errorf("create report in repo: %w", err)
+	}
+	return report, nil
+}
+
+// GetOpenReports returns the moderation queue, oldest report first.
+func (s *DateService) GetOpenReports(ctx context.Context) ([]repository.Report, error) {
+	reports, err := s.repo.GetOpenReports(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get open reports from repo: %w", err)
+	}
+	return reports, nil
+}
+
+// GetReportDetail gathers the report along with the reported profile and the interaction history between the two users.
+func (s *DateService) GetReportDetail(ctx context.Context, reportID int) (ReportDetail, error) {
+	report, err := s.repo.GetReportByID(ctx, reportID)
+	if err != nil {
+		return ReportDetail{}, fmt.Errorf("get report from repo: %w", err)
+	}
+
+	reporter, err := s.repo.GetUserByID(ctx, report.ReporterID)
+	if err != nil {
+		return ReportDetail{}, fmt.Errorf("get reporter from repo: %w", err)
+	}
+	reporter.Age = reporter.CalculateAge()
+	reporter.MaskPrivateFields()
+
+	reported, err := s.repo.GetUserByID(ctx, report.ReportedID)
+	if err != nil {
+		return ReportDetail{}, fmt.Errorf("get reported user from repo: %w", err)
+	}
+	reported.Age = reported.CalculateAge()
+	reported.Password = ""
+
+	swipes, err := s.repo.GetSwipesBetween(ctx, report.ReporterID, report.ReportedID)
+	if err != nil {
+		return ReportDetail{}, fmt.Errorf("get swipes from repo: %w", err)
+	}
+
+	matched, err := s.repo.IsUserMatch(ctx, report.ReporterID, report.ReportedID)
+	if err != nil {
+		return ReportDetail{}, fmt.Errorf("check for user match: %w", err)
+	}
+
+	history, err := s.repo.GetAuditEntriesForUser(ctx, report.ReportedID)
+	if err != nil {
+		return ReportDetail{}, fmt.Errorf("get moderation history from repo: %w", err)
+	}
+
+	return ReportDetail{
+		Report:   report,
+		Reporter: reporter,
+		Reported: reported,
+		Swipes:   swipes,
+		Matched:  matched,
+		History:  history,
+	}, nil
+}
+
+// ModerateUser applies a moderation action to a user on behalf of a moderator. Every action is written to the audit log,
+// and if the action was taken in response to a report, that report is closed, all in one transaction. Banning and
+// unbanning additionally require the moderator's role to hold PermissionUsersBan.
+func (s *DateService) ModerateUser(ctx context.Context, moderator SessionIdentity, userID int, action ModerationAction) error {
+	_, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("find user to moderate: %w", err)
+	}
+
+	if action.ReportID != nil {
+		report, err := s.repo.GetReportByID(ctx, *action.ReportID)
+		if err != nil {
+			return fmt.Errorf("get report from repo: %w", err)
+		}
+		if report.Status != repository.ReportStatusOpen {
+			return ErrReportNotOpen
+		}
+		if report.ReportedID != userID {
+			return errors.New("report does not relate to this user")
+		}
+	}
+
+	change := repository.ModerationChange{
+		UserID:          userID,
+		ResolveReportID: action.ReportID,
+		ReportStatus:    repository.ReportStatusActioned,
+	}
+	detail := action.Reason
+	switch action.Action {
+	case ModerationActionWarn:
+		// A warning has no effect on the account beyond being recorded.
+	case ModerationActionSuspend:
+		if action.Days < 1 {
+			return fmt.Errorf("%w: suspension must be at least one day", ErrInvalidModerationAction)
+		}
+		until := time.Now().Add(time.Duration(action.Days) * 24 * time.Hour)
+		change.Standing = map[string]interface{}{"suspended_until": until}
+		change.RevokeSessions = true
+		detail = fmt.Sprintf("%d days: %s", action.Days, action.Reason)
+	case ModerationActionBan:
+		if !RoleHasPermissions(moderator.Role, PermissionUsersBan) {
+			return ErrPermissionDenied
+		}
+		change.Standing = map[string]interface{}{"banned": true}
+		change.RevokeSessions = true
+	case ModerationActionUnsuspend, ModerationActionUnban:
+		// Reinstating undoes an earlier decision, so doesn't answer a report.
+		if action.ReportID != nil {
+			return fmt.Errorf("%w: %s can't resolve a report", ErrInvalidModerationAction, action.Action)
+		}
+		if action.Action == ModerationActionUnban {
+			if !RoleHasPermissions(moderator.Role, PermissionUsersBan) {
+				return ErrPermissionDenied
+			}
+			change.Standing = map[string]interface{}{"banned": false}
+		} else {
+			change.Standing = map[string]interface{}{"suspended_until": nil}
+		}
+	default:
+		return ErrInvalidModerationAction
+	}
+
+	if change.RevokeSessions {
+		change.Revocation = s.userTokenRevocation(userID)
+	}
+	change.Audit = s.auditEntry(moderator.UserID, action.Action, &userID, action.ReportID, detail)
+	err = s.applyModeration(ctx, change)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// DismissReport closes a report without taking action against the reported user.
+func (s *DateService) DismissReport(ctx context.Context, moderatorID int, reportID int, reason string) error {
+	report, err := s.repo.GetReportByID(ctx, reportID)
+	if err != nil {
+		return fmt.Errorf("get report from repo: %w", err)
+	}
+	if report.Status != repository.ReportStatusOpen {
+		return ErrReportNotOpen
+	}
+
+	return s.applyModeration(ctx, repository.ModerationChange{
+		UserID:          report.ReportedID,
+		Audit:           s.auditEntry(moderatorID, ModerationActionDismiss, &report.ReportedID, &reportID, reason),
+		ResolveReportID: &reportID,
+		ReportStatus:    repository.ReportStatusDismissed,
+	})
+}
+
+// applyModeration writes a moderation change, then logs it and updates the in-memory denylist once it has committed.
+func (s *DateService) applyModeration(ctx context.Context, change repository.ModerationChange) error {
+	err := s.repo.ApplyModeration(ctx, change)
+	if errors.Is(err, repository.ErrReportNotOpen) {
+		return ErrReportNotOpen
+	}
+	if err != nil {
+		return err
+	}
+
+	if change.Revocation != nil {
+		s.revocations.add(*change.Revocation)
+	}
+	entry := change.Audit
+	s.logger.Info("audit", "actor_id", entry.ActorID, "action", entry.Action, "target_user_id", entry.TargetUserID,
+		"report_id", entry.ReportID)
+	return nil
+}
+
+// audit writes a privileged action to both the audit log table and the service log. An actorID of 0 records the action
+// as having no acting user, as is the case for the CLI.
+func (s *DateService) audit(ctx context.Context, actorID int, action string, targetUserID *int, reportID *int, detail string) error {
+	entry := s.auditEntry(actorID, action, targetUserID, reportID, detail)
+	err := s.repo.CreateAuditEntry(ctx, &entry)
+	if err != nil {
+		return fmt.Errorf("write audit log: %w", err)
+	}
+
+	s.logger.Info("audit", "actor_id", actorID, "action", action, "target_user_id", targetUserID, "report_id", reportID)
+	return nil
+}
+
+// auditEntry builds an audit log entry. An actorID of 0 records no acting user.
+func (s *DateService) auditEntry(actorID int, action string, targetUserID *int, reportID *int, detail string) repository.AuditEntry {
+	var actor *int
+	if actorID != 0 {
+		actor = &actorID
+	}
+	return repository.AuditEntry{
+		ActorID:      actor,
+		Action:       action,
+		TargetUserID: targetUserID,
+		ReportID:     reportID,
+		Detail:       detail,
+	}
+}
+
+// checkAccountStanding returns an error if the user is currently barred from using the service.
+func checkAccountStanding(user repository.User) error {
+	if user.Banned {
+		return ErrAccountBanned
+	}
+	if user.IsSuspended() {
+		return ErrAccountSuspended
+	}
+	return nil
+}
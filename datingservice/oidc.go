@@ -0,0 +1,248 @@
+package datingservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chackett/dating-service/pkg/oidc"
+	"github.com/chackett/dating-service/pkg/security"
+	"github.com/chackett/dating-service/repository"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// oidcLoginTTL is how long a user has to complete sign in at the provider.
+const oidcLoginTTL = 10 * time.Minute
+
+var (
+	ErrUnknownOIDCProvider = errors.New("unknown identity provider")
+	ErrOIDCLoginFailed     = errors.New("identity provider login failed")
+	// ErrOIDCEmailConflict is returned when an unlinked provider account has the email of an existing user. Providers
+	// can't be trusted to prove the user owns the address, so the user must log in and link the provider themselves.
+	ErrOIDCEmailConflict = errors.New("an account already exists with this email, log in to link it")
+	// ErrOIDCIdentityInUse is returned when linking a provider account that is already linked to another user.
+	ErrOIDCIdentityInUse = errors.New("this provider account is linked to another user")
+)
+
+// StartOIDCLogin begins signing in with an identity provider, returning the URL to send the user to. The state,
+// nonce and PKCE verifier are kept server side until they return.
+func (s *DateService) StartOIDCLogin(ctx context.Context, providerName string) (string, error) {
+	return s.startOIDCAuthRequest(ctx, providerName, nil)
+}
+
+// StartOIDCLink begins linking an identity provider account to a logged-in user, returning the URL to send the user
+// to. Once they return, the provider account signs in to this user.
+func (s *DateService) StartOIDCLink(ctx context.Context, userID int, providerName string) (string, error) {
+	return s.startOIDCAuthRequest(ctx, providerName, &userID)
+}
+
+// startOIDCAuthRequest stores a new auth request and returns the provider URL for it. linkUserID, if set, is the
+// logged-in user the provider account will be linked to.
+func (s *DateService) startOIDCAuthRequest(ctx context.Context, providerName string, linkUserID *int) (string, error) {
+	provider, ok := s.oidcProviders[providerName]
+	if !ok {
+		return "", ErrUnknownOIDCProvider
+	}
+
+	state, err := security.CreateSecureSessionToken(32)
+	if err != nil {
+		return "", fmt.Errorf("create state: %w", err)
+	}
+	nonce, err := security.CreateSecureSessionToken(16)
+	if err != nil {
+		return "", fmt.Errorf("create nonce: %w", err)
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.repo.CreateOIDCAuthRequest(ctx, &repository.OIDCAuthRequest{
+		StateHash:    security.HashToken(state),
+		Provider:     providerName,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().Add(oidcLoginTTL),
+	})
+	if err != nil {
+		return "", fmt.Errorf("store auth request: %w", err)
+	}
+
+	return provider.AuthCodeURL(ctx, state, nonce, challenge)
+}
+
+// CompleteOIDCLogin finishes signing in when the user returns from the provider. The provider account is matched to a
+// user by its linked identity, or else a new user is created for it. It is never linked to an existing user by email
+// alone, as that would hand the account to whoever controls the provider account. Two-factor authentication still
+// applies to linked accounts. If the flow was started by StartOIDCLink, the provider account is linked to that user
+// instead, and no session is created.
+func (s *DateService) CompleteOIDCLogin(ctx context.Context, providerName string, state string, code string) (LoginResult, error) {
+	provider, ok := s.oidcProviders[providerName]
+	if !ok {
+		return LoginResult{}, ErrUnknownOIDCProvider
+	}
+
+	req, err := s.repo.ConsumeOIDCAuthRequest(ctx, security.HashToken(state))
+	if err != nil {
+		return LoginResult{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	// The state must have been issued for this provider, or a response from one could be replayed against another.
+	if req.Provider != providerName {
+		return LoginResult{}, ErrInvalidToken
+	}
+
+	claims, err := provider.Exchange(ctx, code, req.CodeVerifier, req.Nonce)
+	if err != nil {
+		return LoginResult{}, fmt.Errorf("%w: %w", ErrOIDCLoginFailed, err)
+	}
+
+	if req.LinkUserID != nil {
+		identity, err := s.linkOIDCIdentity(ctx, *req.LinkUserID, providerName, claims)
+		if err != nil {
+			return LoginResult{}, err
+		}
+		return LoginResult{Linked: &identity}, nil
+	}
+
+	user, err := s.userForOIDCIdentity(ctx, providerName, claims)
+	if err != nil {
+		return LoginResult{}, err
+	}
+
+	err = checkAccountStanding(user)
+	if err != nil {
+		return LoginResult{}, err
+	}
+
+	challenge, err := s.startTwoFactorChallenge(ctx, user)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	if challenge != "" {
+		return LoginResult{TwoFactorRequired: true, ChallengeToken: challenge}, nil
+	}
+
+	token, err := s.createSession(ctx, user)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	return LoginResult{Token: token}, nil
+}
+
+// userForOIDCIdentity finds or creates the user for a provider account.
+func (s *DateService) userForOIDCIdentity(ctx context.Context, providerName string, claims oidc.IDTokenClaims) (repository.User, error) {
+	identity, err := s.repo.GetUserIdentity(ctx, providerName, claims.Subject)
+	if err != nil {
+		return repository.User{}, err
+	}
+	if identity != nil {
+		user, err := s.repo.GetUserByID(ctx, identity.UserID)
+		if err != nil {
+			return repository.User{}, fmt.Errorf("get user from repo: %w", err)
+		}
+		return user, nil
+	}
+
+	email := strings.ToLower(strings.TrimSpace(claims.Email))
+	if email == "" {
+		return repository.User{}, fmt.Errorf("%w: provider gave no email", ErrOIDCLoginFailed)
+	}
+	newIdentity := repository.UserIdentity{
+		Provider:  providerName,
+		Subject:   claims.Subject,
+		Email:     email,
+		CreatedAt: time.Now(),
+	}
+
+	_, err = s.repo.GetUserByEmail(ctx, email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return repository.User{}, fmt.Errorf("get user from repo: %w", err)
+	}
+	if err == nil {
+		return repository.User{}, ErrOIDCEmailConflict
+	}
+
+	// Users signing up through a provider have no password of their own. A random one is hashed in its place, which
+	// they can replace through the forgotten password flow if they want to log in directly.
+	unusable, err := security.CreateSecureSessionToken(32)
+	if err != nil {
+		return repository.User{}, fmt.Errorf("create placeholder password: %w", err)
+	}
+	h, err := s.cfg.PasswordHasher.Hash(unusable)
+	if err != nil {
+		return repository.User{}, fmt.Errorf("unable to hash password: %w", err)
+	}
+
+	user := repository.User{
+		Email:         email,
+		Password:      h,
+		Name:          claims.Name,
+		EmailVerified: claims.EmailVerified,
+		Role:          repository.RoleUser,
+		Plan:          repository.PlanFree,
+		Timezone:      "UTC",
+	}
+	err = s.repo.CreateUserWithIdentity(ctx, &user, &newIdentity)
+	if err != nil {
+		return repository.User{}, err
+	}
+
+	if !user.EmailVerified {
+		err = s.sendVerificationEmail(ctx, user)
+		if err != nil {
+			s.logger.Error("send verification email", "user_id", user.ID, "err", err)
+		}
+	}
+	return user, nil
+}
+
+// linkOIDCIdentity links a provider account to a logged-in user who asked for it through StartOIDCLink. Linking an
+// account already linked to the same user changes nothing.
+func (s *DateService) linkOIDCIdentity(ctx context.Context, userID int, providerName string, claims oidc.IDTokenClaims) (repository.UserIdentity, error) {
+	identity, err := s.repo.GetUserIdentity(ctx, providerName, claims.Subject)
+	if err != nil {
+		return repository.UserIdentity{}, err
+	}
+	if identity != nil {
+		if identity.UserID != userID {
+			return repository.UserIdentity{}, ErrOIDCIdentityInUse
+		}
+		return *identity, nil
+	}
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return repository.UserIdentity{}, fmt.Errorf("get user from repo: %w", err)
+	}
+	err = checkAccountStanding(user)
+	if err != nil {
+		return repository.UserIdentity{}, err
+	}
+
+	newIdentity := repository.UserIdentity{
+		UserID:    userID,
+		Provider:  providerName,
+		Subject:   claims.Subject,
+		Email:     strings.ToLower(strings.TrimSpace(claims.Email)),
+		CreatedAt: time.Now(),
+	}
+	err = s.repo.CreateUserIdentity(ctx, &newIdentity)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return repository.UserIdentity{}, ErrOIDCIdentityInUse
+	}
+	if err != nil {
+		return repository.UserIdentity{}, err
+	}
+	return newIdentity, nil
+}
+
+// GetUserIdentities returns the external identities linked to a user.
+func (s *DateService) GetUserIdentities(ctx context.Context, userID int) ([]repository.UserIdentity, error) {
+	identities, err := s.repo.GetUserIdentities(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
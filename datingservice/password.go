@@ -0,0 +1,153 @@
+package datingservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chackett/dating-service/pkg/mailer"
+	"github.com/chackett/dating-service/pkg/security"
+	"github.com/chackett/dating-service/repository"
+	"gorm.io/gorm"
+	"time"
+)
+
+const passwordResetTokenTTL = time.Hour
+
+// ForgotPassword emails a password reset token to the account with the given address, if there is one. It never
+// reveals whether the account exists: the email is sent in the background so the call takes the same time either way.
+func (s *DateService) ForgotPassword(ctx context.Context, email string) error {
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("get user from repo: %w", err)
+	}
+
+	// Detach from the request, which will have completed by the time the email is sent.
+	go func(ctx context.Context) {
+		err := s.sendPasswordResetEmail(ctx, user)
+		if err != nil {
+			s.logger.Error("send password reset email", "user_id", user.ID, "err", err)
+		}
+	}(context.WithoutCancel(ctx))
+
+	return nil
+}
+
+// ResetPassword sets a new password using a token from ForgotPassword. The token is consumed, and every session for the
+// account is revoked. The password is checked first, so a rejected one doesn't use up the token.
+func (s *DateService) ResetPassword(ctx context.Context, token string, newPassword string) error {
+	h, err := s.hashNewPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	ut, err := s.repo.ConsumeUserToken(ctx, repository.TokenPurposePasswordReset, security.HashToken(token))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	user, err := s.repo.GetUserByID(ctx, ut.UserID)
+	if err != nil {
+		return fmt.Errorf("get user from repo: %w", err)
+	}
+	if user.Email != ut.Email {
+		return ErrInvalidToken
+	}
+
+	return s.storePassword(ctx, user.ID, h)
+}
+
+// ChangePassword sets a new password for a logged-in user, who must supply their current password. Every session for
+// the account is revoked, including the caller's.
+func (s *DateService) ChangePassword(ctx context.Context, userID int, currentPassword string, newPassword string) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user from repo: %w", err)
+	}
+
+	match, err := s.cfg.PasswordHasher.Verify(user.Password, currentPassword)
+	if err != nil || !match {
+		return ErrIncorrectPassword
+	}
+
+	return s.setPassword(ctx, userID, newPassword)
+}
+
+// setPassword stores a new password and revokes everything that granted access under the old one.
+func (s *DateService) setPassword(ctx context.Context, userID int, newPassword string) error {
+	h, err := s.hashNewPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	return s.storePassword(ctx, userID, h)
+}
+
+// hashNewPassword validates a password a user has chosen and hashes it.
+func (s *DateService) hashNewPassword(newPassword string) (string, error) {
+	if newPassword == "" {
+		return "", fmt.Errorf("%w: password cannot be empty", ErrInvalidProfile)
+	}
+
+	h, err := s.cfg.PasswordHasher.Hash(newPassword)
+	if err != nil {
+		return "", fmt.Errorf("unable to hash password: %w", err)
+	}
+	return h, nil
+}
+
+// storePassword stores a new password hash and revokes everything that granted access under the old one: sessions and
+// outstanding reset tokens.
+func (s *DateService) storePassword(ctx context.Context, userID int, h string) error {
+	err := s.repo.UpdateUser(ctx, userID, map[string]interface{}{"password": h})
+	if err != nil {
+		return fmt.Errorf("update password in repo: %w", err)
+	}
+
+	err = s.revokeUserSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.InvalidateUserTokens(ctx, userID, repository.TokenPurposePasswordReset)
+	if err != nil {
+		return fmt.Errorf("invalidate reset tokens: %w", err)
+	}
+	return nil
+}
+
+func (s *DateService) sendPasswordResetEmail(ctx context.Context, user repository.User) error {
+	err := s.repo.InvalidateUserTokens(ctx, user.ID, repository.TokenPurposePasswordReset)
+	if err != nil {
+		return fmt.Errorf("invalidate previous tokens: %w", err)
+	}
+
+	token, err := security.CreateSecureSessionToken(32)
+	if err != nil {
+		return fmt.Errorf("create reset token: %w", err)
+	}
+
+	err = s.repo.CreateUserToken(ctx, &repository.UserToken{
+		UserID:    user.ID,
+		Purpose:   repository.TokenPurposePasswordReset,
+		TokenHash: security.HashToken(token),
+		Email:     user.Email,
+		ExpiresAt: time.Now().Add(passwordResetTokenTTL),
+	})
+	if err != nil {
+		return fmt.Errorf("store reset token: %w", err)
+	}
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password for your account. If that was you, submit the "+
+			"code below to /password/reset along with your new password. It expires in %s.\n\n%s\n\n"+
+			"If it wasn't you, you can ignore this email.\n", user.Name, passwordResetTokenTTL, token),
+	})
+	if err != nil {
+		return fmt.Errorf("send reset email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package datingservice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chackett/dating-service/pkg/imaging"
+	"github.com/chackett/dating-service/pkg/security"
+	"github.com/chackett/dating-service/repository"
+	"sort"
+)
+
+const (
+	maxUserPhotos = 6
+	// MaxPhotoUploadBytes is the largest photo file accepted for upload.
+	MaxPhotoUploadBytes = 10 << 20
+)
+
+var ErrInvalidPhoto = errors.New("invalid photo")
+
+// UploadPhoto validates and processes an uploaded photo, stores it along with a thumbnail and appends it to the end of
+// the user's photos.
+func (s *DateService) UploadPhoto(ctx context.Context, userID int, data []byte) (repository.Photo, error) {
+	if len(data) > MaxPhotoUploadBytes {
+		return repository.Photo{}, fmt.Errorf("%w: photo must be at most %d bytes", ErrInvalidPhoto, MaxPhotoUploadBytes)
+	}
+
+	// Checked here to save processing a photo which can't be added, and again as it is added.
+	count, err := s.repo.CountUserPhotos(ctx, userID)
+	if err != nil {
+		return repository.Photo{}, fmt.Errorf("count photos in repo: %w", err)
+	}
+	if count >= maxUserPhotos {
+		return repository.Photo{}, fmt.Errorf("%w: at most %d photos allowed", ErrInvalidPhoto, maxUserPhotos)
+	}
+
+	processed, err := imaging.ProcessPhoto(data)
+	if err != nil {
+		return repository.Photo{}, fmt.Errorf("%w: %w", ErrInvalidPhoto, err)
+	}
+
+	// Keys are unguessable, as stores may serve objects publicly.
+	name, err := security.CreateSecureSessionToken(16)
+	if err != nil {
+		return repository.Photo{}, fmt.Errorf("create photo key: %w", err)
+	}
+	ext := ".jpg"
+	if processed.ContentType == "image/png" {
+		ext = ".png"
+	}
+
+	photo := repository.Photo{
+		UserID:       userID,
+		ImageKey:     fmt.Sprintf("photos/%d/%s%s", userID, name, ext),
+		ThumbnailKey: fmt.Sprintf("photos/%d/%s_thumb%s", userID, name, ext),
+		ContentType:  processed.ContentType,
+		Width:        processed.Width,
+		Height:       processed.Height,
+	}
+
+	err = s.photos.Put(ctx, photo.ImageKey, photo.ContentType, bytes.NewReader(processed.Image))
+	if err != nil {
+		return repository.Photo{}, fmt.Errorf("store photo: %w", err)
+	}
+	err = s.photos.Put(ctx, photo.ThumbnailKey, photo.ContentType, bytes.NewReader(processed.Thumbnail))
+	if err != nil {
+		s.deletePhotoBlobs(ctx, photo)
+		return repository.Photo{}, fmt.Errorf("store thumbnail: %w", err)
+	}
+
+	err = s.repo.CreatePhoto(ctx, &photo, maxUserPhotos)
+	if err != nil {
+		s.deletePhotoBlobs(ctx, photo)
+		if errors.Is(err, repository.ErrTooManyPhotos) {
+			return repository.Photo{}, fmt.Errorf("%w: at most %d photos allowed", ErrInvalidPhoto, maxUserPhotos)
+		}
+		return repository.Photo{}, fmt.Errorf("create photo in repo: %w", err)
+	}
+
+	s.resolvePhotoURLs(&photo)
+	return photo, nil
+}
+
+// DeletePhoto removes one of the user's photos, along with its stored images.
+func (s *DateService) DeletePhoto(ctx context.Context, userID int, photoID int) error {
+	photo, err := s.repo.GetUserPhoto(ctx, userID, photoID)
+	if err != nil {
+		return fmt.Errorf("get photo from repo: %w", err)
+	}
+
+	err = s.repo.DeleteUserPhoto(ctx, userID, photoID)
+	if err != nil {
+		return fmt.Errorf("delete photo in repo: %w", err)
+	}
+
+	// The photo is gone from the profile at this point, so failing to clean up the blobs is only logged.
+	s.deletePhotoBlobs(ctx, photo)
+	return nil
+}
+
+// ReorderPhotos sets the display order of the user's photos. photoIDs must list every one of the user's photos.
+func (s *DateService) ReorderPhotos(ctx context.Context, userID int, photoIDs []int) error {
+	current, err := s.repo.GetPhotosForUsers(ctx, []int{userID})
+	if err != nil {
+		return fmt.Errorf("get photos from repo: %w", err)
+	}
+
+	have := make([]int, 0, len(current[userID]))
+	for _, p := range current[userID] {
+		have = append(have, p.ID)
+	}
+	want := append([]int(nil), photoIDs...)
+	sort.Ints(have)
+	sort.Ints(want)
+	if len(have) != len(want) {
+		return fmt.Errorf("%w: order must include every photo exactly once", ErrInvalidPhoto)
+	}
+	for i := range have {
+		if have[i] != want[i] {
+			return fmt.Errorf("%w: order must include every photo exactly once", ErrInvalidPhoto)
+		}
+	}
+
+	err = s.repo.SetPhotoOrder(ctx, userID, photoIDs)
+	if err != nil {
+		return fmt.Errorf("set photo order in repo: %w", err)
+	}
+	return nil
+}
+
+func (s *DateService) resolvePhotoURLs(photo *repository.Photo) {
+	photo.URL = s.photos.URL(photo.ImageKey)
+	photo.ThumbnailURL = s.photos.URL(photo.ThumbnailKey)
+}
+
+func (s *DateService) deletePhotoBlobs(ctx context.Context, photo repository.Photo) {
+	for _, key := range []string{photo.ImageKey, photo.ThumbnailKey} {
+		err := s.photos.Delete(ctx, key)
+		if err != nil {
+			s.logger.Error("delete photo blob", "key", key, "err", err)
+		}
+	}
+}
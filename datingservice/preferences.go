@@ -0,0 +1,68 @@
+package datingservice
+
+import (
+	"context"
+	"fmt"
+	"github.com/chackett/dating-service/repository"
+	"slices"
+)
+
+const maxPreferredGenders = 10
+
+// GetEducationLevels returns the education scale preferences are expressed against, lowest first.
+func (s *DateService) GetEducationLevels(ctx context.Context) ([]repository.EducationLevel, error) {
+	levels, err := s.repo.GetEducationLevels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get education levels from repo: %w", err)
+	}
+	return levels, nil
+}
+
+// normalisePreferenceValues checks the multi-value and education preferences, canonicalising and deduplicating the
+// lists. Every gender and education level given must be known.
+func (s *DateService) normalisePreferenceValues(ctx context.Context, prefs *repository.UserPreferences) error {
+	genders, err := s.genders.canonicalList(prefs.Genders)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidPreferences, err)
+	}
+	showMeTo, err := s.genders.canonicalList(prefs.ShowMeTo)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidPreferences, err)
+	}
+	if len(genders) > maxPreferredGenders || len(showMeTo) > maxPreferredGenders {
+		return fmt.Errorf("%w: at most %d genders may be chosen", ErrInvalidPreferences, maxPreferredGenders)
+	}
+	prefs.Genders, prefs.ShowMeTo = genders, showMeTo
+
+	levels, err := s.repo.GetEducationLevels(ctx)
+	if err != nil {
+		return fmt.Errorf("validate education levels: %w", err)
+	}
+	known := func(code string) bool {
+		for _, l := range levels {
+			if l.Code == code {
+				return true
+			}
+		}
+		return false
+	}
+
+	accepted := make([]string, 0, len(prefs.AcceptedEducationLevels))
+	for _, code := range prefs.AcceptedEducationLevels {
+		if !known(code) {
+			return fmt.Errorf("%w: unknown education level %q", ErrInvalidPreferences, code)
+		}
+		if !slices.Contains(accepted, code) {
+			accepted = append(accepted, code)
+		}
+	}
+	prefs.AcceptedEducationLevels = accepted
+
+	if prefs.EducationLevel != "" && !known(prefs.EducationLevel) {
+		return fmt.Errorf("%w: unknown education level %q", ErrInvalidPreferences, prefs.EducationLevel)
+	}
+	if prefs.MinEducationLevel != "" && !known(prefs.MinEducationLevel) {
+		return fmt.Errorf("%w: unknown education level %q", ErrInvalidPreferences, prefs.MinEducationLevel)
+	}
+	return nil
+}
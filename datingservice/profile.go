@@ -0,0 +1,286 @@
+package datingservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chackett/dating-service/repository"
+	"net/mail"
+	"time"
+)
+
+const (
+	maxNameLength   = 255
+	maxGenderLength = 50
+	maxBioLength    = 500
+	maxAgeYears     = 120
+)
+
+var (
+	ErrProfileNotVisible = errors.New("profile not visible")
+	ErrInvalidProfile    = errors.New("invalid profile")
+	ErrIncorrectPassword = errors.New("incorrect password")
+)
+
+// ProfileUpdate is a partial update to the logged-in user's profile. Nil fields are left unchanged.
+type ProfileUpdate struct {
+	Name     *string `json:"name"`
+	Location *string `json:"location"`
+	// City sets the location to a known city, found by name as in "Portland" or "Portland, US". It can't be given
+	// along with Location.
+	City   *string `json:"city"`
+	Gender *string `json:"gender"`
+	Bio    *string `json:"bio"`
+	// DateOfBirth may only be given while the user has none, as is the case after signing up through an identity
+	// provider. Users without one are left out of discover.
+	DateOfBirth *time.Time `json:"dateOfBirth"`
+	// Timezone is an IANA zone name such as "Europe/London". It decides when daily quotas reset.
+	Timezone *string `json:"timezone"`
+	// Email changes mark the account as unverified until the new address is confirmed.
+	Email *string `json:"email"`
+	// Password changes require CurrentPassword and revoke all sessions, including the caller's.
+	Password        *string `json:"password"`
+	CurrentPassword string  `json:"currentPassword"`
+}
+
+// GetProfile returns the full profile of a user, as seen by that user. The password hash is never returned.
+func (s *DateService) GetProfile(ctx context.Context, userID int) (repository.User, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return repository.User{}, fmt.Errorf("get user from repo: %w", err)
+	}
+
+	user.Password = ""
+	if user.DateOfBirth != nil {
+		user.Age = user.CalculateAge()
+	}
+	// A finished trip is no longer of interest.
+	if user.TravelEndsAt != nil && !user.TravelEndsAt.After(time.Now()) {
+		user.TravelLocation, user.TravelStartsAt, user.TravelEndsAt = nil, nil, nil
+	}
+
+	users := []repository.User{user}
+	err = s.attachProfileDetails(ctx, users)
+	if err != nil {
+		return repository.User{}, err
+	}
+	return users[0], nil
+}
+
+// UpdateProfile applies a partial update to the session user's profile, returning the updated profile.
+func (s *DateService) UpdateProfile(ctx context.Context, session SessionIdentity, update ProfileUpdate) (repository.User, error) {
+	user, err := s.repo.GetUserByID(ctx, session.UserID)
+	if err != nil {
+		return repository.User{}, fmt.Errorf("get user from repo: %w", err)
+	}
+
+	fields := map[string]interface{}{}
+
+	if update.Name != nil {
+		if *update.Name == "" || len(*update.Name) > maxNameLength {
+			return repository.User{}, fmt.Errorf("%w: name must be 1-%d characters", ErrInvalidProfile, maxNameLength)
+		}
+		fields["name"] = *update.Name
+	}
+
+	if update.City != nil {
+		if update.Location != nil {
+			return repository.User{}, fmt.Errorf("%w: give either location or city", ErrInvalidProfile)
+		}
+		location, err := s.cityLocation(*update.City)
+		if err != nil {
+			return repository.User{}, fmt.Errorf("%w: %w", ErrInvalidProfile, err)
+		}
+		update.Location = &location
+	}
+
+	if update.Location != nil {
+		location, err := s.storedLocation(*update.Location)
+		if err != nil {
+			return repository.User{}, fmt.Errorf("%w: %w", ErrInvalidProfile, err)
+		}
+		if location != user.Location {
+			now := time.Now()
+			err = s.checkLocationUpdate(user, now)
+			if err != nil {
+				return repository.User{}, err
+			}
+			fields["location"] = location
+			fields["location_updated_at"] = now
+		}
+	}
+
+	if update.Gender != nil {
+		gender, ok := s.genders.canonical(*update.Gender)
+		if !ok {
+			return repository.User{}, fmt.Errorf("%w: unknown gender, see GET /genders", ErrInvalidProfile)
+		}
+		fields["gender"] = gender
+	}
+
+	if update.DateOfBirth != nil {
+		if user.DateOfBirth != nil {
+			return repository.User{}, fmt.Errorf("%w: date of birth can't be changed once set", ErrInvalidProfile)
+		}
+		if update.DateOfBirth.After(time.Now()) || update.DateOfBirth.Before(time.Now().AddDate(-maxAgeYears, 0, 0)) {
+			return repository.User{}, fmt.Errorf("%w: invalid date of birth", ErrInvalidProfile)
+		}
+		fields["date_of_birth"] = *update.DateOfBirth
+	}
+
+	if update.Bio != nil {
+		if len(*update.Bio) > maxBioLength {
+			return repository.User{}, fmt.Errorf("%w: bio must be at most %d characters", ErrInvalidProfile, maxBioLength)
+		}
+		fields["bio"] = *update.Bio
+	}
+
+	if update.Timezone != nil {
+		if !isValidTimezone(*update.Timezone) {
+			return repository.User{}, fmt.Errorf("%w: unknown timezone", ErrInvalidProfile)
+		}
+		fields["timezone"] = *update.Timezone
+	}
+
+	if update.Email != nil && *update.Email != user.Email {
+		_, err = mail.ParseAddress(*update.Email)
+		if err != nil {
+			return repository.User{}, fmt.Errorf("%w: invalid email address", ErrInvalidProfile)
+		}
+		fields["email"] = *update.Email
+		fields["email_verified"] = false
+	}
+
+	// Check the current password up front, so a wrong one doesn't leave the rest of the update half applied.
+	if update.Password != nil {
+		match, err := s.cfg.PasswordHasher.Verify(user.Password, update.CurrentPassword)
+		if err != nil || !match {
+			return repository.User{}, ErrIncorrectPassword
+		}
+		if *update.Password == "" {
+			return repository.User{}, fmt.Errorf("%w: password cannot be empty", ErrInvalidProfile)
+		}
+	}
+
+	for _, field := range []string{"name", "gender", "date_of_birth", "bio"} {
+		if _, ok := fields[field]; ok {
+			fields["profile_updated_at"] = time.Now()
+			break
+		}
+	}
+
+	if len(fields) > 0 {
+		err = s.repo.UpdateUser(ctx, session.UserID, fields)
+		if err != nil {
+			return repository.User{}, fmt.Errorf("update user in repo: %w", err)
+		}
+	}
+
+	if _, ok := fields["email"]; ok {
+		user.Email = *update.Email
+		err = s.sendVerificationEmail(ctx, user)
+		if err != nil {
+			s.logger.Error("send verification email", "user_id", user.ID, "err", err)
+		}
+	}
+
+	if update.Password != nil {
+		err = s.setPassword(ctx, session.UserID, *update.Password)
+		if err != nil {
+			return repository.User{}, err
+		}
+	}
+
+	return s.GetProfile(ctx, session.UserID)
+}
+
+// isValidTimezone reports whether tz is a known IANA zone name. "Local" is refused, as it means the server's zone.
+func isValidTimezone(tz string) bool {
+	if tz == "" || tz == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(tz)
+	return err == nil
+}
+
+// ViewProfile returns another user's profile with private fields masked. The viewer may only see profiles that would
+// currently appear in their discover results, or that they have matched with.
+func (s *DateService) ViewProfile(ctx context.Context, viewerID int, userID int) (repository.User, error) {
+	if viewerID != userID {
+		visible, err := s.canViewProfile(ctx, viewerID, userID)
+		if err != nil {
+			return repository.User{}, err
+		}
+		if !visible {
+			return repository.User{}, ErrProfileNotVisible
+		}
+	}
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return repository.User{}, fmt.Errorf("get user from repo: %w", err)
+	}
+	if user.Banned {
+		return repository.User{}, ErrProfileNotVisible
+	}
+
+	if user.DateOfBirth != nil {
+		user.Age = user.CalculateAge()
+	}
+	user.MaskPrivateFields()
+
+	users := []repository.User{user}
+	err = s.attachProfileDetails(ctx, users)
+	if err != nil {
+		return repository.User{}, err
+	}
+	return users[0], nil
+}
+
+// canViewProfile applies the same rules as Discover to decide whether viewer may see a candidate, while also allowing
+// matched users to see one another.
+func (s *DateService) canViewProfile(ctx context.Context, viewerID int, candidateID int) (bool, error) {
+	matched, err := s.repo.IsUserMatch(ctx, viewerID, candidateID)
+	if err != nil {
+		return false, fmt.Errorf("check for user match: %w", err)
+	}
+	if matched {
+		return true, nil
+	}
+
+	swiped, err := s.repo.HasSwiped(ctx, viewerID, candidateID, s.passesExpireBefore(time.Now()))
+	if err != nil {
+		return false, fmt.Errorf("check for existing swipe: %w", err)
+	}
+	if swiped {
+		// Already rated, so no longer a discover candidate.
+		return false, nil
+	}
+
+	viewer, err := s.repo.GetUserByID(ctx, viewerID)
+	if err != nil {
+		return false, fmt.Errorf("get viewer from repo: %w", err)
+	}
+	candidate, err := s.repo.GetUserByID(ctx, candidateID)
+	if err != nil {
+		return false, fmt.Errorf("get candidate from repo: %w", err)
+	}
+	if !candidate.IsDiscoverable() {
+		return false, nil
+	}
+
+	viewerPrefs, err := s.repo.GetUserPreferences(ctx, viewerID)
+	if err != nil {
+		return false, fmt.Errorf("get user preferences from repo: %w", err)
+	}
+	canPrefs, err := s.repo.GetUserPreferences(ctx, candidateID)
+	if err != nil {
+		return false, fmt.Errorf("get user preferences from repo: %w", err)
+	}
+
+	score, err := viewer.RankCandidate(candidate, viewerPrefs, canPrefs)
+	if err != nil {
+		return false, fmt.Errorf("rank candidate: %w", err)
+	}
+	return score != -1, nil
+}
@@ -4,53 +4,267 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/chackett/dating-service/pkg/blobstore"
+	"github.com/chackett/dating-service/pkg/geocode"
+	"github.com/chackett/dating-service/pkg/geohash"
+	"github.com/chackett/dating-service/pkg/mailer"
+	"github.com/chackett/dating-service/pkg/oidc"
 	"github.com/chackett/dating-service/pkg/security"
 	"github.com/chackett/dating-service/rankingservice"
 	"github.com/chackett/dating-service/repository"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 	"log/slog"
 	"os"
 	"time"
 )
 
-var ErrDuplicateSwipe = errors.New("already swiped this user")
+var (
+	ErrDuplicateSwipe = errors.New("already swiped this user")
+	ErrInvalidSwipe   = errors.New("a super like must also be a like")
+	// ErrInvalidPreferences is returned when preferences contradict themselves.
+	ErrInvalidPreferences = errors.New("invalid preferences")
+	ErrAccountSuspended   = errors.New("account is suspended")
+	ErrAccountBanned      = errors.New("account is banned")
+)
 
 const ctxKeySessionUserID = "session_user_id"
 
+// superLikeRankingBoost is added to the ranking of candidates who super liked the user, enough to put them ahead of
+// almost any candidate who didn't.
+const superLikeRankingBoost = 10
+
 // DateService is the core component of this project, sitting between the HTTP layer and DB repository.
 // Business logic is to be performed here.
 type DateService struct {
 	logger *slog.Logger
 	repo   *repository.Repository
+	// photos stores profile photos and their thumbnails.
+	photos blobstore.Store
+	mailer mailer.Mailer
+	cfg    Config
+	// totpBox encrypts TOTP secrets. Nil if no key is configured.
+	totpBox *security.SecretBox
+	// oidcProviders are the configured identity providers, by name.
+	oidcProviders map[string]*oidc.Provider
+	// revocations denylists signed access tokens which were ended early.
+	revocations *tokenDenylist
+	// dummyHash is verified against when a login names an unknown account, so it takes as long as a real one.
+	dummyHash string
+	genders   *genderTaxonomy
+}
+
+// Config holds the tunable behaviour of the DateService.
+type Config struct {
+	// PasswordHasher determines how new passwords are hashed. Existing hashes made with weaker settings are upgraded
+	// the next time the user logs in.
+	PasswordHasher security.PasswordHasher
+	LoginThrottle  LoginThrottleConfig
+	// TOTPEncryptionKey is the 32 byte key TOTP secrets are encrypted with at rest. Two-factor authentication can't be
+	// enrolled without it.
+	TOTPEncryptionKey []byte
+	// TOTPIssuer names the service in authenticator apps.
+	TOTPIssuer string
+	// SessionMode is either SessionModeDatabase, the default, or SessionModeSigned.
+	SessionMode string
+	// AccessTokens signs and verifies tokens in the signed session mode.
+	AccessTokens *security.AccessTokenCodec
+	// AccessTokenTTL is how long signed access tokens are valid for. Keep it short, as the only way to end one early is
+	// the denylist.
+	AccessTokenTTL time.Duration
+	// OIDCProviders are the identity providers users may sign in with.
+	OIDCProviders []*oidc.Provider
+	// FreeDailyLikes is how many likes users without premium may make per day, in their own timezone.
+	FreeDailyLikes int
+	// FreeDailySuperLikes and PremiumDailySuperLikes are how many super likes users may make per day on each plan.
+	FreeDailySuperLikes    int
+	PremiumDailySuperLikes int
+	// FreeDailyUndos is how many swipes users without premium may undo per day. Premium users are unlimited.
+	FreeDailyUndos int
+	// UndoWindow is how long after a swipe it can be undone.
+	UndoWindow time.Duration
+	// PassCooldown is how long a pass keeps a candidate out of the user's discover results. Zero means forever.
+	PassCooldown time.Duration
+	// BoostDuration is how long a boost lasts, and PremiumWeeklyBoosts how many premium users get each week without
+	// spending credits.
+	BoostDuration       time.Duration
+	PremiumWeeklyBoosts int
+	// DistanceJitterKey keys the per-pair jitter applied to distances shown to users. It must stay secret and, so that
+	// jitter stays stable, shouldn't change.
+	DistanceJitterKey []byte
+	// LocationUpdateInterval is the least time allowed between a user's location changes. Zero allows any number.
+	LocationUpdateInterval time.Duration
+	// CoarseLocationPrecision, if set, stores locations as geohashes of that many characters rather than exact
+	// coordinates.
+	CoarseLocationPrecision int
+	// Geocoder names the city users are in, and resolves city names given for locations.
+	Geocoder *geocode.Geocoder
+	// Genders are the genders users may choose from, and GenderAliases maps other names, such as older values still
+	// in the DB, to one of them. Both are matched case-insensitively.
+	Genders       []string
+	GenderAliases map[string]string
 }
 
 // New returns a new instance of DateService
-func New(repo *repository.Repository) (*DateService, error) {
+func New(repo *repository.Repository, photos blobstore.Store, m mailer.Mailer, cfg Config) (*DateService, error) {
+	if photos == nil {
+		return nil, errors.New("photo store is nil")
+	}
+	if m == nil {
+		return nil, errors.New("mailer is nil")
+	}
+	err := cfg.PasswordHasher.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid password hasher config: %w", err)
+	}
+	if cfg.LoginThrottle.MaxAccountFailures < 1 || cfg.LoginThrottle.MaxIPFailures < 1 ||
+		cfg.LoginThrottle.BackoffBase <= 0 || cfg.LoginThrottle.LockoutDuration <= 0 {
+		return nil, errors.New("invalid login throttle config")
+	}
+
+	if cfg.FreeDailyLikes < 1 {
+		return nil, errors.New("free daily likes must be at least 1")
+	}
+	if cfg.FreeDailySuperLikes < 0 || cfg.PremiumDailySuperLikes < 0 {
+		return nil, errors.New("daily super likes can't be negative")
+	}
+	if cfg.FreeDailyUndos < 0 || cfg.UndoWindow <= 0 {
+		return nil, errors.New("invalid undo config")
+	}
+	if cfg.PassCooldown < 0 {
+		return nil, errors.New("pass cooldown can't be negative")
+	}
+	if cfg.BoostDuration <= 0 || cfg.PremiumWeeklyBoosts < 0 {
+		return nil, errors.New("invalid boost config")
+	}
+	if cfg.Geocoder == nil {
+		return nil, errors.New("geocoder is nil")
+	}
+	if len(cfg.DistanceJitterKey) < 16 {
+		return nil, errors.New("distance jitter key must be at least 16 bytes")
+	}
+	if cfg.LocationUpdateInterval < 0 || cfg.CoarseLocationPrecision < 0 || cfg.CoarseLocationPrecision > geohash.MaxPrecision {
+		return nil, errors.New("invalid location privacy config")
+	}
+
+	genders, err := newGenderTaxonomy(cfg.Genders, cfg.GenderAliases)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gender config: %w", err)
+	}
+
+	switch cfg.SessionMode {
+	case "":
+		cfg.SessionMode = SessionModeDatabase
+	case SessionModeDatabase:
+	case SessionModeSigned:
+		if cfg.AccessTokens == nil || cfg.AccessTokenTTL <= 0 {
+			return nil, errors.New("signed session mode needs access token keys and a ttl")
+		}
+	default:
+		return nil, fmt.Errorf("unknown session mode %q", cfg.SessionMode)
+	}
+
+	dummyHash, err := cfg.PasswordHasher.Hash("not a real password")
+	if err != nil {
+		return nil, fmt.Errorf("create dummy password hash: %w", err)
+	}
+
+	var totpBox *security.SecretBox
+	if len(cfg.TOTPEncryptionKey) > 0 {
+		totpBox, err = security.NewSecretBox(cfg.TOTPEncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid totp encryption key: %w", err)
+		}
+	}
+
+	oidcProviders := make(map[string]*oidc.Provider, len(cfg.OIDCProviders))
+	for _, p := range cfg.OIDCProviders {
+		if _, dup := oidcProviders[p.Name()]; dup {
+			return nil, fmt.Errorf("duplicate oidc provider %q", p.Name())
+		}
+		oidcProviders[p.Name()] = p
+	}
+
 	result := &DateService{
 		logger: slog.New(slog.NewJSONHandler(os.Stdout, nil)),
 		repo:   repo,
+		photos: photos,
+		mailer: m,
+		cfg:    cfg,
+
+		totpBox:       totpBox,
+		oidcProviders: oidcProviders,
+		revocations:   newTokenDenylist(),
+		dummyHash:     dummyHash,
+		genders:       genders,
 	}
 
 	return result, nil
 }
 
+// NewUser is a sign up request. Only these fields are taken from the client; everything else about the account, such
+// as its role, plan and boost credits, starts from the defaults CreateUser sets.
+type NewUser struct {
+	Email       string     `json:"email"`
+	Password    string     `json:"password"`
+	Name        string     `json:"name"`
+	Gender      string     `json:"gender"`
+	DateOfBirth *time.Time `json:"dateOfBirth"`
+	Location    string     `json:"location"`
+	Bio         string     `json:"bio"`
+	Timezone    string     `json:"timezone"`
+}
+
 // CreateUser persists a new user into the DB.
 // Note that passwords are not persisted "as is" but rather hashed using a PBKDF.
 // If successful, the created user is returned with its unique identifer (`ID`) populated and the password removed.
-func (s *DateService) CreateUser(ctx context.Context, user repository.User) (*repository.User, error) {
-	h, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.MinCost)
+// The account starts unverified, and a verification token is emailed to the user.
+func (s *DateService) CreateUser(ctx context.Context, input NewUser) (*repository.User, error) {
+	h, err := s.cfg.PasswordHasher.Hash(input.Password)
 	if err != nil {
 		return nil, fmt.Errorf("unable to hash password: %w", err)
 	}
 
-	user.Password = string(h)
+	user := repository.User{
+		Email:       input.Email,
+		Password:    h,
+		Name:        input.Name,
+		Gender:      input.Gender,
+		DateOfBirth: input.DateOfBirth,
+		Location:    input.Location,
+		Bio:         input.Bio,
+		Timezone:    input.Timezone,
+		Role:        repository.RoleUser,
+		Plan:        repository.PlanFree,
+	}
+	if !isValidTimezone(user.Timezone) {
+		user.Timezone = "UTC"
+	}
+	if user.Gender != "" {
+		gender, ok := s.genders.canonical(user.Gender)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown gender", ErrInvalidProfile)
+		}
+		user.Gender = gender
+	}
+	if user.Location != "" {
+		user.Location, err = s.storedLocation(user.Location)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidProfile, err)
+		}
+	}
 	createdUser, err := s.repo.CreateUser(ctx, &user)
 	if err != nil {
 		return nil, errors.New("")
 	}
 	// Clear password as soon as is appropriate.
 	createdUser.Password = ""
+
+	// Failing to send isn't fatal, the user can ask for the email again once logged in.
+	err = s.sendVerificationEmail(ctx, *createdUser)
+	if err != nil {
+		s.logger.Error("send verification email", "user_id", createdUser.ID, "err", err)
+	}
+
 	createdUser.Age = createdUser.CalculateAge()
 	createdUser.DateOfBirth = nil
 	return createdUser, nil
@@ -59,24 +273,106 @@ func (s *DateService) CreateUser(ctx context.Context, user repository.User) (*re
 // SetUserPreferences stores an updated set of preferences for a user. Note that the underlying DB operation is "upsert"
 // so existing preferences will be overridden.
 func (s *DateService) SetUserPreferences(ctx context.Context, prefs repository.UserPreferences) error {
-	err := s.repo.UpsertUserPreferences(ctx, prefs)
+	switch {
+	case prefs.MinAge < 0 || prefs.MaxAge < prefs.MinAge:
+		return fmt.Errorf("%w: age range must be non-negative and minAge no more than maxAge", ErrInvalidPreferences)
+	case prefs.MaxDistanceKm < 0:
+		return fmt.Errorf("%w: maxDistanceKm can't be negative", ErrInvalidPreferences)
+	case prefs.Dealbreakers.Distance && prefs.MaxDistanceKm == 0:
+		return fmt.Errorf("%w: a distance dealbreaker needs maxDistanceKm", ErrInvalidPreferences)
+	}
+
+	err := s.normalisePreferenceValues(ctx, &prefs)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.UpsertUserPreferences(ctx, prefs)
 	if err != nil {
 		return fmt.Errorf("unable to upsert user preferences: %w", err)
 	}
 	return nil
 }
 
+// GetUserPreferences returns the preferences a user has set.
+func (s *DateService) GetUserPreferences(ctx context.Context, userID int) (repository.UserPreferences, error) {
+	return s.repo.GetUserPreferences(ctx, userID)
+}
+
+// LoginResult is the outcome of a successful password check. Either Token is set, or two-factor authentication is
+// required and ChallengeToken must be exchanged along with a code.
+type LoginResult struct {
+	Token             string `json:"token,omitempty"`
+	TwoFactorRequired bool   `json:"twoFactorRequired,omitempty"`
+	ChallengeToken    string `json:"challengeToken,omitempty"`
+	// Linked is set, in place of a session, when an identity provider flow linked an account to a logged-in user.
+	Linked *repository.UserIdentity `json:"linked,omitempty"`
+}
+
 // Login is used to create an authenticated session for a user, so subsequent authenticated calls can be made. Here a username
 // and password is provided, and if valid, a session token is returned.
-func (s *DateService) Login(ctx context.Context, email string, password string) (string, error) {
+// Repeated failures from the same account or client IP are throttled with an increasing delay, and eventually locked out.
+// Attempts against unknown accounts take as long, and are throttled the same, as those against real ones.
+// Users with two-factor authentication enabled are given a challenge token instead of a session, to be exchanged with a
+// code via CompleteTwoFactorLogin.
+func (s *DateService) Login(ctx context.Context, email string, password string, clientIP string) (LoginResult, error) {
+	subjects := s.loginThrottleSubjects(email, clientIP)
+	err := s.checkLoginThrottle(ctx, subjects)
+	if err != nil {
+		return LoginResult{}, err
+	}
+
 	user, err := s.repo.GetUserByEmail(ctx, email)
 	if err != nil {
-		return "", fmt.Errorf("get user password hash: %w", err)
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return LoginResult{}, fmt.Errorf("get user password hash: %w", err)
+		}
+		// Spend the same effort as a real comparison before failing.
+		_, _ = s.cfg.PasswordHasher.Verify(s.dummyHash, password)
+		s.recordLoginFailure(ctx, subjects)
+		return LoginResult{}, fmt.Errorf("get user password hash: %w", err)
+	}
+
+	match, err := s.cfg.PasswordHasher.Verify(user.Password, password)
+	if err != nil {
+		return LoginResult{}, fmt.Errorf("compare hash and password: %w", err)
+	}
+	if !match {
+		s.recordLoginFailure(ctx, subjects)
+		return LoginResult{}, ErrIncorrectPassword
+	}
+	s.recordLoginSuccess(ctx, subjects)
+
+	// This is the only time the plaintext password is available, so take the chance to upgrade a weak hash.
+	if s.cfg.PasswordHasher.NeedsRehash(user.Password) {
+		s.rehashPassword(ctx, user.ID, password)
+	}
+
+	// Standing is checked only after the password, so the response doesn't reveal account state to a guesser.
+	err = checkAccountStanding(user)
+	if err != nil {
+		return LoginResult{}, err
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	challenge, err := s.startTwoFactorChallenge(ctx, user)
 	if err != nil {
-		return "", fmt.Errorf("compare hash and password: %w", err)
+		return LoginResult{}, err
+	}
+	if challenge != "" {
+		return LoginResult{TwoFactorRequired: true, ChallengeToken: challenge}, nil
+	}
+
+	token, err := s.createSession(ctx, user)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	return LoginResult{Token: token}, nil
+}
+
+// createSession issues a new session token for a user who has fully authenticated.
+func (s *DateService) createSession(ctx context.Context, user repository.User) (string, error) {
+	if s.cfg.SessionMode == SessionModeSigned {
+		return s.issueAccessToken(user)
 	}
 
 	sessionTokenSize := 32
@@ -89,6 +385,7 @@ func (s *DateService) Login(ctx context.Context, email string, password string)
 	userSession := repository.Session{
 		UserID:    user.ID,
 		Token:     st,
+		Role:      user.Role,
 		CreatedAt: now,
 		ExpiresAt: now.Add(time.Hour * 24),
 	}
@@ -101,6 +398,23 @@ func (s *DateService) Login(ctx context.Context, email string, password string)
 	return userSession.Token, nil
 }
 
+// rehashPassword replaces a user's stored hash with one made under the current settings. Failure is only logged, as the
+// old hash still works.
+func (s *DateService) rehashPassword(ctx context.Context, userID int, password string) {
+	h, err := s.cfg.PasswordHasher.Hash(password)
+	if err != nil {
+		s.logger.Error("rehash password", "user_id", userID, "err", err)
+		return
+	}
+
+	err = s.repo.UpdateUser(ctx, userID, map[string]interface{}{"password": h})
+	if err != nil {
+		s.logger.Error("store rehashed password", "user_id", userID, "err", err)
+		return
+	}
+	s.logger.Info("upgraded password hash", "user_id", userID, "algorithm", s.cfg.PasswordHasher.Algorithm)
+}
+
 // Discover returns a collection of profiles that have been ranked and matched against the logged-in user. The intention
 // is that these are presented to the user and subsequently "swiped", "yes" or "no" by the user.
 // The returned results are ranked in decreasing order and some sensitive information has been removed for privacy reasons.
@@ -114,15 +428,40 @@ func (s *DateService) Discover(ctx context.Context, userID int) (rankingservice.
 		return rankingservice.RankedResultSet{}, fmt.Errorf("find user by d in repo: %w", err)
 	}
 
-	candidateMatches, err := s.repo.GetUnratedUsers(ctx, userID)
+	candidateMatches, err := s.repo.GetUnratedUsers(ctx, userID, s.passesExpireBefore(time.Now()))
 	if err != nil {
 		return rankingservice.RankedResultSet{}, fmt.Errorf("discover candidateMatches in repo: %w", err)
 	}
 
+	err = s.attachProfileDetails(ctx, candidateMatches)
+	if err != nil {
+		return rankingservice.RankedResultSet{}, fmt.Errorf("load candidate profile details: %w", err)
+	}
+	currentUserDetails := []repository.User{currentUser}
+	err = s.attachProfileDetails(ctx, currentUserDetails)
+	if err != nil {
+		return rankingservice.RankedResultSet{}, fmt.Errorf("load user profile details: %w", err)
+	}
+	currentUser = currentUserDetails[0]
+
 	userPrefs, err := s.repo.GetUserPreferences(ctx, sessionUserID)
 	if err != nil {
 		return rankingservice.RankedResultSet{}, fmt.Errorf("get user preferences from repo: %w", err)
 	}
+	candidateIDs := make([]int, 0, len(candidateMatches))
+	for _, cand := range candidateMatches {
+		candidateIDs = append(candidateIDs, cand.ID)
+	}
+	superLikers, err := s.repo.GetSuperLikers(ctx, sessionUserID, candidateIDs)
+	if err != nil {
+		return rankingservice.RankedResultSet{}, err
+	}
+	now := time.Now()
+	boosted, err := s.repo.GetBoostedUsers(ctx, candidateIDs, now)
+	if err != nil {
+		return rankingservice.RankedResultSet{}, err
+	}
+
 	rankedMatches := rankingservice.NewRankedResultSet()
 
 	for _, cand := range candidateMatches {
@@ -133,54 +472,166 @@ func (s *DateService) Discover(ctx context.Context, userID int) (rankingservice.
 
 		score, err := currentUser.RankCandidate(cand, userPrefs, canPrefs)
 		if err != nil {
-			s.logger.Error("error ranking user (%d) with candidate (%d): %w", currentUser.ID, cand.ID, err)
+			s.logger.Error("error ranking user with candidate", "user_id", currentUser.ID, "candidate_id", cand.ID, "err", err)
 		}
 
 		if score == -1 {
 			// Don't add candidate to results
 			continue
 		}
+		// Super likes lift a candidate who is otherwise suitable, but never override a mismatch.
+		if superLikers[cand.ID] {
+			score += superLikeRankingBoost
+		}
+		score = rankingservice.ApplyBoost(score, boosted[cand.ID])
 
-		candidateDistance := currentUser.DistanceFromUser(cand)
+		candidateDistance := s.shownDistance(currentUser.DistanceFromUser(cand), currentUser.ID, cand.ID)
+		city := s.nearestCity(cand)
+		sharedInterests := currentUser.SharedInterests(cand)
 		cand.Age = cand.CalculateAge()
 		cand.MaskPrivateFields()
 
 		rankedMatch := rankingservice.RankedMatch{
-			User:           cand,
-			Ranking:        score,
-			DistanceFromMe: candidateDistance,
+			User:            cand,
+			Ranking:         score,
+			DistanceFromMe:  candidateDistance,
+			SharedInterests: sharedInterests,
+			SuperLikedMe:    superLikers[cand.ID],
+			City:            city,
 		}
 
 		rankedMatches.AddMatch(rankedMatch)
 	}
 
+	// Impressions feed boost analytics, which aren't worth failing discovery over.
+	shownIDs := make([]int, 0, len(rankedMatches.Matches))
+	for _, match := range rankedMatches.Matches {
+		shownIDs = append(shownIDs, match.ID)
+	}
+	err = s.repo.RecordImpressions(ctx, shownIDs, now)
+	if err != nil {
+		s.logger.Error("record discover impressions", "user_id", sessionUserID, "err", err)
+	}
+
 	return rankedMatches, nil
 }
 
+// SwipeResult is the outcome of a swipe.
+type SwipeResult struct {
+	Matched bool
+	// LikesRemaining is how many likes the user has left today. Nil for premium users, who are unlimited, and for
+	// super likes.
+	LikesRemaining *int
+	// SuperLikesRemaining is how many super likes the user has left today. Only set for super likes.
+	SuperLikesRemaining *int
+}
+
 // Swipe enables a user to specify if they like a discovered profile or not.
-func (s *DateService) Swipe(ctx context.Context, swipeMessage repository.Swipe) (bool, error) {
-	err := s.repo.SubmitSwipe(ctx, swipeMessage)
+// Users must have verified their email address before they can swipe. Free users may only like FreeDailyLikes profiles
+// per day, while passes are unlimited. Super likes have a separate daily quota on every plan.
+func (s *DateService) Swipe(ctx context.Context, swipeMessage repository.Swipe) (SwipeResult, error) {
+	if swipeMessage.SuperLike && !swipeMessage.Likes {
+		return SwipeResult{}, ErrInvalidSwipe
+	}
+
+	user, err := s.repo.GetUserByID(ctx, swipeMessage.UserID)
+	if err != nil {
+		return SwipeResult{}, fmt.Errorf("get user from repo: %w", err)
+	}
+	if !user.EmailVerified {
+		return SwipeResult{}, ErrEmailNotVerified
+	}
+
+	result := SwipeResult{}
+	now := time.Now()
+	passesExpireBefore := s.passesExpireBefore(now)
+	limit, limited := s.swipeLimit(user, swipeMessage, now)
+	if !limited {
+		err = s.repo.SubmitSwipe(ctx, swipeMessage, passesExpireBefore)
+	} else {
+		dayStart, dayEnd := user.LocalDay(now)
+		var used int
+		used, err = s.repo.SubmitSwipeWithinQuota(ctx, swipeMessage, dayStart, limit, passesExpireBefore)
+		if errors.Is(err, repository.ErrQuotaExceeded) {
+			return SwipeResult{}, &LikeQuotaError{ResetsAt: dayEnd, SuperLike: swipeMessage.SuperLike}
+		}
+		remaining := max(limit-used, 0)
+		if swipeMessage.SuperLike {
+			result.SuperLikesRemaining = &remaining
+		} else {
+			result.LikesRemaining = &remaining
+		}
+	}
 	if err != nil {
-		if errors.As(gorm.ErrDuplicatedKey, &err) {
-			return false, ErrDuplicateSwipe
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return SwipeResult{}, ErrDuplicateSwipe
 		}
-		return false, fmt.Errorf("submit swipe to repo: %w", err)
+		return SwipeResult{}, fmt.Errorf("submit swipe to repo: %w", err)
 	}
 
-	match, err := s.repo.IsUserMatch(ctx, swipeMessage.UserID, swipeMessage.CandidateID)
+	result.Matched, err = s.repo.IsUserMatch(ctx, swipeMessage.UserID, swipeMessage.CandidateID)
 	if err != nil {
-		return false, fmt.Errorf("check for user match: %w", err)
+		return SwipeResult{}, fmt.Errorf("check for user match: %w", err)
 	}
 
-	return match, nil
+	return result, nil
 }
 
-// AuthenticateUserToken verifies the tokens created during calls to Login. If the token is valid, the linked users ID is
-// returned.
-func (s *DateService) AuthenticateUserToken(ctx context.Context, token string) (int, error) {
-	u, err := s.repo.GetUserFromAuthToken(ctx, token)
+// SessionIdentity describes who an authenticated request is acting as.
+type SessionIdentity struct {
+	// SessionID is set in the database session mode, and TokenID in the signed mode.
+	SessionID int
+	TokenID   string
+	UserID    int
+	Role      string
+}
+
+// AuthenticateUserToken verifies the tokens created during calls to Login. If the token is valid, the linked user and
+// the role granted to the session are returned. Suspended or banned users are rejected even if they hold a valid token.
+// In the signed session mode this is done without a DB lookup.
+func (s *DateService) AuthenticateUserToken(ctx context.Context, token string) (SessionIdentity, error) {
+	if s.cfg.SessionMode == SessionModeSigned {
+		return s.authenticateAccessToken(token)
+	}
+
+	session, err := s.repo.GetSessionByToken(ctx, token)
+	if err != nil {
+		return SessionIdentity{}, fmt.Errorf("get session from auth token: %w", err)
+	}
+
+	u, err := s.repo.GetUserByID(ctx, session.UserID)
 	if err != nil {
-		return 0, fmt.Errorf("get user from auth token: %w", err)
+		return SessionIdentity{}, fmt.Errorf("get user for session: %w", err)
 	}
-	return u.ID, nil
+
+	err = checkAccountStanding(u)
+	if err != nil {
+		return SessionIdentity{}, err
+	}
+	return SessionIdentity{SessionID: session.ID, UserID: u.ID, Role: session.Role}, nil
+}
+
+// SetUserRole changes the role of the user with the given email. Existing sessions are revoked so the new role takes
+// effect, and can't be outlived by a stale one, on the user's next login. actorID is 0 when called from the CLI.
+func (s *DateService) SetUserRole(ctx context.Context, actorID int, email string, role string) error {
+	if !IsValidRole(role) {
+		return fmt.Errorf("unknown role %q", role)
+	}
+
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("find user: %w", err)
+	}
+
+	err = s.repo.SetUserRole(ctx, user.ID, role)
+	if err != nil {
+		return fmt.Errorf("set role in repo: %w", err)
+	}
+
+	err = s.revokeUserSessions(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	return s.audit(ctx, actorID, "set_role", &user.ID, nil, fmt.Sprintf("%s -> %s", user.Role, role))
 }
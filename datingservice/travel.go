@@ -0,0 +1,82 @@
+package datingservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chackett/dating-service/repository"
+	"time"
+)
+
+// maxTravelDuration is the longest a travel location may be set for.
+const maxTravelDuration = 90 * 24 * time.Hour
+
+var (
+	ErrPremiumRequired = errors.New("premium plan required")
+	ErrInvalidTravel   = errors.New("invalid travel plan")
+)
+
+// TravelPlan is a location a user will be at between two times.
+type TravelPlan struct {
+	Location string `json:"location"`
+	// StartsAt defaults to now.
+	StartsAt *time.Time `json:"startsAt"`
+	EndsAt   time.Time  `json:"endsAt"`
+}
+
+// SetTravel sets a premium user's travel location, replacing any they already had. Between the plan's start and end,
+// discover treats the user as being at that location. Afterwards, their usual location applies again without further
+// action.
+func (s *DateService) SetTravel(ctx context.Context, userID int, plan TravelPlan) (repository.User, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return repository.User{}, fmt.Errorf("get user from repo: %w", err)
+	}
+	now := time.Now()
+	if !user.HasPremium(now) {
+		return repository.User{}, ErrPremiumRequired
+	}
+
+	location, err := s.storedLocation(plan.Location)
+	if err != nil {
+		return repository.User{}, fmt.Errorf("%w: %s", ErrInvalidTravel, err)
+	}
+	startsAt := now
+	if plan.StartsAt != nil {
+		startsAt = *plan.StartsAt
+	}
+	switch {
+	case !plan.EndsAt.After(now):
+		return repository.User{}, fmt.Errorf("%w: end must be in the future", ErrInvalidTravel)
+	case !plan.EndsAt.After(startsAt):
+		return repository.User{}, fmt.Errorf("%w: end must be after start", ErrInvalidTravel)
+	case plan.EndsAt.Sub(now) > maxTravelDuration:
+		return repository.User{}, fmt.Errorf("%w: end can be at most %d days away", ErrInvalidTravel,
+			int(maxTravelDuration.Hours()/24))
+	}
+
+	err = s.checkLocationUpdate(user, now)
+	if err != nil {
+		return repository.User{}, err
+	}
+
+	err = s.repo.UpdateUser(ctx, userID, map[string]interface{}{
+		"travel_location":     location,
+		"travel_starts_at":    startsAt,
+		"travel_ends_at":      plan.EndsAt,
+		"location_updated_at": now,
+	})
+	if err != nil {
+		return repository.User{}, err
+	}
+	return s.GetProfile(ctx, userID)
+}
+
+// ClearTravel removes a user's travel location, so their usual location applies straight away.
+func (s *DateService) ClearTravel(ctx context.Context, userID int) error {
+	return s.repo.UpdateUser(ctx, userID, map[string]interface{}{
+		"travel_location":  nil,
+		"travel_starts_at": nil,
+		"travel_ends_at":   nil,
+	})
+}
@@ -0,0 +1,235 @@
+package datingservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chackett/dating-service/pkg/security"
+	"github.com/chackett/dating-service/repository"
+	"time"
+)
+
+const (
+	loginChallengeTTL = 5 * time.Minute
+	recoveryCodeCount = 10
+)
+
+var (
+	ErrTwoFactorUnavailable    = errors.New("two-factor authentication is not configured")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnrolled    = errors.New("two-factor authentication enrolment not started")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+)
+
+// TwoFactorEnrollment is handed to the user to add the secret to their authenticator app.
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	// URI is an otpauth:// URI, typically rendered as a QR code.
+	URI string `json:"uri"`
+}
+
+// EnrollTwoFactor starts TOTP enrolment, generating a new secret. It has no effect on login until confirmed, and calling
+// it again before confirming replaces the secret.
+func (s *DateService) EnrollTwoFactor(ctx context.Context, userID int) (TwoFactorEnrollment, error) {
+	if s.totpBox == nil {
+		return TwoFactorEnrollment{}, ErrTwoFactorUnavailable
+	}
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return TwoFactorEnrollment{}, fmt.Errorf("get user from repo: %w", err)
+	}
+
+	existing, err := s.repo.GetUserTOTP(ctx, userID)
+	if err != nil {
+		return TwoFactorEnrollment{}, fmt.Errorf("get totp from repo: %w", err)
+	}
+	if existing != nil && existing.ConfirmedAt != nil {
+		return TwoFactorEnrollment{}, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := security.GenerateTOTPSecret()
+	if err != nil {
+		return TwoFactorEnrollment{}, err
+	}
+	sealed, err := s.totpBox.Seal(secret)
+	if err != nil {
+		return TwoFactorEnrollment{}, fmt.Errorf("encrypt totp secret: %w", err)
+	}
+
+	err = s.repo.UpsertUserTOTP(ctx, userID, sealed)
+	if err != nil {
+		return TwoFactorEnrollment{}, fmt.Errorf("store totp in repo: %w", err)
+	}
+
+	return TwoFactorEnrollment{
+		Secret: secret,
+		URI:    security.TOTPURI(s.cfg.TOTPIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor completes enrolment with a code from the authenticator app, proving it was set up correctly. The
+// returned recovery codes are shown to the user once and never again.
+func (s *DateService) ConfirmTwoFactor(ctx context.Context, userID int, code string) ([]string, error) {
+	if s.totpBox == nil {
+		return nil, ErrTwoFactorUnavailable
+	}
+
+	totp, err := s.repo.GetUserTOTP(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get totp from repo: %w", err)
+	}
+	if totp == nil {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	if totp.ConfirmedAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := s.totpBox.Open(totp.SecretEncrypted)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt totp secret: %w", err)
+	}
+	step, ok := security.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		c, err := security.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, c)
+		hashes = append(hashes, security.HashToken(c))
+	}
+
+	err = s.repo.ConfirmUserTOTP(ctx, userID, step, hashes)
+	if err != nil {
+		return nil, fmt.Errorf("confirm totp in repo: %w", err)
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor turns off two-factor authentication, which requires a current TOTP or recovery code.
+func (s *DateService) DisableTwoFactor(ctx context.Context, userID int, code string) error {
+	ok, err := s.verifyTwoFactorCode(ctx, userID, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	err = s.repo.DeleteUserTOTP(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("delete totp in repo: %w", err)
+	}
+	return nil
+}
+
+// CompleteTwoFactorLogin exchanges the challenge token from Login and a TOTP or recovery code for a session token.
+// Wrong codes count as failed logins, so they are throttled in the same way as wrong passwords.
+func (s *DateService) CompleteTwoFactorLogin(ctx context.Context, challengeToken string, code string, clientIP string) (string, error) {
+	challengeHash := security.HashToken(challengeToken)
+	ut, err := s.repo.GetUserToken(ctx, repository.TokenPurposeLoginChallenge, challengeHash)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	user, err := s.repo.GetUserByID(ctx, ut.UserID)
+	if err != nil {
+		return "", fmt.Errorf("get user from repo: %w", err)
+	}
+
+	subjects := s.loginThrottleSubjects(user.Email, clientIP)
+	err = s.checkLoginThrottle(ctx, subjects)
+	if err != nil {
+		return "", err
+	}
+
+	ok, err := s.verifyTwoFactorCode(ctx, user.ID, code)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		s.recordLoginFailure(ctx, subjects)
+		return "", ErrInvalidTwoFactorCode
+	}
+
+	// Consuming can only succeed once, which guards against the same challenge being completed twice concurrently.
+	_, err = s.repo.ConsumeUserToken(ctx, repository.TokenPurposeLoginChallenge, challengeHash)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	s.recordLoginSuccess(ctx, subjects)
+
+	// Re-check, as the account may have been actioned since the password step.
+	err = checkAccountStanding(user)
+	if err != nil {
+		return "", err
+	}
+
+	return s.createSession(ctx, user)
+}
+
+// startTwoFactorChallenge issues a challenge token if the user has two-factor authentication enabled. An empty token
+// means none is needed.
+func (s *DateService) startTwoFactorChallenge(ctx context.Context, user repository.User) (string, error) {
+	totp, err := s.repo.GetUserTOTP(ctx, user.ID)
+	if err != nil {
+		return "", fmt.Errorf("get totp from repo: %w", err)
+	}
+	if totp == nil || totp.ConfirmedAt == nil {
+		return "", nil
+	}
+
+	token, err := security.CreateSecureSessionToken(32)
+	if err != nil {
+		return "", fmt.Errorf("create challenge token: %w", err)
+	}
+
+	err = s.repo.CreateUserToken(ctx, &repository.UserToken{
+		UserID:    user.ID,
+		Purpose:   repository.TokenPurposeLoginChallenge,
+		TokenHash: security.HashToken(token),
+		Email:     user.Email,
+		ExpiresAt: time.Now().Add(loginChallengeTTL),
+	})
+	if err != nil {
+		return "", fmt.Errorf("store challenge token: %w", err)
+	}
+	return token, nil
+}
+
+// verifyTwoFactorCode checks a code against the user's confirmed enrolment. Six digit codes are treated as TOTP, and
+// can't be reused; anything else is tried as a recovery code, which is used up.
+func (s *DateService) verifyTwoFactorCode(ctx context.Context, userID int, code string) (bool, error) {
+	if s.totpBox == nil {
+		return false, ErrTwoFactorUnavailable
+	}
+
+	totp, err := s.repo.GetUserTOTP(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("get totp from repo: %w", err)
+	}
+	if totp == nil || totp.ConfirmedAt == nil {
+		return false, ErrTwoFactorNotEnrolled
+	}
+
+	if len(code) == 6 {
+		secret, err := s.totpBox.Open(totp.SecretEncrypted)
+		if err != nil {
+			return false, fmt.Errorf("decrypt totp secret: %w", err)
+		}
+		step, ok := security.ValidateTOTP(secret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		return s.repo.AdvanceTOTPStep(ctx, userID, step)
+	}
+
+	return s.repo.UseRecoveryCode(ctx, userID, security.HashToken(security.NormaliseRecoveryCode(code)))
+}
@@ -0,0 +1,92 @@
+package datingservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chackett/dating-service/repository"
+	"time"
+)
+
+// maxNotifications is how many notifications are returned at once.
+const maxNotifications = 50
+
+var (
+	ErrNothingToUndo     = errors.New("no recent swipe to undo")
+	ErrUndoQuotaExceeded = errors.New("daily undo quota used up")
+)
+
+// UndoQuotaError is returned when a user has no undos left today. ResetsAt is the start of their next day.
+type UndoQuotaError struct {
+	ResetsAt time.Time
+}
+
+func (e *UndoQuotaError) Error() string {
+	return fmt.Sprintf("%s, resets at %s", ErrUndoQuotaExceeded, e.ResetsAt.Format(time.RFC3339))
+}
+
+func (e *UndoQuotaError) Is(target error) bool {
+	return target == ErrUndoQuotaExceeded
+}
+
+// UndoResult describes a swipe which was undone.
+type UndoResult struct {
+	CandidateID int  `json:"candidateId"`
+	Likes       bool `json:"likes"`
+	SuperLike   bool `json:"superLike,omitempty"`
+	// Unmatched is set if the swipe had made a match, which has now ended.
+	Unmatched bool `json:"unmatched"`
+	// UndosRemaining is how many undos the user has left today. Nil for premium users, who are unlimited.
+	UndosRemaining *int `json:"undosRemaining,omitempty"`
+}
+
+// UndoSwipe reverts the user's most recent swipe, so long as it was made within UndoWindow. Free users may undo
+// FreeDailyUndos swipes per day. If the swipe had made a match, the match ends and the other user is notified.
+// The candidate is free to be swiped on again, and a like or super like undone is given back to the daily quota.
+func (s *DateService) UndoSwipe(ctx context.Context, userID int) (UndoResult, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return UndoResult{}, fmt.Errorf("get user from repo: %w", err)
+	}
+
+	now := time.Now()
+	dayStart, dayEnd := user.LocalDay(now)
+	limit := s.cfg.FreeDailyUndos
+	premium := user.HasPremium(now)
+	if premium {
+		limit = -1
+	}
+
+	undone, err := s.repo.UndoLatestSwipe(ctx, userID, now.Add(-s.cfg.UndoWindow), dayStart, limit)
+	if errors.Is(err, repository.ErrNothingToUndo) {
+		return UndoResult{}, ErrNothingToUndo
+	}
+	if errors.Is(err, repository.ErrQuotaExceeded) {
+		return UndoResult{}, &UndoQuotaError{ResetsAt: dayEnd}
+	}
+	if err != nil {
+		return UndoResult{}, err
+	}
+
+	result := UndoResult{
+		CandidateID: undone.Swipe.CandidateID,
+		Likes:       undone.Swipe.Likes,
+		SuperLike:   undone.Swipe.SuperLike,
+		Unmatched:   undone.Unmatched,
+	}
+	if !premium {
+		remaining := max(limit-undone.Used, 0)
+		result.UndosRemaining = &remaining
+	}
+	return result, nil
+}
+
+// GetNotifications returns the user's most recent notifications, newest first.
+func (s *DateService) GetNotifications(ctx context.Context, userID int) ([]repository.Notification, error) {
+	return s.repo.GetNotifications(ctx, userID, maxNotifications)
+}
+
+// MarkNotificationsRead marks all the user's notifications as read.
+func (s *DateService) MarkNotificationsRead(ctx context.Context, userID int) error {
+	return s.repo.MarkNotificationsRead(ctx, userID)
+}
@@ -0,0 +1,89 @@
+package datingservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chackett/dating-service/pkg/mailer"
+	"github.com/chackett/dating-service/pkg/security"
+	"github.com/chackett/dating-service/repository"
+	"time"
+)
+
+const verificationTokenTTL = 24 * time.Hour
+
+var (
+	ErrEmailNotVerified = errors.New("email address not verified")
+	ErrInvalidToken     = errors.New("invalid or expired token")
+)
+
+// VerifyEmail consumes a verification token, marking the address it was sent to as verified.
+func (s *DateService) VerifyEmail(ctx context.Context, token string) error {
+	ut, err := s.repo.ConsumeUserToken(ctx, repository.TokenPurposeEmailVerification, security.HashToken(token))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	user, err := s.repo.GetUserByID(ctx, ut.UserID)
+	if err != nil {
+		return fmt.Errorf("get user from repo: %w", err)
+	}
+
+	// The address may have changed again since the token was sent, in which case it verifies nothing.
+	if user.Email != ut.Email {
+		return ErrInvalidToken
+	}
+
+	err = s.repo.UpdateUser(ctx, user.ID, map[string]interface{}{"email_verified": true})
+	if err != nil {
+		return fmt.Errorf("mark email verified in repo: %w", err)
+	}
+	return nil
+}
+
+// ResendVerification sends a fresh verification token to the user, invalidating any sent previously.
+func (s *DateService) ResendVerification(ctx context.Context, userID int) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user from repo: %w", err)
+	}
+	if user.EmailVerified {
+		return nil
+	}
+
+	return s.sendVerificationEmail(ctx, user)
+}
+
+func (s *DateService) sendVerificationEmail(ctx context.Context, user repository.User) error {
+	err := s.repo.InvalidateUserTokens(ctx, user.ID, repository.TokenPurposeEmailVerification)
+	if err != nil {
+		return fmt.Errorf("invalidate previous tokens: %w", err)
+	}
+
+	token, err := security.CreateSecureSessionToken(32)
+	if err != nil {
+		return fmt.Errorf("create verification token: %w", err)
+	}
+
+	err = s.repo.CreateUserToken(ctx, &repository.UserToken{
+		UserID:    user.ID,
+		Purpose:   repository.TokenPurposeEmailVerification,
+		TokenHash: security.HashToken(token),
+		Email:     user.Email,
+		ExpiresAt: time.Now().Add(verificationTokenTTL),
+	})
+	if err != nil {
+		return fmt.Errorf("store verification token: %w", err)
+	}
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by submitting the code below to /user/verify. "+
+			"It expires in %s.\n\n%s\n", user.Name, verificationTokenTTL, token),
+	})
+	if err != nil {
+		return fmt.Errorf("send verification email: %w", err)
+	}
+	return nil
+}
@@ -4,16 +4,23 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/chackett/dating-service/datingservice"
+	"github.com/chackett/dating-service/pkg/ratelimit"
 	"github.com/chackett/dating-service/rankingservice"
 	"github.com/chackett/dating-service/repository"
+	"gorm.io/gorm"
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 )
 
 const (
 	maxRequestBodySizeBytes = 1048576
 	ctxKeySessionUserID     = "session_user_id"
+	ctxKeySessionRole       = "session_role"
+	ctxKeySessionID         = "session_id"
+	ctxKeySessionTokenID    = "session_token_id"
 )
 
 // handler defines functionality for exposing routes via HTTP and also parsing the messages before passing onto the relevant
@@ -22,49 +29,327 @@ type handler struct {
 	dateService *datingservice.DateService
 	logger      *slog.Logger
 	mux         http.Handler
-	routes      map[string]routeConfig
+	// routeMux is the inner mux holding the routes, used by middleware to resolve a request to its route pattern.
+	routeMux *http.ServeMux
+	routes   map[string]routeConfig
+	// mediaDir holds locally stored photos to serve, if any.
+	mediaDir string
+	// limiter keeps the buckets for routes with a rate limit.
+	limiter ratelimit.Store
 }
 
-// routeConfig stores an HTTP route and any config related to it. i.e. Authenticate it or not.
-// For a basic ACL you could add access level here.
+// routeConfig stores an HTTP route and any config related to it. i.e. Authenticate it or not, and which permissions the
+// session's role must hold to call it.
 type routeConfig struct {
 	authUser bool
-	handler  func(http.ResponseWriter, *http.Request)
+	// permissions are all required of the session's role. Only checked when authUser is set.
+	permissions []datingservice.Permission
+	// rateLimit, if set, limits requests per session user, or per client IP for unauthenticated requests.
+	rateLimit *ratelimit.Limit
+	handler   func(http.ResponseWriter, *http.Request)
 }
 
+// Rate limits shared between routes.
+var (
+	// rateLimitAuth covers routes guessing credentials or sending email, which need to be tight.
+	rateLimitAuth = &ratelimit.Limit{Rate: 10, Per: time.Minute, Burst: 5}
+	// rateLimitSignup is for account creation, which nobody needs to do often.
+	rateLimitSignup = &ratelimit.Limit{Rate: 5, Per: time.Hour, Burst: 3}
+	// rateLimitBrowse is for browsing other users, generous for people but slowing scraping.
+	rateLimitBrowse = &ratelimit.Limit{Rate: 30, Per: time.Minute, Burst: 10}
+	// rateLimitSwipe allows quick swiping, but not automated mass swiping.
+	rateLimitSwipe = &ratelimit.Limit{Rate: 60, Per: time.Minute, Burst: 20}
+)
+
 // newHandler creates and initialises the handler/routes.
-func newHandler(ds *datingservice.DateService) (*handler, error) {
+func newHandler(ds *datingservice.DateService, mediaDir string, limiter ratelimit.Store) (*handler, error) {
 	if ds == nil {
 		return nil, errors.New("datingservice is nil")
 	}
+	if limiter == nil {
+		return nil, errors.New("rate limit store is nil")
+	}
 
 	result := &handler{
 		dateService: ds,
 		logger:      slog.New(slog.NewJSONHandler(os.Stdout, nil)),
+		mediaDir:    mediaDir,
+		limiter:     limiter,
 	}
 
 	result.routes = map[string]routeConfig{
 		"POST /user/create": {
-			authUser: false,
-			handler:  result.handlePOSTCreateUser,
+			authUser:  false,
+			rateLimit: rateLimitSignup,
+			handler:   result.handlePOSTCreateUser,
+		},
+		"POST /user/verify": {
+			authUser:  false,
+			rateLimit: rateLimitAuth,
+			handler:   result.handlePOSTVerifyEmail,
+		},
+		"POST /user/verify/resend": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			rateLimit:   rateLimitAuth,
+			handler:     result.handlePOSTResendVerification,
+		},
+		"GET /user/preferences": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handleGETUserPreferences,
 		},
 		"POST /user/preferences": {
-			authUser: true,
-			handler:  result.handlePOSTUserPreferences,
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handlePOSTUserPreferences,
 		},
 		"POST /login": {
-			authUser: false,
-			handler:  result.handlePOSTLogin,
+			authUser:  false,
+			rateLimit: rateLimitAuth,
+			handler:   result.handlePOSTLogin,
 		},
-		"GET /discover": {
+		"POST /logout": {
 			authUser: true,
-			handler:  result.handleGETDiscover,
+			handler:  result.handlePOSTLogout,
+		},
+		"POST /login/2fa": {
+			authUser:  false,
+			rateLimit: rateLimitAuth,
+			handler:   result.handlePOSTLoginTwoFactor,
+		},
+		"POST /me/2fa/enroll": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handlePOSTMeTwoFactorEnroll,
+		},
+		"POST /me/2fa/confirm": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handlePOSTMeTwoFactorConfirm,
+		},
+		"DELETE /me/2fa": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handleDELETEMeTwoFactor,
+		},
+		"GET /auth/oidc/{provider}": {
+			authUser:  false,
+			rateLimit: rateLimitAuth,
+			handler:   result.handleGETOIDCLogin,
+		},
+		"GET /auth/oidc/{provider}/callback": {
+			authUser:  false,
+			rateLimit: rateLimitAuth,
+			handler:   result.handleGETOIDCCallback,
+		},
+		"GET /me/identities": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handleGETMeIdentities,
+		},
+		"POST /me/identities/{provider}": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			rateLimit:   rateLimitAuth,
+			handler:     result.handlePOSTMeIdentity,
+		},
+		"POST /password/forgot": {
+			authUser:  false,
+			rateLimit: rateLimitAuth,
+			handler:   result.handlePOSTForgotPassword,
+		},
+		"POST /password/reset": {
+			authUser:  false,
+			rateLimit: rateLimitAuth,
+			handler:   result.handlePOSTResetPassword,
+		},
+		"POST /password/change": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			rateLimit:   rateLimitAuth,
+			handler:     result.handlePOSTChangePassword,
+		},
+		"GET /discover": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			rateLimit:   rateLimitBrowse,
+			handler:     result.handleGETDiscover,
 		},
 		"POST /swipe": {
-			authUser: true,
-			handler:  result.handlePOSTSwipe,
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			rateLimit:   rateLimitSwipe,
+			handler:     result.handlePOSTSwipe,
+		},
+		"GET /swipes": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handleGETSwipes,
+		},
+		"POST /swipe/undo": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			rateLimit:   rateLimitSwipe,
+			handler:     result.handlePOSTSwipeUndo,
+		},
+		"GET /likes/received": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			rateLimit:   rateLimitBrowse,
+			handler:     result.handleGETLikesReceived,
+		},
+		"GET /me": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handleGETMe,
+		},
+		"PATCH /me": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handlePATCHMe,
+		},
+		"GET /me/entitlements": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handleGETMeEntitlements,
+		},
+		"PUT /me/travel": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handlePUTMeTravel,
+		},
+		"DELETE /me/travel": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handleDELETEMeTravel,
+		},
+		"GET /me/boosts": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handleGETMeBoosts,
+		},
+		"POST /me/boosts": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handlePOSTMeBoosts,
+		},
+		"GET /me/notifications": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handleGETMeNotifications,
+		},
+		"POST /me/notifications/read": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handlePOSTMeNotificationsRead,
+		},
+		"GET /users/{id}": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			rateLimit:   rateLimitBrowse,
+			handler:     result.handleGETUser,
+		},
+		"GET /interests": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handleGETInterests,
+		},
+		// Genders are listed without a session, so they can be offered at signup.
+		"GET /genders": {
+			authUser: false,
+			handler:  result.handleGETGenders,
+		},
+		"GET /education-levels": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handleGETEducationLevels,
+		},
+		"GET /prompts": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handleGETPrompts,
+		},
+		"PUT /me/interests": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handlePUTMeInterests,
+		},
+		"PUT /me/prompts": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handlePUTMePrompts,
+		},
+		"POST /me/photos": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handlePOSTMePhotos,
+		},
+		"DELETE /me/photos/{id}": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handleDELETEMePhoto,
+		},
+		"PUT /me/photos/order": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			handler:     result.handlePUTMePhotosOrder,
+		},
+		"POST /report": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionProfile},
+			rateLimit:   rateLimitAuth,
+			handler:     result.handlePOSTReport,
+		},
+		"GET /admin/reports": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionReportsRead},
+			handler:     result.handleGETAdminReports,
+		},
+		"GET /admin/reports/{id}": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionReportsRead},
+			handler:     result.handleGETAdminReport,
+		},
+		"POST /admin/reports/{id}/dismiss": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionReportsResolve},
+			handler:     result.handlePOSTAdminDismissReport,
+		},
+		"GET /admin/lockouts": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionUsersManage},
+			handler:     result.handleGETAdminLockouts,
+		},
+		"DELETE /admin/lockouts/{id}": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionUsersManage},
+			handler:     result.handleDELETEAdminLockout,
+		},
+		"PUT /admin/users/{id}/plan": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionUsersManage},
+			handler:     result.handlePUTAdminUserPlan,
+		},
+		"POST /admin/users/{id}/boosts": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionUsersManage},
+			handler:     result.handlePOSTAdminUserBoosts,
+		},
+		"POST /admin/users/{id}/actions": {
+			authUser:    true,
+			permissions: []datingservice.Permission{datingservice.PermissionReportsResolve},
+			handler:     result.handlePOSTAdminUserAction,
 		},
 	}
+
+	if mediaDir != "" {
+		result.routes["GET /media/{path...}"] = routeConfig{
+			authUser: false,
+			handler:  result.handleGETMedia,
+		}
+	}
+
 	return result, nil
 }
 
@@ -73,39 +358,46 @@ func (h *handler) setupRoutes(middlewares []func(h http.Handler) http.Handler) {
 	mux := http.NewServeMux()
 
 	for rp, rc := range h.routes {
-		h.logger.Debug("set up route: %s", rp)
+		h.logger.Debug("set up route", "pattern", rp)
 		mux.HandleFunc(rp, rc.handler)
 	}
 
-	h.mux = http.NewServeMux()
+	h.routeMux = mux
 
-	for _, mw := range middlewares {
-		h.mux = mw(mux)
+	// Middlewares are listed outermost first, so are wrapped in reverse.
+	var next http.Handler = mux
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		next = middlewares[i](next)
 	}
+	h.mux = next
 }
 
 // handlePOSTCreateUser handles requests to create new user
 func (h *handler) handlePOSTCreateUser(w http.ResponseWriter, r *http.Request) {
-	u := repository.User{}
+	u := datingservice.NewUser{}
 
 	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySizeBytes)
 	err := json.NewDecoder(r.Body).Decode(&u)
 	if err != nil {
-		h.logger.Error("decode create user message: %w", err)
+		h.logger.Error("decode create user message", "err", err)
 		h.writePlainResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	createdUser, err := h.dateService.CreateUser(r.Context(), u)
 	if err != nil {
-		h.logger.Error("create user: %w", err)
+		h.logger.Error("create user", "err", err)
+		if errors.Is(err, datingservice.ErrInvalidProfile) {
+			h.writePlainResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		h.writePlainResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	btsUser, err := json.Marshal(createdUser)
 	if err != nil {
-		h.logger.Error("marshal created user: %w", err)
+		h.logger.Error("marshal created user", "err", err)
 		h.writePlainResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -113,6 +405,52 @@ func (h *handler) handlePOSTCreateUser(w http.ResponseWriter, r *http.Request) {
 	h.writePlainResponse(w, http.StatusCreated, string(btsUser))
 }
 
+// handlePOSTVerifyEmail handles requests to verify an email address with the token sent to it.
+func (h *handler) handlePOSTVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		Token string `json:"token"`
+	}{}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySizeBytes)
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		h.logger.Error("decode verify email message", "err", err)
+		h.writePlainResponse(w, http.StatusBadRequest, "unable to parse verification message")
+		return
+	}
+
+	err = h.dateService.VerifyEmail(r.Context(), input.Token)
+	if err != nil {
+		h.logger.Error("verify email", "err", err)
+		if errors.Is(err, datingservice.ErrInvalidToken) {
+			h.writePlainResponse(w, http.StatusBadRequest, datingservice.ErrInvalidToken.Error())
+			return
+		}
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	h.writePlainResponse(w, http.StatusOK, "")
+}
+
+// handlePOSTResendVerification handles requests from a logged-in user for a new verification email.
+func (h *handler) handlePOSTResendVerification(w http.ResponseWriter, r *http.Request) {
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	err := h.dateService.ResendVerification(r.Context(), sessionUserID)
+	if err != nil {
+		h.logger.Error("resend verification", "err", err)
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	h.writePlainResponse(w, http.StatusAccepted, "")
+}
+
 // handlePOSTUserPreferences handle request to set user preferences
 func (h *handler) handlePOSTUserPreferences(w http.ResponseWriter, r *http.Request) {
 	input := repository.UserPreferences{}
@@ -120,7 +458,7 @@ func (h *handler) handlePOSTUserPreferences(w http.ResponseWriter, r *http.Reque
 	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySizeBytes)
 	err := json.NewDecoder(r.Body).Decode(&input)
 	if err != nil {
-		h.logger.Error("decode create user preferences message: %w", err)
+		h.logger.Error("decode create user preferences message", "err", err)
 		h.writePlainResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -132,14 +470,18 @@ func (h *handler) handlePOSTUserPreferences(w http.ResponseWriter, r *http.Reque
 	}
 
 	if sessionUserID != input.UserID {
-		h.logger.Info("unauthorized attempt by user `%d` to update preferences for other user `%d`", sessionUserID, input.UserID)
+		h.logger.Info("unauthorized attempt to update preferences for other user", "session_user_id", sessionUserID, "user_id", input.UserID)
 		h.writePlainResponse(w, http.StatusBadRequest, "logged in user mismatch with preference request")
 		return
 	}
 
 	err = h.dateService.SetUserPreferences(r.Context(), input)
 	if err != nil {
-		h.logger.Error("create user preferences: %w", err)
+		h.logger.Error("create user preferences", "err", err)
+		if errors.Is(err, datingservice.ErrInvalidPreferences) {
+			h.writePlainResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		h.writePlainResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -147,6 +489,33 @@ func (h *handler) handlePOSTUserPreferences(w http.ResponseWriter, r *http.Reque
 	h.writePlainResponse(w, http.StatusCreated, "")
 }
 
+// handleGETUserPreferences returns the logged-in user's preferences.
+func (h *handler) handleGETUserPreferences(w http.ResponseWriter, r *http.Request) {
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	prefs, err := h.dateService.GetUserPreferences(r.Context(), sessionUserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			h.writePlainResponse(w, http.StatusNotFound, "no preferences set")
+			return
+		}
+		h.logger.Error("get user preferences", "err", err)
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	btsResp, err := json.Marshal(prefs)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+}
+
 // handlePOSTLogin handle requests to create authenticated session (i.e. Login)
 // Once this is successfully called with a valid username/password combination, then a token is returned which can be used
 // against subsequent authenticated HTTP calls.
@@ -160,27 +529,31 @@ func (h *handler) handlePOSTLogin(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, 1048576)
 	err := json.NewDecoder(r.Body).Decode(&input)
 	if err != nil {
-		h.logger.Error("decode login message", err)
+		h.logger.Error("decode login message", "err", err)
 		h.writePlainResponse(w, http.StatusUnauthorized, "incorrect email / password combination")
 		return
 	}
 
-	token, err := h.dateService.Login(r.Context(), input.Email, input.Password)
+	result, err := h.dateService.Login(r.Context(), input.Email, input.Password, clientIP(r))
 	if err != nil {
-		h.logger.Error("date service login attempt", err)
+		h.logger.Error("date service login attempt", "err", err)
+		throttled := &datingservice.LoginThrottledError{}
+		if errors.As(err, &throttled) {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(throttled.RetryAfter)))
+			h.writePlainResponse(w, http.StatusTooManyRequests, "too many failed login attempts, try again later")
+			return
+		}
+		if errors.Is(err, datingservice.ErrAccountSuspended) || errors.Is(err, datingservice.ErrAccountBanned) {
+			h.writePlainResponse(w, http.StatusForbidden, err.Error())
+			return
+		}
 		h.writePlainResponse(w, http.StatusUnauthorized, "incorrect email / password combination")
 		return
 	}
 
-	// Use anonymous struct type as this message type is not used elsewhere.
-	tokenResponse := struct {
-		Token string `json:"token"`
-	}{
-		Token: token,
-	}
-	btsResp, err := json.Marshal(tokenResponse)
+	btsResp, err := json.Marshal(result)
 	if err != nil {
-		h.logger.Error("marshal login token message to JSON", err)
+		h.logger.Error("marshal login token message to JSON", "err", err)
 		h.writePlainResponse(w, http.StatusUnauthorized, "incorrect email / password combination")
 		return
 	}
@@ -188,6 +561,24 @@ func (h *handler) handlePOSTLogin(w http.ResponseWriter, r *http.Request) {
 	h.writeJSONResponse(w, http.StatusAccepted, string(btsResp))
 }
 
+// handlePOSTLogout ends the session the request was made with.
+func (h *handler) handlePOSTLogout(w http.ResponseWriter, r *http.Request) {
+	identity, ok := sessionIdentityFromRequest(r)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	err := h.dateService.Logout(r.Context(), identity)
+	if err != nil {
+		h.logger.Error("logout", "err", err)
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	h.writePlainResponse(w, http.StatusNoContent, "")
+}
+
 // handleGETDiscover a handler for requests to discover matched candidates
 func (h *handler) handleGETDiscover(w http.ResponseWriter, r *http.Request) {
 	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
@@ -224,7 +615,7 @@ func (h *handler) handlePOSTSwipe(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, 1048576)
 	err := json.NewDecoder(r.Body).Decode(&input)
 	if err != nil {
-		h.logger.Error("decode swipe message", err)
+		h.logger.Error("decode swipe message", "err", err)
 		h.writePlainResponse(w, http.StatusBadRequest, "unable to parse swipe message")
 		return
 	}
@@ -236,17 +627,31 @@ func (h *handler) handlePOSTSwipe(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if sessionUserID != input.UserID {
-		h.logger.Info("unauthorized swipe attempt by user `%d` for other user `%d`", sessionUserID, input.CandidateID)
+		h.logger.Info("unauthorized swipe attempt for other user", "session_user_id", sessionUserID, "user_id", input.UserID)
 		h.writePlainResponse(w, http.StatusBadRequest, "logged in user mismatch with swipe message")
 		return
 	}
 
-	match, err := h.dateService.Swipe(r.Context(), input)
+	swipeResult, err := h.dateService.Swipe(r.Context(), input)
 	if err != nil {
-		if errors.As(datingservice.ErrDuplicateSwipe, &err) {
+		quota := &datingservice.LikeQuotaError{}
+		if errors.As(err, &quota) {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(time.Until(quota.ResetsAt))))
+			message := datingservice.ErrLikeQuotaExceeded.Error()
+			if quota.SuperLike {
+				message = datingservice.ErrSuperLikeQuotaExceeded.Error()
+			}
+			h.writePlainResponse(w, http.StatusTooManyRequests, message)
+			return
+		}
+		if errors.Is(err, datingservice.ErrDuplicateSwipe) || errors.Is(err, datingservice.ErrInvalidSwipe) {
 			h.writePlainResponse(w, http.StatusBadRequest, err.Error())
 			return
 		}
+		if errors.Is(err, datingservice.ErrEmailNotVerified) {
+			h.writePlainResponse(w, http.StatusForbidden, err.Error())
+			return
+		}
 		h.writePlainResponse(w, http.StatusInternalServerError, "unable to submit swipe message")
 		return
 	}
@@ -255,10 +660,13 @@ func (h *handler) handlePOSTSwipe(w http.ResponseWriter, r *http.Request) {
 	type subResults struct {
 		Matched bool `json:"matched"`
 		MatchID int  `json:"matchID,omitempty"`
+		// LikesRemaining is left out for premium users, who have no quota.
+		LikesRemaining      *int `json:"likesRemaining,omitempty"`
+		SuperLikesRemaining *int `json:"superLikesRemaining,omitempty"`
 	}
 
 	var matchedCandidateID int
-	if match {
+	if swipeResult.Matched {
 		matchedCandidateID = input.CandidateID
 	}
 
@@ -266,8 +674,10 @@ func (h *handler) handlePOSTSwipe(w http.ResponseWriter, r *http.Request) {
 		Results subResults `json:"results"`
 	}{
 		Results: subResults{
-			Matched: match,
-			MatchID: matchedCandidateID,
+			Matched:             swipeResult.Matched,
+			MatchID:             matchedCandidateID,
+			LikesRemaining:      swipeResult.LikesRemaining,
+			SuperLikesRemaining: swipeResult.SuperLikesRemaining,
 		},
 	}
 
@@ -279,13 +689,26 @@ func (h *handler) handlePOSTSwipe(w http.ResponseWriter, r *http.Request) {
 	h.writeJSONResponse(w, http.StatusCreated, string(btsResult))
 }
 
+// sessionIdentityFromRequest reads the identity placed into the request context by middlewareAuth.
+func sessionIdentityFromRequest(r *http.Request) (datingservice.SessionIdentity, bool) {
+	userID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		return datingservice.SessionIdentity{}, false
+	}
+	sessionID, _ := r.Context().Value(ctxKeySessionID).(int)
+	tokenID, _ := r.Context().Value(ctxKeySessionTokenID).(string)
+	role, _ := r.Context().Value(ctxKeySessionRole).(string)
+
+	return datingservice.SessionIdentity{SessionID: sessionID, TokenID: tokenID, UserID: userID, Role: role}, true
+}
+
 // writeJSONResponse a helper function to reduce duplicated code to return a JSON message.
 func (h *handler) writeJSONResponse(w http.ResponseWriter, statusCode int, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 	_, err := w.Write([]byte(message))
 	if err != nil {
-		h.logger.Error("unable to write http response", err)
+		h.logger.Error("unable to write http response", "err", err)
 	}
 }
 
@@ -295,6 +718,6 @@ func (h *handler) writePlainResponse(w http.ResponseWriter, statusCode int, mess
 	w.WriteHeader(statusCode)
 	_, err := w.Write([]byte(message))
 	if err != nil {
-		h.logger.Error("unable to write http response", err)
+		h.logger.Error("unable to write http response", "err", err)
 	}
 }
@@ -0,0 +1,303 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/chackett/dating-service/datingservice"
+	"github.com/chackett/dating-service/repository"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// handlePOSTReport handles requests from users to report another user to the moderators.
+func (h *handler) handlePOSTReport(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		ReportedID int    `json:"reportedId"`
+		Reason     string `json:"reason"`
+	}{}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySizeBytes)
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		h.logger.Error("decode report message", "err", err)
+		h.writePlainResponse(w, http.StatusBadRequest, "unable to parse report message")
+		return
+	}
+
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	report, err := h.dateService.ReportUser(r.Context(), sessionUserID, input.ReportedID, input.Reason)
+	if err != nil {
+		h.logger.Error("report user", "err", err)
+		h.writePlainResponse(w, http.StatusBadRequest, "unable to submit report")
+		return
+	}
+
+	btsResp, err := json.Marshal(struct {
+		ID int `json:"id"`
+	}{ID: report.ID})
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writeJSONResponse(w, http.StatusCreated, string(btsResp))
+}
+
+// handleGETAdminReports lists the open reports awaiting moderation.
+func (h *handler) handleGETAdminReports(w http.ResponseWriter, r *http.Request) {
+	reports, err := h.dateService.GetOpenReports(r.Context())
+	if err != nil {
+		h.logger.Error("get open reports", "err", err)
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	resp := struct {
+		Results []repository.Report `json:"results"`
+	}{
+		Results: reports,
+	}
+
+	btsResp, err := json.Marshal(resp)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+}
+
+// handleGETAdminReport shows a single report with the reported profile and the context between the two users.
+func (h *handler) handleGETAdminReport(w http.ResponseWriter, r *http.Request) {
+	reportID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid report id")
+		return
+	}
+
+	detail, err := h.dateService.GetReportDetail(r.Context(), reportID)
+	if err != nil {
+		h.logger.Error("get report detail", "err", err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			h.writePlainResponse(w, http.StatusNotFound, "report not found")
+			return
+		}
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	btsResp, err := json.Marshal(detail)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+}
+
+// handlePOSTAdminDismissReport closes a report without acting on the reported user.
+func (h *handler) handlePOSTAdminDismissReport(w http.ResponseWriter, r *http.Request) {
+	reportID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid report id")
+		return
+	}
+
+	input := struct {
+		Reason string `json:"reason"`
+	}{}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySizeBytes)
+	err = json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		h.logger.Error("decode dismiss report message", "err", err)
+		h.writePlainResponse(w, http.StatusBadRequest, "unable to parse dismiss message")
+		return
+	}
+
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	err = h.dateService.DismissReport(r.Context(), sessionUserID, reportID, input.Reason)
+	if err != nil {
+		h.writeModerationError(w, err)
+		return
+	}
+
+	h.writePlainResponse(w, http.StatusOK, "")
+}
+
+// handlePOSTAdminUserAction applies a warning, suspension or ban to a user.
+func (h *handler) handlePOSTAdminUserAction(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	input := datingservice.ModerationAction{}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySizeBytes)
+	err = json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		h.logger.Error("decode moderation action message", "err", err)
+		h.writePlainResponse(w, http.StatusBadRequest, "unable to parse moderation action")
+		return
+	}
+
+	moderator, ok := sessionIdentityFromRequest(r)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	err = h.dateService.ModerateUser(r.Context(), moderator, userID, input)
+	if err != nil {
+		h.writeModerationError(w, err)
+		return
+	}
+
+	h.writePlainResponse(w, http.StatusCreated, "")
+}
+
+// handleGETAdminLockouts lists accounts and IPs currently locked out after failed logins.
+func (h *handler) handleGETAdminLockouts(w http.ResponseWriter, r *http.Request) {
+	lockouts, err := h.dateService.GetActiveLockouts(r.Context())
+	if err != nil {
+		h.logger.Error("get active lockouts", "err", err)
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	resp := struct {
+		Results []repository.Lockout `json:"results"`
+	}{
+		Results: lockouts,
+	}
+
+	btsResp, err := json.Marshal(resp)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+}
+
+// handleDELETEAdminLockout lifts a lockout before it expires.
+func (h *handler) handleDELETEAdminLockout(w http.ResponseWriter, r *http.Request) {
+	lockoutID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid lockout id")
+		return
+	}
+
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	err = h.dateService.ClearLockout(r.Context(), sessionUserID, lockoutID)
+	if err != nil {
+		h.writeModerationError(w, err)
+		return
+	}
+
+	h.writePlainResponse(w, http.StatusNoContent, "")
+}
+
+// handlePUTAdminUserPlan grants or removes a user's subscription plan.
+func (h *handler) handlePUTAdminUserPlan(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	input := struct {
+		Plan      string     `json:"plan"`
+		ExpiresAt *time.Time `json:"expiresAt"`
+	}{}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySizeBytes)
+	err = json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		h.logger.Error("decode plan message", "err", err)
+		h.writePlainResponse(w, http.StatusBadRequest, "unable to parse plan message")
+		return
+	}
+
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	err = h.dateService.SetUserPlan(r.Context(), sessionUserID, userID, input.Plan, input.ExpiresAt)
+	if err != nil {
+		h.writeModerationError(w, err)
+		return
+	}
+
+	h.writePlainResponse(w, http.StatusNoContent, "")
+}
+
+// handlePOSTAdminUserBoosts grants boost credits to a user, such as after a purchase.
+func (h *handler) handlePOSTAdminUserBoosts(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	input := struct {
+		Credits int `json:"credits"`
+	}{}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySizeBytes)
+	err = json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		h.logger.Error("decode boost credits message", "err", err)
+		h.writePlainResponse(w, http.StatusBadRequest, "unable to parse boost credits message")
+		return
+	}
+
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	err = h.dateService.GrantBoostCredits(r.Context(), sessionUserID, userID, input.Credits)
+	if err != nil {
+		h.writeModerationError(w, err)
+		return
+	}
+
+	h.writePlainResponse(w, http.StatusNoContent, "")
+}
+
+// writeModerationError maps errors from moderation calls onto suitable response codes.
+func (h *handler) writeModerationError(w http.ResponseWriter, err error) {
+	h.logger.Error("moderation action", "err", err)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		h.writePlainResponse(w, http.StatusNotFound, "not found")
+	case errors.Is(err, datingservice.ErrInvalidModerationAction), errors.Is(err, datingservice.ErrInvalidPlan),
+		errors.Is(err, datingservice.ErrInvalidBoostGift):
+		h.writePlainResponse(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, datingservice.ErrPermissionDenied):
+		h.writePlainResponse(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, datingservice.ErrReportNotOpen):
+		h.writePlainResponse(w, http.StatusConflict, err.Error())
+	default:
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+	}
+}
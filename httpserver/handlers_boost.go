@@ -0,0 +1,67 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/chackett/dating-service/datingservice"
+	"net/http"
+)
+
+// handlePOSTMeBoosts starts a boost for the logged-in user.
+func (h *handler) handlePOSTMeBoosts(w http.ResponseWriter, r *http.Request) {
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	boost, err := h.dateService.StartBoost(r.Context(), sessionUserID)
+	if err != nil {
+		if errors.Is(err, datingservice.ErrBoostActive) {
+			h.writePlainResponse(w, http.StatusConflict, err.Error())
+			return
+		}
+		if errors.Is(err, datingservice.ErrNoBoostsLeft) {
+			h.writePlainResponse(w, http.StatusPaymentRequired, err.Error())
+			return
+		}
+		h.logger.Error("start boost", "err", err)
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	btsResp, err := json.Marshal(boost)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writeJSONResponse(w, http.StatusCreated, string(btsResp))
+}
+
+// handleGETMeBoosts lists the logged-in user's recent boosts and what each achieved.
+func (h *handler) handleGETMeBoosts(w http.ResponseWriter, r *http.Request) {
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	boosts, err := h.dateService.GetBoosts(r.Context(), sessionUserID)
+	if err != nil {
+		h.logger.Error("get boosts", "err", err)
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	resp := struct {
+		Results []datingservice.BoostStats `json:"results"`
+	}{
+		Results: boosts,
+	}
+	btsResp, err := json.Marshal(resp)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+}
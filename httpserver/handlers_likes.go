@@ -0,0 +1,91 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// handleGETLikesReceived lists who has liked the logged-in user, among those they haven't swiped on. The optional
+// cursor and limit query parameters page through the results.
+func (h *handler) handleGETLikesReceived(w http.ResponseWriter, r *http.Request) {
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	cursor, err := optionalIntQuery(r, "cursor")
+	if err != nil {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid cursor")
+		return
+	}
+	limit, err := optionalIntQuery(r, "limit")
+	if err != nil {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+
+	likes, err := h.dateService.GetReceivedLikes(r.Context(), sessionUserID, cursor, limit)
+	if err != nil {
+		h.logger.Error("get received likes", "err", err)
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	btsResp, err := json.Marshal(likes)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+}
+
+// optionalIntQuery parses a non-negative integer query parameter, which is 0 if absent.
+func optionalIntQuery(r *http.Request, name string) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return 0, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", name)
+	}
+	return v, nil
+}
+
+// handleGETSwipes lists the logged-in user's own likes and passes. The optional cursor and limit query parameters page
+// through the results.
+func (h *handler) handleGETSwipes(w http.ResponseWriter, r *http.Request) {
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	cursor, err := optionalIntQuery(r, "cursor")
+	if err != nil {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid cursor")
+		return
+	}
+	limit, err := optionalIntQuery(r, "limit")
+	if err != nil {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+
+	history, err := h.dateService.GetSwipeHistory(r.Context(), sessionUserID, cursor, limit)
+	if err != nil {
+		h.logger.Error("get swipe history", "err", err)
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	btsResp, err := json.Marshal(history)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+}
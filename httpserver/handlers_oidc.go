@@ -0,0 +1,130 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/chackett/dating-service/datingservice"
+	"github.com/chackett/dating-service/repository"
+	"net/http"
+)
+
+// handleGETOIDCLogin starts signing in with an identity provider by redirecting to it.
+func (h *handler) handleGETOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	redirectURL, err := h.dateService.StartOIDCLogin(r.Context(), r.PathValue("provider"))
+	if err != nil {
+		h.logger.Error("start oidc login", "err", err)
+		if errors.Is(err, datingservice.ErrUnknownOIDCProvider) {
+			h.writePlainResponse(w, http.StatusNotFound, err.Error())
+			return
+		}
+		h.writePlainResponse(w, http.StatusBadGateway, "identity provider unavailable")
+		return
+	}
+
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
+
+// handleGETOIDCCallback is where the identity provider returns the user to. The response is the same as /login's.
+func (h *handler) handleGETOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if providerErr := q.Get("error"); providerErr != "" {
+		h.logger.Info("oidc provider returned error", "provider", r.PathValue("provider"), "error", providerErr)
+		h.writePlainResponse(w, http.StatusBadRequest, "identity provider login failed: "+providerErr)
+		return
+	}
+
+	result, err := h.dateService.CompleteOIDCLogin(r.Context(), r.PathValue("provider"), q.Get("state"), q.Get("code"))
+	if err != nil {
+		h.logger.Error("complete oidc login", "err", err)
+		switch {
+		case errors.Is(err, datingservice.ErrUnknownOIDCProvider):
+			h.writePlainResponse(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, datingservice.ErrInvalidToken):
+			h.writePlainResponse(w, http.StatusBadRequest, "invalid or expired login state")
+		case errors.Is(err, datingservice.ErrOIDCLoginFailed):
+			h.writePlainResponse(w, http.StatusUnauthorized, datingservice.ErrOIDCLoginFailed.Error())
+		case errors.Is(err, datingservice.ErrOIDCEmailConflict), errors.Is(err, datingservice.ErrOIDCIdentityInUse):
+			h.writePlainResponse(w, http.StatusConflict, err.Error())
+		case errors.Is(err, datingservice.ErrAccountSuspended) || errors.Is(err, datingservice.ErrAccountBanned):
+			h.writePlainResponse(w, http.StatusForbidden, err.Error())
+		default:
+			h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		}
+		return
+	}
+
+	btsResp, err := json.Marshal(result)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	if result.Linked != nil {
+		h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+		return
+	}
+	h.writeJSONResponse(w, http.StatusAccepted, string(btsResp))
+}
+
+// handlePOSTMeIdentity starts linking an identity provider account to the logged-in user. It responds with the URL to
+// send the user to, as the request carries a session that a browser redirect wouldn't. The provider returns them to
+// the usual callback, which then links the account rather than logging in.
+func (h *handler) handlePOSTMeIdentity(w http.ResponseWriter, r *http.Request) {
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	redirectURL, err := h.dateService.StartOIDCLink(r.Context(), sessionUserID, r.PathValue("provider"))
+	if err != nil {
+		h.logger.Error("start oidc link", "err", err)
+		if errors.Is(err, datingservice.ErrUnknownOIDCProvider) {
+			h.writePlainResponse(w, http.StatusNotFound, err.Error())
+			return
+		}
+		h.writePlainResponse(w, http.StatusBadGateway, "identity provider unavailable")
+		return
+	}
+
+	resp := struct {
+		URL string `json:"url"`
+	}{
+		URL: redirectURL,
+	}
+	btsResp, err := json.Marshal(resp)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+}
+
+// handleGETMeIdentities lists the identity provider accounts linked to the logged-in user.
+func (h *handler) handleGETMeIdentities(w http.ResponseWriter, r *http.Request) {
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	identities, err := h.dateService.GetUserIdentities(r.Context(), sessionUserID)
+	if err != nil {
+		h.logger.Error("get user identities", "err", err)
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	resp := struct {
+		Results []repository.UserIdentity `json:"results"`
+	}{
+		Results: identities,
+	}
+	btsResp, err := json.Marshal(resp)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+}
@@ -0,0 +1,101 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/chackett/dating-service/datingservice"
+	"net/http"
+)
+
+// handlePOSTForgotPassword starts a password reset. The response is always 202, whether or not the account exists, so
+// it can't be used to discover registered emails.
+func (h *handler) handlePOSTForgotPassword(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		Email string `json:"email"`
+	}{}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySizeBytes)
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		h.logger.Error("decode forgot password message", "err", err)
+		h.writePlainResponse(w, http.StatusBadRequest, "unable to parse forgot password message")
+		return
+	}
+
+	err = h.dateService.ForgotPassword(r.Context(), input.Email)
+	if err != nil {
+		// Logged only, the response must not differ.
+		h.logger.Error("forgot password", "err", err)
+	}
+
+	h.writePlainResponse(w, http.StatusAccepted, "")
+}
+
+// handlePOSTResetPassword completes a password reset with the emailed token.
+func (h *handler) handlePOSTResetPassword(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}{}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySizeBytes)
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		h.logger.Error("decode reset password message", "err", err)
+		h.writePlainResponse(w, http.StatusBadRequest, "unable to parse reset password message")
+		return
+	}
+
+	err = h.dateService.ResetPassword(r.Context(), input.Token, input.Password)
+	if err != nil {
+		h.writePasswordError(w, err)
+		return
+	}
+
+	h.writePlainResponse(w, http.StatusOK, "")
+}
+
+// handlePOSTChangePassword changes the logged-in user's password. All of their sessions, including this one, are ended.
+func (h *handler) handlePOSTChangePassword(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
+	}{}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySizeBytes)
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		h.logger.Error("decode change password message", "err", err)
+		h.writePlainResponse(w, http.StatusBadRequest, "unable to parse change password message")
+		return
+	}
+
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	err = h.dateService.ChangePassword(r.Context(), sessionUserID, input.CurrentPassword, input.NewPassword)
+	if err != nil {
+		h.writePasswordError(w, err)
+		return
+	}
+
+	h.writePlainResponse(w, http.StatusOK, "")
+}
+
+// writePasswordError maps errors from password calls onto suitable response codes.
+func (h *handler) writePasswordError(w http.ResponseWriter, err error) {
+	h.logger.Error("password update", "err", err)
+	switch {
+	case errors.Is(err, datingservice.ErrInvalidToken):
+		h.writePlainResponse(w, http.StatusBadRequest, datingservice.ErrInvalidToken.Error())
+	case errors.Is(err, datingservice.ErrIncorrectPassword):
+		h.writePlainResponse(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, datingservice.ErrInvalidProfile):
+		h.writePlainResponse(w, http.StatusBadRequest, err.Error())
+	default:
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+	}
+}
@@ -0,0 +1,138 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/chackett/dating-service/datingservice"
+	"gorm.io/gorm"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// handlePOSTMePhotos handles a multipart upload of a photo, in the `photo` field, to the logged-in user's profile.
+func (h *handler) handlePOSTMePhotos(w http.ResponseWriter, r *http.Request) {
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	// Allow some headroom over the photo limit for the multipart framing.
+	r.Body = http.MaxBytesReader(w, r.Body, datingservice.MaxPhotoUploadBytes+maxRequestBodySizeBytes)
+	file, _, err := r.FormFile("photo")
+	if err != nil {
+		h.logger.Error("read photo upload", "err", err)
+		h.writePlainResponse(w, http.StatusBadRequest, "expected a multipart upload with a `photo` file")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, datingservice.MaxPhotoUploadBytes+1))
+	if err != nil {
+		h.logger.Error("read photo upload", "err", err)
+		h.writePlainResponse(w, http.StatusBadRequest, "unable to read photo")
+		return
+	}
+
+	photo, err := h.dateService.UploadPhoto(r.Context(), sessionUserID, data)
+	if err != nil {
+		h.logger.Error("upload photo", "err", err)
+		if errors.Is(err, datingservice.ErrInvalidPhoto) {
+			h.writePlainResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	btsResp, err := json.Marshal(photo)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writeJSONResponse(w, http.StatusCreated, string(btsResp))
+}
+
+// handleDELETEMePhoto removes a photo from the logged-in user's profile.
+func (h *handler) handleDELETEMePhoto(w http.ResponseWriter, r *http.Request) {
+	photoID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid photo id")
+		return
+	}
+
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	err = h.dateService.DeletePhoto(r.Context(), sessionUserID, photoID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			h.writePlainResponse(w, http.StatusNotFound, "photo not found")
+			return
+		}
+		h.logger.Error("delete photo", "err", err)
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	h.writePlainResponse(w, http.StatusNoContent, "")
+}
+
+// handlePUTMePhotosOrder sets the display order of the logged-in user's photos.
+func (h *handler) handlePUTMePhotosOrder(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		PhotoIDs []int `json:"photoIds"`
+	}{}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySizeBytes)
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		h.logger.Error("decode photo order message", "err", err)
+		h.writePlainResponse(w, http.StatusBadRequest, "unable to parse photo order")
+		return
+	}
+
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	err = h.dateService.ReorderPhotos(r.Context(), sessionUserID, input.PhotoIDs)
+	if err != nil {
+		h.logger.Error("reorder photos", "err", err)
+		if errors.Is(err, datingservice.ErrInvalidPhoto) {
+			h.writePlainResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	h.writePlainResponse(w, http.StatusOK, "")
+}
+
+// handleGETMedia serves photos kept in the local blob store. Directory listings are never served.
+func (h *handler) handleGETMedia(w http.ResponseWriter, r *http.Request) {
+	p := r.PathValue("path")
+	if !filepath.IsLocal(p) {
+		h.writePlainResponse(w, http.StatusNotFound, "")
+		return
+	}
+
+	full := filepath.Join(h.mediaDir, filepath.FromSlash(p))
+	info, err := os.Stat(full)
+	if err != nil || info.IsDir() {
+		h.writePlainResponse(w, http.StatusNotFound, "")
+		return
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeFile(w, r, full)
+}
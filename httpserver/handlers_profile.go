@@ -0,0 +1,406 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/chackett/dating-service/datingservice"
+	"github.com/chackett/dating-service/repository"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+)
+
+// handleGETMe returns the logged-in user's own profile.
+func (h *handler) handleGETMe(w http.ResponseWriter, r *http.Request) {
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	user, err := h.dateService.GetProfile(r.Context(), sessionUserID)
+	if err != nil {
+		h.logger.Error("get profile", "err", err)
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	btsResp, err := json.Marshal(user)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+}
+
+// handleGETMeEntitlements returns the logged-in user's plan and remaining daily quota.
+func (h *handler) handleGETMeEntitlements(w http.ResponseWriter, r *http.Request) {
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	entitlements, err := h.dateService.GetEntitlements(r.Context(), sessionUserID)
+	if err != nil {
+		h.logger.Error("get entitlements", "err", err)
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	btsResp, err := json.Marshal(entitlements)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+}
+
+// handlePATCHMe applies a partial update to the logged-in user's profile.
+func (h *handler) handlePATCHMe(w http.ResponseWriter, r *http.Request) {
+	input := datingservice.ProfileUpdate{}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySizeBytes)
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		h.logger.Error("decode profile update message", "err", err)
+		h.writePlainResponse(w, http.StatusBadRequest, "unable to parse profile update")
+		return
+	}
+
+	session, ok := sessionIdentityFromRequest(r)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	user, err := h.dateService.UpdateProfile(r.Context(), session, input)
+	if err != nil {
+		h.logger.Error("update profile", "err", err)
+		if h.writeLocationThrottled(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, datingservice.ErrInvalidProfile):
+			h.writePlainResponse(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, datingservice.ErrIncorrectPassword):
+			h.writePlainResponse(w, http.StatusForbidden, err.Error())
+		case errors.Is(err, gorm.ErrDuplicatedKey):
+			h.writePlainResponse(w, http.StatusConflict, "email address already in use")
+		default:
+			h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		}
+		return
+	}
+
+	btsResp, err := json.Marshal(user)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+}
+
+// handleGETUser returns another user's masked profile, provided the logged-in user is allowed to see it.
+func (h *handler) handleGETUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	user, err := h.dateService.ViewProfile(r.Context(), sessionUserID, userID)
+	if err != nil {
+		// Hidden and missing profiles look the same, so this can't be used to probe for users.
+		if errors.Is(err, datingservice.ErrProfileNotVisible) || errors.Is(err, gorm.ErrRecordNotFound) {
+			h.writePlainResponse(w, http.StatusNotFound, "user not found")
+			return
+		}
+		h.logger.Error("view profile", "err", err)
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	btsResp, err := json.Marshal(user)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+}
+
+// handleGETInterests returns the catalogue of interests users can choose from.
+func (h *handler) handleGETInterests(w http.ResponseWriter, r *http.Request) {
+	interests, err := h.dateService.GetInterestCatalogue(r.Context())
+	if err != nil {
+		h.logger.Error("get interest catalogue", "err", err)
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	resp := struct {
+		Results []repository.Interest `json:"results"`
+	}{
+		Results: interests,
+	}
+
+	btsResp, err := json.Marshal(resp)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+}
+
+// handleGETGenders returns the genders users may choose from.
+func (h *handler) handleGETGenders(w http.ResponseWriter, r *http.Request) {
+	resp := struct {
+		Results []string `json:"results"`
+	}{
+		Results: h.dateService.GetGenders(),
+	}
+
+	btsResp, err := json.Marshal(resp)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+}
+
+// handleGETEducationLevels returns the education scale used by preferences.
+func (h *handler) handleGETEducationLevels(w http.ResponseWriter, r *http.Request) {
+	levels, err := h.dateService.GetEducationLevels(r.Context())
+	if err != nil {
+		h.logger.Error("get education levels", "err", err)
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	resp := struct {
+		Results []repository.EducationLevel `json:"results"`
+	}{
+		Results: levels,
+	}
+
+	btsResp, err := json.Marshal(resp)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+}
+
+// handleGETPrompts returns the profile prompts users can answer.
+func (h *handler) handleGETPrompts(w http.ResponseWriter, r *http.Request) {
+	prompts, err := h.dateService.GetPrompts(r.Context())
+	if err != nil {
+		h.logger.Error("get prompts", "err", err)
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	resp := struct {
+		Results []repository.Prompt `json:"results"`
+	}{
+		Results: prompts,
+	}
+
+	btsResp, err := json.Marshal(resp)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+}
+
+// handlePUTMeInterests replaces the logged-in user's interests.
+func (h *handler) handlePUTMeInterests(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		InterestIDs []int `json:"interestIds"`
+	}{}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySizeBytes)
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		h.logger.Error("decode interests message", "err", err)
+		h.writePlainResponse(w, http.StatusBadRequest, "unable to parse interests")
+		return
+	}
+
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	err = h.dateService.SetUserInterests(r.Context(), sessionUserID, input.InterestIDs)
+	if err != nil {
+		h.logger.Error("set user interests", "err", err)
+		if errors.Is(err, datingservice.ErrInvalidProfile) {
+			h.writePlainResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	h.writePlainResponse(w, http.StatusOK, "")
+}
+
+// handlePUTMePrompts replaces the logged-in user's prompt answers.
+func (h *handler) handlePUTMePrompts(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		Answers []repository.PromptAnswer `json:"answers"`
+	}{}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySizeBytes)
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		h.logger.Error("decode prompts message", "err", err)
+		h.writePlainResponse(w, http.StatusBadRequest, "unable to parse prompt answers")
+		return
+	}
+
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	err = h.dateService.SetUserPromptAnswers(r.Context(), sessionUserID, input.Answers)
+	if err != nil {
+		h.logger.Error("set user prompt answers", "err", err)
+		if errors.Is(err, datingservice.ErrInvalidProfile) {
+			h.writePlainResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	h.writePlainResponse(w, http.StatusOK, "")
+}
+
+// handleGETMeNotifications lists the logged-in user's most recent notifications.
+func (h *handler) handleGETMeNotifications(w http.ResponseWriter, r *http.Request) {
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	notifications, err := h.dateService.GetNotifications(r.Context(), sessionUserID)
+	if err != nil {
+		h.logger.Error("get notifications", "err", err)
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	resp := struct {
+		Results []repository.Notification `json:"results"`
+	}{
+		Results: notifications,
+	}
+	btsResp, err := json.Marshal(resp)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+}
+
+// handlePOSTMeNotificationsRead marks all the logged-in user's notifications as read.
+func (h *handler) handlePOSTMeNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	err := h.dateService.MarkNotificationsRead(r.Context(), sessionUserID)
+	if err != nil {
+		h.logger.Error("mark notifications read", "err", err)
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writePlainResponse(w, http.StatusNoContent, "")
+}
+
+// handlePUTMeTravel sets the logged-in user's travel location, returning their updated profile.
+func (h *handler) handlePUTMeTravel(w http.ResponseWriter, r *http.Request) {
+	input := datingservice.TravelPlan{}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySizeBytes)
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		h.logger.Error("decode travel message", "err", err)
+		h.writePlainResponse(w, http.StatusBadRequest, "unable to parse travel plan")
+		return
+	}
+
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	user, err := h.dateService.SetTravel(r.Context(), sessionUserID, input)
+	if err != nil {
+		if h.writeLocationThrottled(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, datingservice.ErrInvalidTravel):
+			h.writePlainResponse(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, datingservice.ErrPremiumRequired):
+			h.writePlainResponse(w, http.StatusForbidden, err.Error())
+		default:
+			h.logger.Error("set travel", "err", err)
+			h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		}
+		return
+	}
+
+	btsResp, err := json.Marshal(user)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+}
+
+// handleDELETEMeTravel returns the logged-in user to their usual location.
+func (h *handler) handleDELETEMeTravel(w http.ResponseWriter, r *http.Request) {
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	err := h.dateService.ClearTravel(r.Context(), sessionUserID)
+	if err != nil {
+		h.logger.Error("clear travel", "err", err)
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writePlainResponse(w, http.StatusNoContent, "")
+}
+
+// writeLocationThrottled responds with 429 if err refuses a location change for being too soon after the last,
+// reporting whether it did.
+func (h *handler) writeLocationThrottled(w http.ResponseWriter, err error) bool {
+	throttled := &datingservice.LocationThrottledError{}
+	if !errors.As(err, &throttled) {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(throttled.RetryAfter)))
+	h.writePlainResponse(w, http.StatusTooManyRequests, datingservice.ErrLocationUpdateTooSoon.Error())
+	return true
+}
@@ -0,0 +1,159 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/chackett/dating-service/datingservice"
+	"net/http"
+	"strconv"
+)
+
+// handlePOSTLoginTwoFactor completes a login for a user with two-factor authentication, exchanging the challenge token
+// from /login and a TOTP or recovery code for a session token.
+func (h *handler) handlePOSTLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		ChallengeToken string `json:"challengeToken"`
+		Code           string `json:"code"`
+	}{}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySizeBytes)
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		h.logger.Error("decode two-factor login message", "err", err)
+		h.writePlainResponse(w, http.StatusBadRequest, "unable to parse two-factor login message")
+		return
+	}
+
+	token, err := h.dateService.CompleteTwoFactorLogin(r.Context(), input.ChallengeToken, input.Code, clientIP(r))
+	if err != nil {
+		h.logger.Error("two-factor login attempt", "err", err)
+		throttled := &datingservice.LoginThrottledError{}
+		switch {
+		case errors.As(err, &throttled):
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(throttled.RetryAfter)))
+			h.writePlainResponse(w, http.StatusTooManyRequests, "too many failed login attempts, try again later")
+		case errors.Is(err, datingservice.ErrAccountSuspended) || errors.Is(err, datingservice.ErrAccountBanned):
+			h.writePlainResponse(w, http.StatusForbidden, err.Error())
+		case errors.Is(err, datingservice.ErrInvalidToken), errors.Is(err, datingservice.ErrInvalidTwoFactorCode):
+			h.writePlainResponse(w, http.StatusUnauthorized, "invalid or expired challenge / code")
+		default:
+			h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		}
+		return
+	}
+
+	btsResp, err := json.Marshal(datingservice.LoginResult{Token: token})
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusAccepted, string(btsResp))
+}
+
+// handlePOSTMeTwoFactorEnroll starts two-factor enrolment for the logged-in user.
+func (h *handler) handlePOSTMeTwoFactorEnroll(w http.ResponseWriter, r *http.Request) {
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	enrollment, err := h.dateService.EnrollTwoFactor(r.Context(), sessionUserID)
+	if err != nil {
+		h.writeTwoFactorError(w, err)
+		return
+	}
+
+	btsResp, err := json.Marshal(enrollment)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+}
+
+// handlePOSTMeTwoFactorConfirm confirms enrolment with a first code, responding with the one-time recovery codes.
+func (h *handler) handlePOSTMeTwoFactorConfirm(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		Code string `json:"code"`
+	}{}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySizeBytes)
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		h.logger.Error("decode two-factor confirm message", "err", err)
+		h.writePlainResponse(w, http.StatusBadRequest, "unable to parse two-factor confirm message")
+		return
+	}
+
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	codes, err := h.dateService.ConfirmTwoFactor(r.Context(), sessionUserID, input.Code)
+	if err != nil {
+		h.writeTwoFactorError(w, err)
+		return
+	}
+
+	resp := struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}{
+		RecoveryCodes: codes,
+	}
+	btsResp, err := json.Marshal(resp)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+}
+
+// handleDELETEMeTwoFactor disables two-factor authentication, given a current TOTP or recovery code.
+func (h *handler) handleDELETEMeTwoFactor(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		Code string `json:"code"`
+	}{}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySizeBytes)
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		h.logger.Error("decode two-factor disable message", "err", err)
+		h.writePlainResponse(w, http.StatusBadRequest, "unable to parse two-factor disable message")
+		return
+	}
+
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	err = h.dateService.DisableTwoFactor(r.Context(), sessionUserID, input.Code)
+	if err != nil {
+		h.writeTwoFactorError(w, err)
+		return
+	}
+
+	h.writePlainResponse(w, http.StatusNoContent, "")
+}
+
+// writeTwoFactorError maps errors from two-factor calls onto suitable response codes.
+func (h *handler) writeTwoFactorError(w http.ResponseWriter, err error) {
+	h.logger.Error("two-factor update", "err", err)
+	switch {
+	case errors.Is(err, datingservice.ErrTwoFactorUnavailable):
+		h.writePlainResponse(w, http.StatusNotImplemented, err.Error())
+	case errors.Is(err, datingservice.ErrTwoFactorAlreadyEnabled), errors.Is(err, datingservice.ErrTwoFactorNotEnrolled):
+		h.writePlainResponse(w, http.StatusConflict, err.Error())
+	case errors.Is(err, datingservice.ErrInvalidTwoFactorCode):
+		h.writePlainResponse(w, http.StatusForbidden, err.Error())
+	default:
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+	}
+}
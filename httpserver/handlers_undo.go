@@ -0,0 +1,48 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/chackett/dating-service/datingservice"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// handlePOSTSwipeUndo reverts the logged-in user's most recent swipe.
+func (h *handler) handlePOSTSwipeUndo(w http.ResponseWriter, r *http.Request) {
+	sessionUserID, ok := r.Context().Value(ctxKeySessionUserID).(int)
+	if !ok {
+		h.writePlainResponse(w, http.StatusBadRequest, "invalid user")
+		return
+	}
+
+	undone, err := h.dateService.UndoSwipe(r.Context(), sessionUserID)
+	if err != nil {
+		quota := &datingservice.UndoQuotaError{}
+		if errors.As(err, &quota) {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(time.Until(quota.ResetsAt))))
+			h.writePlainResponse(w, http.StatusTooManyRequests, datingservice.ErrUndoQuotaExceeded.Error())
+			return
+		}
+		if errors.Is(err, datingservice.ErrNothingToUndo) {
+			h.writePlainResponse(w, http.StatusConflict, err.Error())
+			return
+		}
+		h.logger.Error("undo swipe", "err", err)
+		h.writePlainResponse(w, http.StatusInternalServerError, "unable to undo swipe")
+		return
+	}
+
+	result := struct {
+		Results datingservice.UndoResult `json:"results"`
+	}{
+		Results: undone,
+	}
+	btsResp, err := json.Marshal(result)
+	if err != nil {
+		h.writePlainResponse(w, http.StatusInternalServerError, "an error has occurred")
+		return
+	}
+	h.writeJSONResponse(w, http.StatusOK, string(btsResp))
+}
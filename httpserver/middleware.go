@@ -2,33 +2,92 @@ package httpserver
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"github.com/chackett/dating-service/datingservice"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// clientIP returns the address of the connecting client. Forwarding headers are deliberately ignored, as they can be set
+// by the client to dodge per-IP limits.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func (h *handler) middlewareRequestLogger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		h.logger.Info("Started %s %s", r.Method, r.URL.Path)
+		h.logger.Info("Started", "method", r.Method, "path", r.URL.Path)
 
 		// Call the next handler
 		next.ServeHTTP(w, r)
 
-		h.logger.Info("Completed %s in %v", r.URL.Path, time.Since(start))
+		h.logger.Info("Completed", "path", r.URL.Path, "duration", time.Since(start))
+	})
+}
+
+// middlewareRateLimit applies the route's rate limit, if it has one. It runs after middlewareAuth so authenticated
+// requests are limited per user, however many IPs they come from; anything else is limited per client IP. Each route
+// has its own buckets. If the store fails the request is let through, as an outage of the limiter shouldn't take the
+// API down with it.
+func (h *handler) middlewareRateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := h.routeMux.Handler(r)
+		rc, ok := h.routes[pattern]
+		if !ok || rc.rateLimit == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := pattern + "|ip:" + clientIP(r)
+		if userID, ok := r.Context().Value(ctxKeySessionUserID).(int); ok {
+			key = pattern + "|user:" + strconv.Itoa(userID)
+		}
+
+		res, err := h.limiter.Take(r.Context(), key, *rc.rateLimit, time.Now())
+		if err != nil {
+			h.logger.Error("rate limit", "err", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter)))
+		if !res.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			h.logger.Info("rate limited", "key", key, "path", r.URL.Path)
+			h.writePlainResponse(w, http.StatusTooManyRequests, "too many requests, try again later")
+			return
+		}
+
+		next.ServeHTTP(w, r)
 	})
 }
 
+// ceilSeconds rounds a duration up to whole seconds, for headers that count in seconds.
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
 func (h *handler) middlewareAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		h.logger.Info("Started %s %s", r.Method, r.URL.Path)
+		h.logger.Info("Started", "method", r.Method, "path", r.URL.Path)
 
-		pattern := fmt.Sprintf("%s %s", r.Method, r.URL.Path)
+		// Resolve the pattern through the mux rather than the raw path, so routes with wildcards such as
+		// `/admin/reports/{id}` find their config.
+		_, pattern := h.routeMux.Handler(r)
 		rc, ok := h.routes[pattern]
 		if !ok {
-			h.logger.Error("%s %s - not configured", r.Method, r.URL.Path)
+			h.logger.Error("route not configured", "method", r.Method, "path", r.URL.Path)
 			h.writePlainResponse(w, http.StatusNotFound, "")
 			return
 		}
@@ -44,23 +103,40 @@ func (h *handler) middlewareAuth(next http.Handler) http.Handler {
 		if len(split) < 2 {
 			respCode := http.StatusUnauthorized
 			h.writePlainResponse(w, respCode, "invalid auth token")
-			h.logger.Info("Completed (unauthenticated) %s with response code (%d) in %v", r.URL.Path, respCode, time.Since(start))
+			h.logger.Info("Completed (unauthenticated)", "path", r.URL.Path, "status", respCode, "duration", time.Since(start))
 			return
 		}
 		authToken = split[1]
 
-		userID, err := h.dateService.AuthenticateUserToken(r.Context(), authToken)
+		identity, err := h.dateService.AuthenticateUserToken(r.Context(), authToken)
 		if err != nil {
 			respCode := http.StatusUnauthorized
-			h.logger.Error("authenticate user token", err)
-			h.writePlainResponse(w, respCode, "invalid auth token")
-			h.logger.Info("Completed (unauthenticated) %s with response code (%d) in %v", r.URL.Path, respCode, time.Since(start))
+			message := "invalid auth token"
+			if errors.Is(err, datingservice.ErrAccountSuspended) || errors.Is(err, datingservice.ErrAccountBanned) {
+				respCode = http.StatusForbidden
+				message = err.Error()
+			}
+			h.logger.Error("authenticate user token", "err", err)
+			h.writePlainResponse(w, respCode, message)
+			h.logger.Info("Completed (unauthenticated)", "path", r.URL.Path, "status", respCode, "duration", time.Since(start))
+			return
+		}
+
+		if !datingservice.RoleHasPermissions(identity.Role, rc.permissions...) {
+			respCode := http.StatusForbidden
+			h.logger.Info("session lacks permission for route", "user_id", identity.UserID, "role", identity.Role, "path", r.URL.Path)
+			h.writePlainResponse(w, respCode, "forbidden")
+			h.logger.Info("Completed (forbidden)", "path", r.URL.Path, "status", respCode, "duration", time.Since(start))
 			return
 		}
 
-		r = r.WithContext(context.WithValue(r.Context(), ctxKeySessionUserID, userID))
+		ctx := context.WithValue(r.Context(), ctxKeySessionUserID, identity.UserID)
+		ctx = context.WithValue(ctx, ctxKeySessionRole, identity.Role)
+		ctx = context.WithValue(ctx, ctxKeySessionID, identity.SessionID)
+		ctx = context.WithValue(ctx, ctxKeySessionTokenID, identity.TokenID)
+		r = r.WithContext(ctx)
 
-		h.logger.Info("Completed (authenticated) %s in %v", r.URL.Path, time.Since(start))
+		h.logger.Info("Completed (authenticated)", "path", r.URL.Path, "duration", time.Since(start))
 		next.ServeHTTP(w, r)
 	})
 }
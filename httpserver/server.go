@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/chackett/dating-service/datingservice"
+	"github.com/chackett/dating-service/pkg/ratelimit"
 	"log/slog"
 	"net/http"
 	"os"
@@ -15,14 +16,16 @@ type HTTPServer struct {
 	mux    http.Handler
 }
 
-func New(port int, ds *datingservice.DateService) (*HTTPServer, error) {
-	h, err := newHandler(ds)
+// New creates the HTTP server. If mediaDir is set, the files within it are served publicly under /media. Rate limited
+// routes keep their buckets in limiter.
+func New(port int, ds *datingservice.DateService, mediaDir string, limiter ratelimit.Store) (*HTTPServer, error) {
+	h, err := newHandler(ds, mediaDir, limiter)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create handler: %w", err)
 	}
 
 	mws := []func(handler2 http.Handler) http.Handler{
-		h.middlewareAuth, h.middlewareAuth,
+		h.middlewareAuth, h.middlewareRateLimit,
 	}
 
 	h.setupRoutes(mws)
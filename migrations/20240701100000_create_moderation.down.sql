@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS audit_log;
+DROP TABLE IF EXISTS reports;
+ALTER TABLE users
+    DROP COLUMN role,
+    DROP COLUMN suspended_until,
+    DROP COLUMN banned;
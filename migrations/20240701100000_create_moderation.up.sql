@@ -0,0 +1,39 @@
+START TRANSACTION;
+
+ALTER TABLE users
+    ADD COLUMN role            VARCHAR(20) NOT NULL DEFAULT 'user',
+    ADD COLUMN suspended_until TIMESTAMP   NULL,
+    ADD COLUMN banned          BOOL        NOT NULL DEFAULT FALSE;
+
+CREATE TABLE reports
+(
+    id          INT AUTO_INCREMENT PRIMARY KEY,
+    reporter_id INT           NOT NULL,
+    reported_id INT           NOT NULL,
+    reason      VARCHAR(1000) NOT NULL,
+    status      VARCHAR(20)   NOT NULL DEFAULT 'open',
+    created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP     NULL,
+    resolved_by INT           NULL,
+    INDEX idx_reports_status (status),
+    FOREIGN KEY (reporter_id) REFERENCES users (id),
+    FOREIGN KEY (reported_id) REFERENCES users (id),
+    FOREIGN KEY (resolved_by) REFERENCES users (id)
+);
+
+CREATE TABLE audit_log
+(
+    id             INT AUTO_INCREMENT PRIMARY KEY,
+    actor_id       INT         NOT NULL,
+    action         VARCHAR(50) NOT NULL,
+    target_user_id INT         NULL,
+    report_id      INT         NULL,
+    detail         VARCHAR(1000),
+    created_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_audit_log_target_user (target_user_id),
+    FOREIGN KEY (actor_id) REFERENCES users (id),
+    FOREIGN KEY (target_user_id) REFERENCES users (id),
+    FOREIGN KEY (report_id) REFERENCES reports (id)
+);
+
+COMMIT;
@@ -0,0 +1,5 @@
+DELETE FROM audit_log WHERE actor_id IS NULL;
+ALTER TABLE audit_log
+    MODIFY actor_id INT NOT NULL;
+ALTER TABLE sessions
+    DROP COLUMN role;
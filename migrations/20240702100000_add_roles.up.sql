@@ -0,0 +1,10 @@
+START TRANSACTION;
+
+ALTER TABLE sessions
+    ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user';
+
+-- CLI driven actions have no acting user.
+ALTER TABLE audit_log
+    MODIFY actor_id INT NULL;
+
+COMMIT;
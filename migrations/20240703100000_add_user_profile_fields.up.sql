@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ADD COLUMN bio            TEXT,
+    ADD COLUMN email_verified BOOL NOT NULL DEFAULT TRUE;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS user_prompts;
+DROP TABLE IF EXISTS prompts;
+DROP TABLE IF EXISTS user_interests;
+DROP TABLE IF EXISTS interests;
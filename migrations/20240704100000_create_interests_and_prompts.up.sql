@@ -0,0 +1,80 @@
+START TRANSACTION;
+
+CREATE TABLE interests
+(
+    id       INT AUTO_INCREMENT PRIMARY KEY,
+    name     VARCHAR(100) NOT NULL UNIQUE,
+    category VARCHAR(50)
+);
+
+CREATE TABLE user_interests
+(
+    user_id     INT NOT NULL,
+    interest_id INT NOT NULL,
+    PRIMARY KEY (user_id, interest_id),
+    FOREIGN KEY (user_id) REFERENCES users (id),
+    FOREIGN KEY (interest_id) REFERENCES interests (id)
+);
+
+CREATE TABLE prompts
+(
+    id     INT AUTO_INCREMENT PRIMARY KEY,
+    text   VARCHAR(255) NOT NULL UNIQUE,
+    active BOOL         NOT NULL DEFAULT TRUE
+);
+
+CREATE TABLE user_prompts
+(
+    user_id   INT          NOT NULL,
+    prompt_id INT          NOT NULL,
+    answer    VARCHAR(300) NOT NULL,
+    position  INT          NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, prompt_id),
+    FOREIGN KEY (user_id) REFERENCES users (id),
+    FOREIGN KEY (prompt_id) REFERENCES prompts (id)
+);
+
+INSERT INTO interests (name, category)
+VALUES ('Hiking', 'Outdoors'),
+       ('Climbing', 'Outdoors'),
+       ('Camping', 'Outdoors'),
+       ('Running', 'Fitness'),
+       ('Yoga', 'Fitness'),
+       ('Cycling', 'Fitness'),
+       ('Cooking', 'Food & Drink'),
+       ('Wine', 'Food & Drink'),
+       ('Coffee', 'Food & Drink'),
+       ('Live Music', 'Arts'),
+       ('Photography', 'Arts'),
+       ('Theatre', 'Arts'),
+       ('Reading', 'Culture'),
+       ('Film', 'Culture'),
+       ('Travel', 'Culture'),
+       ('Board Games', 'Games'),
+       ('Video Games', 'Games'),
+       ('Dogs', 'Pets'),
+       ('Cats', 'Pets'),
+       ('Volunteering', 'Community');
+
+INSERT INTO prompts (text)
+VALUES ('My ideal Sunday is...'),
+       ('I''m looking for...'),
+       ('The way to win me over is...'),
+       ('My most irrational fear...'),
+       ('Two truths and a lie...'),
+       ('I geek out on...');
+
+-- Give the seed users something to share.
+INSERT INTO user_interests (user_id, interest_id)
+VALUES (1, 1), (1, 7), (1, 15),
+       (2, 1), (2, 4), (2, 15),
+       (3, 10), (3, 14), (3, 17),
+       (4, 7), (4, 8), (4, 13),
+       (5, 5), (5, 15), (5, 18),
+       (6, 2), (6, 3), (6, 6),
+       (7, 7), (7, 10), (7, 19),
+       (8, 4), (8, 16), (8, 17),
+       (9, 11), (9, 13), (9, 15),
+       (10, 1), (10, 9), (10, 18);
+
+COMMIT;
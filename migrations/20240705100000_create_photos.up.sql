@@ -0,0 +1,14 @@
+CREATE TABLE photos
+(
+    id            INT AUTO_INCREMENT PRIMARY KEY,
+    user_id       INT          NOT NULL,
+    image_key     VARCHAR(255) NOT NULL,
+    thumbnail_key VARCHAR(255) NOT NULL,
+    content_type  VARCHAR(50)  NOT NULL,
+    width         INT          NOT NULL,
+    height        INT          NOT NULL,
+    position      INT          NOT NULL DEFAULT 0,
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_photos_user_position (user_id, position),
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
@@ -0,0 +1,13 @@
+CREATE TABLE user_tokens
+(
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    user_id    INT          NOT NULL,
+    purpose    VARCHAR(30)  NOT NULL,
+    token_hash CHAR(64)     NOT NULL UNIQUE,
+    email      VARCHAR(255),
+    expires_at TIMESTAMP    NOT NULL,
+    used_at    TIMESTAMP    NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_user_tokens_user_purpose (user_id, purpose),
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
@@ -0,0 +1,26 @@
+START TRANSACTION;
+
+CREATE TABLE login_failures
+(
+    scope           VARCHAR(10)  NOT NULL,
+    subject         VARCHAR(255) NOT NULL,
+    failures        INT          NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP    NOT NULL,
+    locked_until    TIMESTAMP    NULL,
+    PRIMARY KEY (scope, subject)
+);
+
+CREATE TABLE lockouts
+(
+    id           INT AUTO_INCREMENT PRIMARY KEY,
+    scope        VARCHAR(10)  NOT NULL,
+    subject      VARCHAR(255) NOT NULL,
+    locked_at    TIMESTAMP    NOT NULL,
+    locked_until TIMESTAMP    NOT NULL,
+    cleared_at   TIMESTAMP    NULL,
+    cleared_by   INT          NULL,
+    INDEX idx_lockouts_locked_until (locked_until),
+    FOREIGN KEY (cleared_by) REFERENCES users (id)
+);
+
+COMMIT;
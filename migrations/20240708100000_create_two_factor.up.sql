@@ -0,0 +1,23 @@
+START TRANSACTION;
+
+CREATE TABLE user_totp
+(
+    user_id          INT          NOT NULL PRIMARY KEY,
+    secret_encrypted VARCHAR(255) NOT NULL,
+    confirmed_at     TIMESTAMP    NULL,
+    last_used_step   BIGINT       NULL,
+    created_at       TIMESTAMP    NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE TABLE user_recovery_codes
+(
+    id        INT AUTO_INCREMENT PRIMARY KEY,
+    user_id   INT       NOT NULL,
+    code_hash CHAR(64)  NOT NULL,
+    used_at   TIMESTAMP NULL,
+    INDEX idx_user_recovery_codes_user_id (user_id),
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+COMMIT;
@@ -0,0 +1,15 @@
+START TRANSACTION;
+
+-- Millisecond precision, so revoking a user's tokens doesn't also catch a token issued moments later in the same second.
+CREATE TABLE token_revocations
+(
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    token_id   VARCHAR(64)  NULL,
+    user_id    INT          NULL,
+    revoked_at TIMESTAMP(3) NOT NULL,
+    expires_at TIMESTAMP    NOT NULL,
+    INDEX idx_token_revocations_expires_at (expires_at),
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+COMMIT;
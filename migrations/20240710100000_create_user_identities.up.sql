@@ -0,0 +1,26 @@
+START TRANSACTION;
+
+CREATE TABLE user_identities
+(
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    user_id    INT          NOT NULL,
+    provider   VARCHAR(50)  NOT NULL,
+    subject    VARCHAR(255) NOT NULL,
+    email      VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP    NOT NULL,
+    UNIQUE KEY idx_user_identities_provider_subject (provider, subject),
+    INDEX idx_user_identities_user_id (user_id),
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE TABLE oidc_auth_requests
+(
+    state_hash    CHAR(64)     NOT NULL PRIMARY KEY,
+    provider      VARCHAR(50)  NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    nonce         VARCHAR(64)  NOT NULL,
+    expires_at    TIMESTAMP    NOT NULL,
+    INDEX idx_oidc_auth_requests_expires_at (expires_at)
+);
+
+COMMIT;
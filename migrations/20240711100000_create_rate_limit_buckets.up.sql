@@ -0,0 +1,11 @@
+START TRANSACTION;
+
+CREATE TABLE rate_limit_buckets
+(
+    bucket_key VARCHAR(255) NOT NULL PRIMARY KEY,
+    tokens     DOUBLE       NOT NULL,
+    updated_at TIMESTAMP(3) NOT NULL,
+    INDEX idx_rate_limit_buckets_updated_at (updated_at)
+);
+
+COMMIT;
@@ -0,0 +1,6 @@
+DROP INDEX idx_swipes_user_created ON swipes;
+
+ALTER TABLE users
+    DROP COLUMN plan,
+    DROP COLUMN plan_expires_at,
+    DROP COLUMN timezone;
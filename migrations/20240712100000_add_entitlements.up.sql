@@ -0,0 +1,7 @@
+ALTER TABLE users
+    ADD COLUMN plan            VARCHAR(20) NOT NULL DEFAULT 'free',
+    ADD COLUMN plan_expires_at TIMESTAMP   NULL,
+    ADD COLUMN timezone        VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+-- Daily quotas count a user's likes since their local midnight.
+CREATE INDEX idx_swipes_user_created ON swipes (user_id, created_at);
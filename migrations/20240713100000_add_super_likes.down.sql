@@ -0,0 +1,5 @@
+DROP INDEX idx_swipes_candidate_super_like ON swipes;
+
+ALTER TABLE swipes
+    DROP CHECK chk_swipes_super_like_likes,
+    DROP COLUMN super_like;
@@ -0,0 +1,7 @@
+-- A super like is always also a like, so everything treating likes as likes, such as matching, keeps working.
+ALTER TABLE swipes
+    ADD COLUMN super_like BOOL NOT NULL DEFAULT FALSE,
+    ADD CONSTRAINT chk_swipes_super_like_likes CHECK (likes OR NOT super_like);
+
+-- Finds who has super liked a user when ranking their discover results.
+CREATE INDEX idx_swipes_candidate_super_like ON swipes (candidate_id, super_like);
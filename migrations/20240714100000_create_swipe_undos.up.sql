@@ -0,0 +1,26 @@
+START TRANSACTION;
+
+CREATE TABLE swipe_undos
+(
+    id           INT AUTO_INCREMENT PRIMARY KEY,
+    user_id      INT       NOT NULL,
+    candidate_id INT       NOT NULL,
+    created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users (id),
+    INDEX idx_swipe_undos_user_created (user_id, created_at)
+);
+
+CREATE TABLE notifications
+(
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    user_id    INT         NOT NULL,
+    kind       VARCHAR(32) NOT NULL,
+    actor_id   INT         NULL,
+    created_at TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    read_at    TIMESTAMP   NULL,
+    FOREIGN KEY (user_id) REFERENCES users (id),
+    FOREIGN KEY (actor_id) REFERENCES users (id),
+    INDEX idx_notifications_user_created (user_id, created_at)
+);
+
+COMMIT;
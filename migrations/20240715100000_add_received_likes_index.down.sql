@@ -0,0 +1 @@
+DROP INDEX idx_swipes_candidate_likes ON swipes;
@@ -0,0 +1,3 @@
+-- Serves "who liked me", which looks up likes by recipient and pages through them by ID. InnoDB appends the primary key
+-- to secondary indexes, so the ID ordering comes for free.
+CREATE INDEX idx_swipes_candidate_likes ON swipes (candidate_id, likes);
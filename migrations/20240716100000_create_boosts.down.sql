@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS profile_impressions;
+DROP TABLE IF EXISTS boosts;
+
+ALTER TABLE users
+    DROP COLUMN boost_credits;
@@ -0,0 +1,30 @@
+START TRANSACTION;
+
+ALTER TABLE users
+    ADD COLUMN boost_credits INT NOT NULL DEFAULT 0;
+
+CREATE TABLE boosts
+(
+    id                   INT AUTO_INCREMENT PRIMARY KEY,
+    user_id              INT         NOT NULL,
+    source               VARCHAR(20) NOT NULL,
+    starts_at            TIMESTAMP   NOT NULL,
+    ends_at              TIMESTAMP   NOT NULL,
+    impressions          INT         NOT NULL DEFAULT 0,
+    baseline_impressions DOUBLE      NOT NULL DEFAULT 0,
+    baseline_likes       DOUBLE      NOT NULL DEFAULT 0,
+    FOREIGN KEY (user_id) REFERENCES users (id),
+    INDEX idx_boosts_user_ends (user_id, ends_at)
+);
+
+-- Daily totals of how often each user was shown in discover results, from which boost baselines are taken.
+CREATE TABLE profile_impressions
+(
+    user_id     INT  NOT NULL,
+    day         DATE NOT NULL,
+    impressions INT  NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, day),
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+COMMIT;
@@ -0,0 +1,4 @@
+ALTER TABLE users
+    DROP COLUMN travel_location,
+    DROP COLUMN travel_starts_at,
+    DROP COLUMN travel_ends_at;
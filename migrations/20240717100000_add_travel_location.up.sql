@@ -0,0 +1,4 @@
+ALTER TABLE users
+    ADD COLUMN travel_location  VARCHAR(255) NULL,
+    ADD COLUMN travel_starts_at TIMESTAMP    NULL,
+    ADD COLUMN travel_ends_at   TIMESTAMP    NULL;
@@ -0,0 +1,4 @@
+-- Locations may hold a geohash instead of "lat,long" when only coarse locations are stored, which fits the existing
+-- columns as is.
+ALTER TABLE users
+    ADD COLUMN location_updated_at TIMESTAMP NULL;
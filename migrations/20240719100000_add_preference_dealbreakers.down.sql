@@ -0,0 +1,8 @@
+ALTER TABLE user_preferences
+    DROP INDEX idx_user_preferences_user_id,
+    DROP COLUMN max_distance_km,
+    DROP COLUMN dealbreaker_age,
+    DROP COLUMN dealbreaker_children,
+    DROP COLUMN dealbreaker_education,
+    DROP COLUMN dealbreaker_distance,
+    DROP COLUMN dealbreaker_travel;
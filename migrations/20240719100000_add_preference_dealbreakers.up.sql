@@ -0,0 +1,14 @@
+-- Preferences were upserted without a unique key, so each update added a row while the first kept being read. Only the
+-- latest row per user is kept, and the key makes future upserts update in place.
+DELETE older
+FROM user_preferences older
+         JOIN user_preferences newer ON newer.user_id = older.user_id AND newer.id > older.id;
+
+ALTER TABLE user_preferences
+    ADD UNIQUE INDEX idx_user_preferences_user_id (user_id),
+    ADD COLUMN max_distance_km       INT     NOT NULL DEFAULT 0,
+    ADD COLUMN dealbreaker_age       BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN dealbreaker_children  BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN dealbreaker_education BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN dealbreaker_distance  BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN dealbreaker_travel    BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,16 @@
+START TRANSACTION;
+
+ALTER TABLE user_preferences
+    ADD COLUMN genders VARCHAR(255),
+    DROP COLUMN min_education_level;
+
+UPDATE user_preferences p
+SET p.genders = (SELECT GROUP_CONCAT(g.gender ORDER BY g.gender SEPARATOR ',')
+                 FROM user_preference_genders g
+                 WHERE g.user_id = p.user_id);
+
+DROP TABLE user_preference_education_levels;
+DROP TABLE user_preference_genders;
+DROP TABLE education_levels;
+
+COMMIT;
@@ -0,0 +1,53 @@
+START TRANSACTION;
+
+CREATE TABLE education_levels
+(
+    code  VARCHAR(50) PRIMARY KEY,
+    name  VARCHAR(100) NOT NULL,
+    level INT          NOT NULL UNIQUE
+);
+
+INSERT INTO education_levels (code, name, level)
+VALUES ('HS', 'High school', 1),
+       ('ASC', 'Associate degree', 2),
+       ('BSCH', 'Bachelor''s degree', 3),
+       ('MSCH', 'Master''s degree', 4),
+       ('PHD', 'Doctorate', 5);
+
+CREATE TABLE user_preference_genders
+(
+    user_id INT         NOT NULL,
+    gender  VARCHAR(50) NOT NULL,
+    PRIMARY KEY (user_id, gender),
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE TABLE user_preference_education_levels
+(
+    user_id         INT         NOT NULL,
+    education_level VARCHAR(50) NOT NULL,
+    PRIMARY KEY (user_id, education_level),
+    FOREIGN KEY (user_id) REFERENCES users (id),
+    FOREIGN KEY (education_level) REFERENCES education_levels (code)
+);
+
+-- Split the comma separated genders into rows.
+INSERT IGNORE INTO user_preference_genders (user_id, gender)
+SELECT p.user_id, TRIM(g.gender)
+FROM user_preferences p,
+     JSON_TABLE(CONCAT('["', REPLACE(REPLACE(p.genders, '"', ''), ',', '","'), '"]'), '$[*]'
+                COLUMNS (gender VARCHAR(255) PATH '$')) g
+WHERE p.genders IS NOT NULL
+  AND TRIM(g.gender) <> '';
+
+-- Education used to match only the user's own level, so that stays the one level accepted.
+INSERT INTO user_preference_education_levels (user_id, education_level)
+SELECT p.user_id, p.education_level
+FROM user_preferences p
+         JOIN education_levels l ON l.code = p.education_level;
+
+ALTER TABLE user_preferences
+    ADD COLUMN min_education_level VARCHAR(50) NOT NULL DEFAULT '',
+    DROP COLUMN genders;
+
+COMMIT;
@@ -0,0 +1,19 @@
+START TRANSACTION;
+
+DROP TABLE user_preference_show_me_to;
+
+UPDATE users
+SET gender = CASE gender WHEN 'Woman' THEN 'Female' WHEN 'Man' THEN 'Male' ELSE gender END;
+
+UPDATE IGNORE user_preference_genders
+SET gender = CASE gender WHEN 'Woman' THEN 'Female' WHEN 'Man' THEN 'Male' ELSE gender END;
+
+-- Longer genders don't fit the old column.
+UPDATE users
+SET gender = NULL
+WHERE CHAR_LENGTH(gender) > 10;
+
+ALTER TABLE users
+    MODIFY gender VARCHAR(10);
+
+COMMIT;
@@ -0,0 +1,17 @@
+START TRANSACTION;
+
+ALTER TABLE users
+    MODIFY gender VARCHAR(50);
+
+CREATE TABLE user_preference_show_me_to
+(
+    user_id INT         NOT NULL,
+    gender  VARCHAR(50) NOT NULL,
+    PRIMARY KEY (user_id, gender),
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+-- Existing values are brought onto the configured taxonomy by the canonicalise-genders command, as GENDERS and
+-- GENDER_ALIASES vary between deployments.
+
+COMMIT;
@@ -0,0 +1,2 @@
+ALTER TABLE oidc_auth_requests
+    DROP COLUMN link_user_id;
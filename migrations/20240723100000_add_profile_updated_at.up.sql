@@ -0,0 +1,2 @@
+ALTER TABLE users
+    ADD COLUMN profile_updated_at TIMESTAMP NULL;
@@ -0,0 +1,20 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Store persists binary objects, such as photos, under a key. The interface is deliberately the subset of operations an
+// S3 compatible object store offers, so that implementations are interchangeable.
+type Store interface {
+	// Put writes the object, replacing any existing object with the same key.
+	Put(ctx context.Context, key string, contentType string, body io.Reader) error
+	// Delete removes the object. Deleting a key that does not exist is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns the address clients should use to fetch the object.
+	URL(key string) string
+}
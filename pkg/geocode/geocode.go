@@ -0,0 +1,154 @@
+// Package geocode resolves coordinates to the nearest known city and city names to coordinates, entirely in process.
+// A small dataset of major cities is bundled, and a GeoNames cities dump, such as cities15000.txt, can be loaded in its
+// place for better coverage.
+package geocode
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// earthRadiusKm is the mean radius of the earth.
+const earthRadiusKm = 6371.0
+
+//go:embed cities.tsv
+var bundledCities string
+
+var ErrCityNotFound = errors.New("city not found")
+
+// City is a named place with its coordinates.
+type City struct {
+	Name        string
+	CountryCode string
+	Lat         float64
+	Lon         float64
+	Population  int
+}
+
+// Geocoder answers nearest city and city name lookups over a fixed set of cities.
+type Geocoder struct {
+	tree   *kdNode
+	byName map[string][]City
+}
+
+// NewBundled returns a geocoder over the bundled dataset of major cities.
+func NewBundled() (*Geocoder, error) {
+	return Load(strings.NewReader(bundledCities))
+}
+
+// Load reads cities, one per line, as tab separated values. Lines are either in the GeoNames dump format, or in the
+// bundled format of name, country code, latitude, longitude and population. Blank lines and those starting with "#"
+// are skipped.
+func Load(r io.Reader) (*Geocoder, error) {
+	var cities []City
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		city, err := parseCity(strings.Split(text, "\t"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		cities = append(cities, city)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read cities: %w", err)
+	}
+	if len(cities) == 0 {
+		return nil, errors.New("no cities loaded")
+	}
+
+	g := &Geocoder{byName: map[string][]City{}}
+	points := make([]kdPoint, 0, len(cities))
+	for _, c := range cities {
+		points = append(points, kdPoint{pos: toCartesian(c.Lat, c.Lon), city: c})
+		key := normaliseName(c.Name)
+		g.byName[key] = append(g.byName[key], c)
+	}
+	// The most populous city is preferred when a name is ambiguous.
+	for _, same := range g.byName {
+		sort.SliceStable(same, func(i, j int) bool { return same[i].Population > same[j].Population })
+	}
+	g.tree = buildKDTree(points, 0)
+	return g, nil
+}
+
+// parseCity reads a line's fields, by their count, as either format Load accepts.
+func parseCity(fields []string) (City, error) {
+	var name, country, lat, lon, population string
+	switch {
+	case len(fields) >= 19:
+		// GeoNames: geonameid, name, asciiname, alternatenames, latitude, longitude, feature class, feature code,
+		// country code, cc2, admin codes 1-4, population, ...
+		name, lat, lon, country, population = fields[2], fields[4], fields[5], fields[8], fields[14]
+	case len(fields) == 5:
+		name, country, lat, lon, population = fields[0], fields[1], fields[2], fields[3], fields[4]
+	default:
+		return City{}, fmt.Errorf("unexpected number of fields %d", len(fields))
+	}
+
+	c := City{Name: name, CountryCode: country}
+	var err error
+	c.Lat, err = strconv.ParseFloat(lat, 64)
+	if err != nil || c.Lat < -90 || c.Lat > 90 {
+		return City{}, errors.New("invalid latitude")
+	}
+	c.Lon, err = strconv.ParseFloat(lon, 64)
+	if err != nil || c.Lon < -180 || c.Lon > 180 {
+		return City{}, errors.New("invalid longitude")
+	}
+	if population != "" {
+		c.Population, err = strconv.Atoi(population)
+		if err != nil {
+			return City{}, errors.New("invalid population")
+		}
+	}
+	return c, nil
+}
+
+// Nearest returns the city closest to the given coordinates and its distance in km.
+func (g *Geocoder) Nearest(lat, lon float64) (City, float64) {
+	target := toCartesian(lat, lon)
+	best := g.tree.point
+	bestDist := math.Inf(1)
+	g.tree.nearest(target, &best, &bestDist)
+	// The tree measures chords through the unit sphere, which are converted back into distances along the surface.
+	return best.city, 2 * math.Asin(min(math.Sqrt(bestDist)/2, 1)) * earthRadiusKm
+}
+
+// Lookup finds a city by name, ignoring case. The name may be followed by a comma and a country code, as in
+// "Portland, US", to choose between cities of the same name. Otherwise the most populous is returned.
+func (g *Geocoder) Lookup(name string) (City, error) {
+	name, country, _ := strings.Cut(name, ",")
+	matches := g.byName[normaliseName(name)]
+	country = strings.TrimSpace(country)
+	for _, c := range matches {
+		if country == "" || strings.EqualFold(c.CountryCode, country) {
+			return c, nil
+		}
+	}
+	return City{}, ErrCityNotFound
+}
+
+func normaliseName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// toCartesian places coordinates on the unit sphere, where straight line distance orders points the same way as great
+// circle distance, so a plain k-d tree can be used.
+func toCartesian(lat, lon float64) [3]float64 {
+	latR, lonR := lat*math.Pi/180, lon*math.Pi/180
+	return [3]float64{math.Cos(latR) * math.Cos(lonR), math.Cos(latR) * math.Sin(lonR), math.Sin(latR)}
+}
@@ -0,0 +1,200 @@
+package geocode
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// greatCircleKm is the haversine distance between two points, computed independently of the tree's chord distances.
+func greatCircleKm(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat, dLon := (lat2-lat1)*rad, (lon2-lon1)*rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// allCities returns every city a geocoder was loaded with.
+func allCities(g *Geocoder) []City {
+	var cities []City
+	for _, same := range g.byName {
+		cities = append(cities, same...)
+	}
+	return cities
+}
+
+func TestNearestMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	// A random dataset, dense enough that the tree is several levels deep, alongside the bundled one.
+	var sb strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&sb, "city%d\tXX\t%.4f\t%.4f\t%d\n", i, rng.Float64()*180-90, rng.Float64()*360-180, i)
+	}
+	random, err := Load(strings.NewReader(sb.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundled, err := NewBundled()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, g := range map[string]*Geocoder{"random": random, "bundled": bundled} {
+		t.Run(name, func(t *testing.T) {
+			cities := allCities(g)
+			for i := 0; i < 1000; i++ {
+				lat, lon := rng.Float64()*180-90, rng.Float64()*360-180
+				want := math.Inf(1)
+				for _, c := range cities {
+					want = min(want, greatCircleKm(lat, lon, c.Lat, c.Lon))
+				}
+
+				got, km := g.Nearest(lat, lon)
+				// Compared by distance, as two cities may be equally near.
+				if math.Abs(km-want) > 0.01 {
+					t.Fatalf("Nearest(%v, %v) = %s at %vkm, want a city at %vkm", lat, lon, got.Name, km, want)
+				}
+				if d := greatCircleKm(lat, lon, got.Lat, got.Lon); math.Abs(d-km) > 0.01 {
+					t.Fatalf("Nearest(%v, %v) reported %vkm to %s, which is %vkm away", lat, lon, km, got.Name, d)
+				}
+			}
+		})
+	}
+}
+
+func TestNearest(t *testing.T) {
+	g, err := Load(strings.NewReader("London\tGB\t51.5074\t-0.1278\t8982000\n" +
+		"Paris\tFR\t48.8566\t2.3522\t2161000\n" +
+		"Suva\tFJ\t-18.1416\t178.4419\t93970\n" +
+		"Apia\tWS\t-13.8333\t-171.7500\t37708\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     string
+	}{
+		{name: "at a city", lat: 51.5074, lon: -0.1278, want: "London"},
+		{name: "between", lat: 49.5, lon: 2, want: "Paris"},
+		{name: "across the antimeridian", lat: -16, lon: -179.5, want: "Suva"},
+		{name: "pole", lat: 90, lon: 0, want: "London"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, km := g.Nearest(tt.lat, tt.lon)
+			if got.Name != tt.want {
+				t.Errorf("Nearest(%v, %v) = %s, want %s", tt.lat, tt.lon, got.Name, tt.want)
+			}
+			if want := greatCircleKm(tt.lat, tt.lon, got.Lat, got.Lon); math.Abs(km-want) > 0.01 {
+				t.Errorf("Nearest(%v, %v) distance = %v, want %v", tt.lat, tt.lon, km, want)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	g, err := Load(strings.NewReader("Portland\tUS\t45.5152\t-122.6784\t652503\n" +
+		"Portland\tAU\t-38.3440\t141.6040\t9712\n" +
+		"New York\tUS\t40.7128\t-74.0060\t8336817\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		country string
+		err     error
+	}{
+		{name: "most populous", query: "Portland", country: "US"},
+		{name: "ignores case", query: "portland", country: "US"},
+		{name: "with country", query: "Portland, AU", country: "AU"},
+		{name: "country ignores case", query: "Portland, au", country: "AU"},
+		{name: "extra whitespace", query: "  new   york ,  us ", country: "US"},
+		{name: "unknown country", query: "Portland, GB", err: ErrCityNotFound},
+		{name: "unknown city", query: "Atlantis", err: ErrCityNotFound},
+		{name: "empty", query: "", err: ErrCityNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := g.Lookup(tt.query)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Lookup(%q) error = %v, want %v", tt.query, err, tt.err)
+			}
+			if got.CountryCode != tt.country {
+				t.Errorf("Lookup(%q) = %+v, want the city in %q", tt.query, got, tt.country)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	geonames := strings.Join([]string{"2643743", "London", "London", "Londres,Londra", "51.50853", "-0.12574", "P",
+		"PPLC", "GB", "", "ENG", "GLA", "", "", "8961989", "", "25", "Europe/London", "2019-09-18"}, "\t")
+
+	tests := []struct {
+		name    string
+		data    string
+		want    City
+		wantErr string
+	}{
+		{
+			name: "bundled format",
+			data: "# name\tcountry_code\tlatitude\tlongitude\tpopulation\n\nParis\tFR\t48.8566\t2.3522\t2161000\n",
+			want: City{Name: "Paris", CountryCode: "FR", Lat: 48.8566, Lon: 2.3522, Population: 2161000},
+		},
+		{
+			name: "geonames format uses the ascii name",
+			data: geonames + "\n",
+			want: City{Name: "London", CountryCode: "GB", Lat: 51.50853, Lon: -0.12574, Population: 8961989},
+		},
+		{
+			name: "population may be empty",
+			data: "Nowhere\tXX\t0\t0\t\n",
+			want: City{Name: "Nowhere", CountryCode: "XX"},
+		},
+		{name: "no cities", data: "# nothing here\n\n", wantErr: "no cities loaded"},
+		{name: "wrong field count", data: "Paris\tFR\t48.8566\n", wantErr: "line 1: unexpected number of fields 3"},
+		{name: "latitude out of range", data: "\nX\tXX\t91\t0\t1\n", wantErr: "line 2: invalid latitude"},
+		{name: "bad longitude", data: "X\tXX\t0\teast\t1\n", wantErr: "line 1: invalid longitude"},
+		{name: "longitude out of range", data: "X\tXX\t0\t-180.5\t1\n", wantErr: "line 1: invalid longitude"},
+		{name: "bad population", data: "X\tXX\t0\t0\tmany\n", wantErr: "line 1: invalid population"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := Load(strings.NewReader(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			got, err := g.Lookup(tt.want.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("loaded %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewBundled(t *testing.T) {
+	g, err := NewBundled()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(allCities(g)); n < 100 {
+		t.Errorf("bundled dataset has %d cities", n)
+	}
+}
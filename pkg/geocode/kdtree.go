@@ -0,0 +1,58 @@
+package geocode
+
+import "sort"
+
+type kdPoint struct {
+	pos  [3]float64
+	city City
+}
+
+// kdNode is a node of a 3 dimensional k-d tree, splitting on axis depth % 3.
+type kdNode struct {
+	point       kdPoint
+	axis        int
+	left, right *kdNode
+}
+
+// buildKDTree builds a balanced tree by splitting on the median at each level. points is reordered.
+func buildKDTree(points []kdPoint, depth int) *kdNode {
+	if len(points) == 0 {
+		return nil
+	}
+	axis := depth % 3
+	sort.Slice(points, func(i, j int) bool { return points[i].pos[axis] < points[j].pos[axis] })
+	mid := len(points) / 2
+	return &kdNode{
+		point: points[mid],
+		axis:  axis,
+		left:  buildKDTree(points[:mid], depth+1),
+		right: buildKDTree(points[mid+1:], depth+1),
+	}
+}
+
+// nearest searches the subtree for a point closer to target than best, whose squared distance is bestDist.
+func (n *kdNode) nearest(target [3]float64, best *kdPoint, bestDist *float64) {
+	if n == nil {
+		return
+	}
+	d := squaredDistance(n.point.pos, target)
+	if d < *bestDist {
+		*best, *bestDist = n.point, d
+	}
+
+	diff := target[n.axis] - n.point.pos[n.axis]
+	near, far := n.left, n.right
+	if diff > 0 {
+		near, far = n.right, n.left
+	}
+	near.nearest(target, best, bestDist)
+	// The far side can only hold a closer point if the splitting plane is nearer than the best found so far.
+	if diff*diff < *bestDist {
+		far.nearest(target, best, bestDist)
+	}
+}
+
+func squaredDistance(a, b [3]float64) float64 {
+	dx, dy, dz := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dx*dx + dy*dy + dz*dz
+}
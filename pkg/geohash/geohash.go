@@ -0,0 +1,89 @@
+// Package geohash encodes coordinates as geohashes, strings naming a cell of the earth's surface which shrinks as the
+// string gets longer. Five characters is a cell of roughly 5km square.
+package geohash
+
+import (
+	"errors"
+	"strings"
+)
+
+const alphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// MaxPrecision is the longest geohash Encode produces, a cell a few centimetres across.
+const MaxPrecision = 12
+
+var ErrInvalidGeohash = errors.New("invalid geohash")
+
+// Encode returns the geohash of the given precision, in characters, for a latitude and longitude. Precision is clamped
+// to 1-MaxPrecision.
+func Encode(lat, lon float64, precision int) string {
+	precision = min(max(precision, 1), MaxPrecision)
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+
+	var sb strings.Builder
+	bits, ch := 0, 0
+	even := true
+	for sb.Len() < precision {
+		// Bits alternate between longitude and latitude, starting with longitude.
+		if even {
+			mid := (lonRange[0] + lonRange[1]) / 2
+			if lon >= mid {
+				ch = ch<<1 | 1
+				lonRange[0] = mid
+			} else {
+				ch <<= 1
+				lonRange[1] = mid
+			}
+		} else {
+			mid := (latRange[0] + latRange[1]) / 2
+			if lat >= mid {
+				ch = ch<<1 | 1
+				latRange[0] = mid
+			} else {
+				ch <<= 1
+				latRange[1] = mid
+			}
+		}
+		even = !even
+
+		bits++
+		if bits == 5 {
+			sb.WriteByte(alphabet[ch])
+			bits, ch = 0, 0
+		}
+	}
+	return sb.String()
+}
+
+// Decode returns the latitude and longitude of the centre of a geohash's cell.
+func Decode(hash string) (float64, float64, error) {
+	if hash == "" || len(hash) > MaxPrecision {
+		return 0, 0, ErrInvalidGeohash
+	}
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+
+	even := true
+	for _, c := range strings.ToLower(hash) {
+		idx := strings.IndexRune(alphabet, c)
+		if idx < 0 {
+			return 0, 0, ErrInvalidGeohash
+		}
+		for bit := 4; bit >= 0; bit-- {
+			set := idx>>bit&1 == 1
+			r := &latRange
+			if even {
+				r = &lonRange
+			}
+			mid := (r[0] + r[1]) / 2
+			if set {
+				r[0] = mid
+			} else {
+				r[1] = mid
+			}
+			even = !even
+		}
+	}
+	return (latRange[0] + latRange[1]) / 2, (lonRange[0] + lonRange[1]) / 2, nil
+}
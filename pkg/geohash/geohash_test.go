@@ -0,0 +1,107 @@
+package geohash
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name      string
+		lat, lon  float64
+		precision int
+		want      string
+	}{
+		{name: "jutland", lat: 57.64911, lon: 10.40744, precision: 11, want: "u4pruydqqvj"},
+		{name: "jutland coarse", lat: 57.64911, lon: 10.40744, precision: 5, want: "u4pru"},
+		{name: "leon", lat: 42.605, lon: -5.603, precision: 5, want: "ezs42"},
+		{name: "origin", lat: 0, lon: 0, precision: 6, want: "s00000"},
+		{name: "south west corner", lat: -90, lon: -180, precision: 4, want: "0000"},
+		{name: "north east corner", lat: 90, lon: 180, precision: 4, want: "zzzz"},
+		{name: "precision clamped up", lat: 57.64911, lon: 10.40744, precision: 0, want: "u"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Encode(tt.lat, tt.lon, tt.precision)
+			if got != tt.want {
+				t.Errorf("Encode(%v, %v, %d) = %q, want %q", tt.lat, tt.lon, tt.precision, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeClampsPrecision(t *testing.T) {
+	got := Encode(57.64911, 10.40744, 20)
+	if len(got) != MaxPrecision || got[:11] != "u4pruydqqvj" {
+		t.Errorf("Encode at precision 20 = %q, want %d characters starting u4pruydqqvj", got, MaxPrecision)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		hash     string
+		lat, lon float64
+		// tolerance is the allowed error in degrees.
+		tolerance float64
+		err       error
+	}{
+		{name: "leon", hash: "ezs42", lat: 42.605, lon: -5.603, tolerance: 0.001},
+		{name: "upper case", hash: "EZS42", lat: 42.605, lon: -5.603, tolerance: 0.001},
+		{name: "jutland", hash: "u4pruydqqvj", lat: 57.64911, lon: 10.40744, tolerance: 0.00001},
+		{name: "one character", hash: "s", lat: 22.5, lon: 22.5, tolerance: 0},
+		{name: "empty", hash: "", err: ErrInvalidGeohash},
+		{name: "too long", hash: "u4pruydqqvj2u", err: ErrInvalidGeohash},
+		{name: "letter a", hash: "ezsa2", err: ErrInvalidGeohash},
+		{name: "letter i", hash: "ezsi2", err: ErrInvalidGeohash},
+		{name: "letter l", hash: "ezsl2", err: ErrInvalidGeohash},
+		{name: "letter o", hash: "ezso2", err: ErrInvalidGeohash},
+		{name: "comma", hash: "42.6,-5.6", err: ErrInvalidGeohash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lat, lon, err := Decode(tt.hash)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Decode(%q) error = %v, want %v", tt.hash, err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if math.Abs(lat-tt.lat) > tt.tolerance || math.Abs(lon-tt.lon) > tt.tolerance {
+				t.Errorf("Decode(%q) = %v, %v, want %v, %v", tt.hash, lat, lon, tt.lat, tt.lon)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	points := []struct{ lat, lon float64 }{
+		{51.5074, -0.1278},
+		{-33.8688, 151.2093},
+		{40.7128, -74.0060},
+		{-54.8019, -68.3030},
+		{89.9999, 179.9999},
+	}
+	for _, p := range points {
+		for precision := 1; precision <= MaxPrecision; precision++ {
+			hash := Encode(p.lat, p.lon, precision)
+			lat, lon, err := Decode(hash)
+			if err != nil {
+				t.Fatalf("Decode(%q): %v", hash, err)
+			}
+
+			// The centre of a cell is at most half its size from any point in it. Each character halves longitude
+			// and latitude 5 times between them, starting with longitude.
+			bits := 5 * precision
+			lonErr := 180 / math.Pow(2, float64((bits+1)/2))
+			latErr := 90 / math.Pow(2, float64(bits/2))
+			if math.Abs(lat-p.lat) > latErr || math.Abs(lon-p.lon) > lonErr {
+				t.Errorf("%v,%v at precision %d: centre %v,%v is outside the cell", p.lat, p.lon, precision, lat, lon)
+			}
+			if again := Encode(lat, lon, precision); again != hash {
+				t.Errorf("%v,%v at precision %d: centre encodes to %q, want %q", p.lat, p.lon, precision, again, hash)
+			}
+		}
+	}
+}
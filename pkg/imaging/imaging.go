@@ -0,0 +1,163 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	// MaxDimension is the longest edge a stored photo may have. Larger uploads are scaled down.
+	MaxDimension = 2048
+	// ThumbnailDimension is the longest edge of a generated thumbnail.
+	ThumbnailDimension = 320
+	// maxPixels guards against decompression bombs, where a small file declares an enormous canvas.
+	maxPixels   = 50_000_000
+	jpegQuality = 85
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrImageTooLarge     = errors.New("image dimensions too large")
+)
+
+// Processed is the result of preparing an uploaded photo for storage.
+type Processed struct {
+	ContentType string
+	Image       []byte
+	Thumbnail   []byte
+	Width       int
+	Height      int
+}
+
+// ProcessPhoto validates an uploaded photo by its content rather than any client supplied type, then re-encodes it
+// along with a thumbnail. Re-encoding drops all metadata, including EXIF GPS coordinates. The EXIF orientation is
+// applied to the pixels first, so the photo still displays the right way up without it.
+func ProcessPhoto(data []byte) (Processed, error) {
+	contentType := http.DetectContentType(data)
+	if contentType != "image/jpeg" && contentType != "image/png" {
+		return Processed{}, fmt.Errorf("%w: %s", ErrUnsupportedFormat, contentType)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Processed{}, fmt.Errorf("%w: %w", ErrUnsupportedFormat, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return Processed{}, ErrImageTooLarge
+	}
+
+	var src image.Image
+	if contentType == "image/jpeg" {
+		src, err = jpeg.Decode(bytes.NewReader(data))
+	} else {
+		src, err = png.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return Processed{}, fmt.Errorf("%w: %w", ErrUnsupportedFormat, err)
+	}
+
+	img := toRGBA(src)
+	if contentType == "image/jpeg" {
+		img = applyOrientation(img, exifOrientation(data))
+	}
+
+	img = fit(img, MaxDimension)
+	thumb := fit(img, ThumbnailDimension)
+
+	encodedImg, err := encode(img, contentType)
+	if err != nil {
+		return Processed{}, err
+	}
+	encodedThumb, err := encode(thumb, contentType)
+	if err != nil {
+		return Processed{}, err
+	}
+
+	return Processed{
+		ContentType: contentType,
+		Image:       encodedImg,
+		Thumbnail:   encodedThumb,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+	}, nil
+}
+
+func encode(img image.Image, contentType string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	var err error
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(buf, img, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(buf, img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func toRGBA(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// fit scales an image down, preserving aspect ratio, so that neither edge exceeds maxEdge. Images already small enough
+// are returned as is.
+func fit(src *image.RGBA, maxEdge int) *image.RGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	if w <= maxEdge && h <= maxEdge {
+		return src
+	}
+
+	dw, dh := maxEdge, maxEdge
+	if w > h {
+		dh = max(1, h*maxEdge/w)
+	} else {
+		dw = max(1, w*maxEdge/h)
+	}
+	return boxResize(src, dw, dh)
+}
+
+// boxResize downsamples by averaging every source pixel that falls within each destination pixel. This is only suitable
+// for shrinking, which is all that is needed here.
+func boxResize(src *image.RGBA, dw int, dh int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for dy := 0; dy < dh; dy++ {
+		y0 := dy * sh / dh
+		y1 := max(y0+1, (dy+1)*sh/dh)
+		for dx := 0; dx < dw; dx++ {
+			x0 := dx * sw / dw
+			x1 := max(x0+1, (dx+1)*sw/dw)
+
+			var r, g, b, a, n uint64
+			for y := y0; y < y1; y++ {
+				row := src.Pix[y*src.Stride:]
+				for x := x0; x < x1; x++ {
+					p := row[x*4 : x*4+4]
+					r += uint64(p[0])
+					g += uint64(p[1])
+					b += uint64(p[2])
+					a += uint64(p[3])
+					n++
+				}
+			}
+
+			o := dst.Pix[dy*dst.Stride+dx*4:]
+			o[0] = uint8(r / n)
+			o[1] = uint8(g / n)
+			o[2] = uint8(b / n)
+			o[3] = uint8(a / n)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,360 @@
+// Package oidc is a minimal OpenID Connect relying party, supporting the authorization code flow with PKCE and RS256
+// signed ID tokens.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// clockSkew is tolerated when checking ID token times.
+	clockSkew = time.Minute
+	// minKeyRefresh limits how often an unknown key ID can trigger a JWKS refetch.
+	minKeyRefresh = time.Minute
+)
+
+var ErrInvalidIDToken = errors.New("invalid id token")
+
+// ProviderConfig describes a relying party registration with an identity provider.
+type ProviderConfig struct {
+	// Name identifies the provider in routes and stored identities, e.g. "google".
+	Name      string
+	IssuerURL string
+	ClientID  string
+	// ClientSecret may be empty for public clients, which rely on PKCE alone.
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// IDTokenClaims are the claims read from a verified ID token.
+type IDTokenClaims struct {
+	Issuer        string `json:"iss"`
+	Subject       string `json:"sub"`
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	ExpiresAt     int64  `json:"exp"`
+	IssuedAt      int64  `json:"iat"`
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider talks to a single identity provider. Its discovery document and keys are fetched lazily and cached, so it
+// can be created before the provider is reachable.
+type Provider struct {
+	cfg    ProviderConfig
+	client *http.Client
+
+	mu            sync.Mutex
+	discovery     *discoveryDocument
+	keys          map[string]*rsa.PublicKey
+	keysFetchedAt time.Time
+}
+
+// NewProvider returns a Provider for the given registration.
+func NewProvider(cfg ProviderConfig, client *http.Client) (*Provider, error) {
+	if cfg.Name == "" || cfg.IssuerURL == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("oidc provider needs a name, issuer, client id and redirect url")
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{cfg: cfg, client: client}, nil
+}
+
+// Name returns the provider's configured name.
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// NewPKCE returns a random PKCE code verifier and its S256 challenge.
+func NewPKCE() (verifier string, challenge string, err error) {
+	raw := make([]byte, 32)
+	_, err = rand.Read(raw)
+	if err != nil {
+		return "", "", fmt.Errorf("generate code verifier: %w", err)
+	}
+	verifier = base64.RawURLEncoding.EncodeToString(raw)
+	return verifier, PKCEChallenge(verifier), nil
+}
+
+// PKCEChallenge returns the S256 challenge for a code verifier.
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the URL to send the user to in order to sign in with the provider.
+func (p *Provider) AuthCodeURL(ctx context.Context, state string, nonce string, codeChallenge string) (string, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange redeems an authorization code, and verifies the returned ID token, including that it carries the nonce
+// sent with the authorization request.
+func (p *Provider) Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (IDTokenClaims, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return IDTokenClaims{}, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	form.Set("client_id", p.cfg.ClientID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return IDTokenClaims{}, fmt.Errorf("create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return IDTokenClaims{}, fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return IDTokenClaims{}, fmt.Errorf("read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return IDTokenClaims{}, fmt.Errorf("token request: status %d: %s", resp.StatusCode, body)
+	}
+
+	tokenResp := struct {
+		IDToken string `json:"id_token"`
+	}{}
+	err = json.Unmarshal(body, &tokenResp)
+	if err != nil {
+		return IDTokenClaims{}, fmt.Errorf("decode token response: %w", err)
+	}
+	if tokenResp.IDToken == "" {
+		return IDTokenClaims{}, errors.New("token response has no id_token")
+	}
+
+	claims, err := p.verifyIDToken(ctx, d, tokenResp.IDToken)
+	if err != nil {
+		return IDTokenClaims{}, err
+	}
+	if claims.Nonce != nonce {
+		return IDTokenClaims{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	return claims, nil
+}
+
+func (p *Provider) verifyIDToken(ctx context.Context, d *discoveryDocument, token string) (IDTokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return IDTokenClaims{}, fmt.Errorf("%w: malformed", ErrInvalidIDToken)
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return IDTokenClaims{}, fmt.Errorf("%w: decode header", ErrInvalidIDToken)
+	}
+	header := struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}{}
+	err = json.Unmarshal(rawHeader, &header)
+	if err != nil {
+		return IDTokenClaims{}, fmt.Errorf("%w: decode header", ErrInvalidIDToken)
+	}
+	if header.Algorithm != "RS256" {
+		return IDTokenClaims{}, fmt.Errorf("%w: unsupported alg %q", ErrInvalidIDToken, header.Algorithm)
+	}
+
+	key, err := p.getKey(ctx, d, header.KeyID)
+	if err != nil {
+		return IDTokenClaims{}, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return IDTokenClaims{}, fmt.Errorf("%w: decode signature", ErrInvalidIDToken)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig)
+	if err != nil {
+		return IDTokenClaims{}, fmt.Errorf("%w: bad signature", ErrInvalidIDToken)
+	}
+
+	rawClaims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return IDTokenClaims{}, fmt.Errorf("%w: decode claims", ErrInvalidIDToken)
+	}
+	claims := IDTokenClaims{}
+	err = json.Unmarshal(rawClaims, &claims)
+	if err != nil {
+		return IDTokenClaims{}, fmt.Errorf("%w: decode claims", ErrInvalidIDToken)
+	}
+	// aud may be a string or an array, so is read separately.
+	audience := struct {
+		Audience json.RawMessage `json:"aud"`
+	}{}
+	err = json.Unmarshal(rawClaims, &audience)
+	if err != nil || !audienceContains(audience.Audience, p.cfg.ClientID) {
+		return IDTokenClaims{}, fmt.Errorf("%w: wrong audience", ErrInvalidIDToken)
+	}
+
+	if claims.Issuer != d.Issuer {
+		return IDTokenClaims{}, fmt.Errorf("%w: wrong issuer", ErrInvalidIDToken)
+	}
+	now := time.Now()
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)) {
+		return IDTokenClaims{}, fmt.Errorf("%w: expired", ErrInvalidIDToken)
+	}
+	if claims.Subject == "" {
+		return IDTokenClaims{}, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+	return claims, nil
+}
+
+func audienceContains(raw json.RawMessage, clientID string) bool {
+	var single string
+	if json.Unmarshal(raw, &single) == nil {
+		return single == clientID
+	}
+	var many []string
+	if json.Unmarshal(raw, &many) == nil {
+		for _, a := range many {
+			if a == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *Provider) getDiscovery(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	d := &discoveryDocument{}
+	err := p.getJSON(ctx, strings.TrimSuffix(p.cfg.IssuerURL, "/")+"/.well-known/openid-configuration", d)
+	if err != nil {
+		return nil, fmt.Errorf("fetch discovery document: %w", err)
+	}
+	if d.Issuer != p.cfg.IssuerURL {
+		return nil, fmt.Errorf("discovery document issuer %q does not match %q", d.Issuer, p.cfg.IssuerURL)
+	}
+	p.discovery = d
+	return d, nil
+}
+
+// getKey returns the provider's signing key with the given ID, refetching the key set if it isn't known, as the
+// provider may have rotated keys.
+func (p *Provider) getKey(ctx context.Context, d *discoveryDocument, keyID string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[keyID]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < minKeyRefresh {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidIDToken, keyID)
+	}
+
+	jwks := struct {
+		Keys []struct {
+			KeyType string `json:"kty"`
+			KeyID   string `json:"kid"`
+			N       string `json:"n"`
+			E       string `json:"e"`
+		} `json:"keys"`
+	}{}
+	err := p.getJSON(ctx, d.JWKSURI, &jwks)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range jwks.Keys {
+		if k.KeyType != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.KeyID] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	key, ok := keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidIDToken, keyID)
+	}
+	return key, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d from %s", resp.StatusCode, u)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
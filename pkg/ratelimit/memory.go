@@ -0,0 +1,46 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often idle buckets are removed from a MemoryStore.
+const sweepInterval = time.Minute
+
+type memoryBucket struct {
+	Bucket
+	// fullAt is when the bucket will have refilled, after which it is the same as no bucket at all.
+	fullAt time.Time
+}
+
+// MemoryStore keeps buckets in process. Limits are per replica, so with several replicas the effective limit is
+// multiplied by their number.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]memoryBucket
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]memoryBucket{}}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > sweepInterval {
+		for k, b := range s.buckets {
+			if !now.Before(b.fullAt) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	b, res := Take(s.buckets[key].Bucket, limit, now)
+	s.buckets[key] = memoryBucket{Bucket: b, fullAt: now.Add(res.ResetAfter)}
+	return res, nil
+}
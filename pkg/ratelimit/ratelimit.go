@@ -0,0 +1,75 @@
+// Package ratelimit implements token bucket rate limiting over pluggable storage.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit allows Rate requests every Per on average, with bursts of up to Burst requests.
+type Limit struct {
+	Rate  int
+	Per   time.Duration
+	Burst int
+}
+
+// tokensPerSecond is how quickly the bucket refills.
+func (l Limit) tokensPerSecond() float64 {
+	return float64(l.Rate) / l.Per.Seconds()
+}
+
+// Bucket is the stored state of one key's bucket.
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// Result describes the outcome of taking a token.
+type Result struct {
+	Allowed bool
+	// Limit is the bucket's capacity.
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until a token is next available. Zero when Allowed.
+	RetryAfter time.Duration
+	// ResetAfter is how long until the bucket is full again.
+	ResetAfter time.Duration
+}
+
+// Store keeps buckets, keyed by whatever is being limited. Take must refill and take from a bucket atomically.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// Take refills a bucket for the time passed since it was last updated and tries to take a token from it, returning the
+// new state. A zero Bucket is treated as full. Stores use this so they only differ in how state is kept.
+func Take(b Bucket, limit Limit, now time.Time) (Bucket, Result) {
+	rate := limit.tokensPerSecond()
+	burst := float64(limit.Burst)
+
+	tokens := burst
+	if !b.UpdatedAt.IsZero() {
+		elapsed := now.Sub(b.UpdatedAt).Seconds()
+		if elapsed < 0 {
+			elapsed = 0
+		}
+		tokens = math.Min(burst, b.Tokens+elapsed*rate)
+	}
+
+	res := Result{Limit: limit.Burst}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = secondsToDuration((1 - tokens) / rate)
+	}
+	res.Remaining = int(math.Floor(tokens))
+	res.ResetAfter = secondsToDuration((burst - tokens) / rate)
+
+	return Bucket{Tokens: tokens, UpdatedAt: now}, res
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
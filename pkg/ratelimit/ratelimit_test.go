@@ -0,0 +1,185 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testLimit refills one token every 6 seconds, holding up to 3.
+var testLimit = Limit{Rate: 10, Per: time.Minute, Burst: 3}
+
+func TestTake(t *testing.T) {
+	start := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		bucket Bucket
+		now    time.Time
+		want   Result
+		tokens float64
+	}{
+		{
+			name:   "zero bucket is full",
+			bucket: Bucket{},
+			now:    start,
+			want:   Result{Allowed: true, Limit: 3, Remaining: 2, ResetAfter: 6 * time.Second},
+			tokens: 2,
+		},
+		{
+			name:   "last token",
+			bucket: Bucket{Tokens: 1, UpdatedAt: start},
+			now:    start,
+			want:   Result{Allowed: true, Limit: 3, Remaining: 0, ResetAfter: 18 * time.Second},
+			tokens: 0,
+		},
+		{
+			name:   "empty",
+			bucket: Bucket{Tokens: 0, UpdatedAt: start},
+			now:    start,
+			want:   Result{Allowed: false, Limit: 3, Remaining: 0, RetryAfter: 6 * time.Second, ResetAfter: 18 * time.Second},
+			tokens: 0,
+		},
+		{
+			name:   "partly refilled but not enough",
+			bucket: Bucket{Tokens: 0, UpdatedAt: start},
+			now:    start.Add(3 * time.Second),
+			want:   Result{Allowed: false, Limit: 3, Remaining: 0, RetryAfter: 3 * time.Second, ResetAfter: 15 * time.Second},
+			tokens: 0.5,
+		},
+		{
+			name:   "refilled exactly one token",
+			bucket: Bucket{Tokens: 0, UpdatedAt: start},
+			now:    start.Add(6 * time.Second),
+			want:   Result{Allowed: true, Limit: 3, Remaining: 0, ResetAfter: 18 * time.Second},
+			tokens: 0,
+		},
+		{
+			name:   "refill is capped at burst",
+			bucket: Bucket{Tokens: 0, UpdatedAt: start},
+			now:    start.Add(24 * time.Hour),
+			want:   Result{Allowed: true, Limit: 3, Remaining: 2, ResetAfter: 6 * time.Second},
+			tokens: 2,
+		},
+		{
+			name:   "clock going backwards doesn't refill or drain",
+			bucket: Bucket{Tokens: 1.5, UpdatedAt: start},
+			now:    start.Add(-time.Hour),
+			want:   Result{Allowed: true, Limit: 3, Remaining: 0, ResetAfter: 15 * time.Second},
+			tokens: 0.5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, got := Take(tt.bucket, testLimit, tt.now)
+			if got != tt.want {
+				t.Errorf("Take() result = %+v, want %+v", got, tt.want)
+			}
+			if diff := b.Tokens - tt.tokens; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("Take() tokens = %v, want %v", b.Tokens, tt.tokens)
+			}
+			if !b.UpdatedAt.Equal(tt.now) {
+				t.Errorf("Take() UpdatedAt = %v, want %v", b.UpdatedAt, tt.now)
+			}
+		})
+	}
+}
+
+func TestTakeSequence(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	var b Bucket
+	var res Result
+
+	// A full burst is allowed straight away, then the next request is refused.
+	for i := 0; i < testLimit.Burst; i++ {
+		b, res = Take(b, testLimit, now)
+		if !res.Allowed {
+			t.Fatalf("request %d refused within burst", i+1)
+		}
+	}
+	b, res = Take(b, testLimit, now)
+	if res.Allowed {
+		t.Fatal("request beyond burst allowed")
+	}
+
+	// Waiting RetryAfter is enough for exactly one more.
+	now = now.Add(res.RetryAfter)
+	b, res = Take(b, testLimit, now)
+	if !res.Allowed {
+		t.Fatal("request refused after waiting RetryAfter")
+	}
+	_, res = Take(b, testLimit, now)
+	if res.Allowed {
+		t.Fatal("second request allowed after only one token refilled")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+
+	for i := 0; i < testLimit.Burst; i++ {
+		res, err := s.Take(ctx, "a", testLimit, now)
+		if err != nil || !res.Allowed {
+			t.Fatalf("Take(a) #%d = %+v, %v", i+1, res, err)
+		}
+	}
+	res, err := s.Take(ctx, "a", testLimit, now)
+	if err != nil || res.Allowed {
+		t.Fatalf("Take(a) beyond burst = %+v, %v", res, err)
+	}
+
+	// Keys have their own buckets.
+	res, err = s.Take(ctx, "b", testLimit, now)
+	if err != nil || !res.Allowed {
+		t.Fatalf("Take(b) = %+v, %v", res, err)
+	}
+
+	// Once refilled, idle buckets are swept, which is the same as them being full.
+	later := now.Add(time.Hour)
+	res, err = s.Take(ctx, "c", testLimit, later)
+	if err != nil || !res.Allowed {
+		t.Fatalf("Take(c) = %+v, %v", res, err)
+	}
+	if _, ok := s.buckets["a"]; ok {
+		t.Error("refilled bucket a was not swept")
+	}
+	res, err = s.Take(ctx, "a", testLimit, later)
+	if err != nil || !res.Allowed || res.Remaining != testLimit.Burst-1 {
+		t.Errorf("Take(a) after sweep = %+v, %v, want a full bucket", res, err)
+	}
+}
+
+func TestMemoryStoreConcurrent(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	s := NewMemoryStore()
+	limit := Limit{Rate: 1, Per: time.Hour, Burst: 10}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := s.Take(ctx, "key", limit, now)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if res.Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != limit.Burst {
+		t.Errorf("%d concurrent requests allowed, want %d", allowed, limit.Burst)
+	}
+}
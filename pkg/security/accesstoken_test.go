@@ -0,0 +1,279 @@
+package security
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testHMACKey    = bytes.Repeat([]byte{1}, 32)
+	testEd25519Key = bytes.Repeat([]byte{2}, 32)
+)
+
+func testClaims(now time.Time) AccessTokenClaims {
+	return AccessTokenClaims{
+		ID:        "token-1",
+		UserID:    42,
+		Role:      "admin",
+		IssuedAt:  now,
+		ExpiresAt: now.Add(15 * time.Minute),
+	}
+}
+
+// testCodecs covers every supported format and algorithm combination.
+func testCodecs(t *testing.T) map[string]*AccessTokenCodec {
+	t.Helper()
+	return map[string]*AccessTokenCodec{
+		"jwt hs256":      mustCodec(t, AccessTokenFormatJWT, SigningAlgorithmHS256, map[string][]byte{"k1": testHMACKey}, "k1"),
+		"jwt ed25519":    mustCodec(t, AccessTokenFormatJWT, SigningAlgorithmEd25519, map[string][]byte{"k1": testEd25519Key}, "k1"),
+		"paseto ed25519": mustCodec(t, AccessTokenFormatPASETO, SigningAlgorithmEd25519, map[string][]byte{"k1": testEd25519Key}, "k1"),
+	}
+}
+
+func mustCodec(t *testing.T, format string, algorithm string, keys map[string][]byte, active string) *AccessTokenCodec {
+	t.Helper()
+	c, err := NewAccessTokenCodec(format, algorithm, keys, active)
+	if err != nil {
+		t.Fatalf("NewAccessTokenCodec() error = %v", err)
+	}
+	return c
+}
+
+func TestNewAccessTokenCodec(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    string
+		algorithm string
+		keys      map[string][]byte
+		active    string
+		wantErr   bool
+	}{
+		{name: "jwt hs256", format: AccessTokenFormatJWT, algorithm: SigningAlgorithmHS256, keys: map[string][]byte{"k1": testHMACKey}, active: "k1"},
+		{name: "paseto ed25519", format: AccessTokenFormatPASETO, algorithm: SigningAlgorithmEd25519, keys: map[string][]byte{"k1": testEd25519Key}, active: "k1"},
+		{name: "paseto hs256", format: AccessTokenFormatPASETO, algorithm: SigningAlgorithmHS256, keys: map[string][]byte{"k1": testHMACKey}, active: "k1", wantErr: true},
+		{name: "unknown format", format: "saml", algorithm: SigningAlgorithmHS256, keys: map[string][]byte{"k1": testHMACKey}, active: "k1", wantErr: true},
+		{name: "unknown algorithm", format: AccessTokenFormatJWT, algorithm: "rs256", keys: map[string][]byte{"k1": testHMACKey}, active: "k1", wantErr: true},
+		{name: "short hmac key", format: AccessTokenFormatJWT, algorithm: SigningAlgorithmHS256, keys: map[string][]byte{"k1": testHMACKey[:31]}, active: "k1", wantErr: true},
+		{name: "wrong ed25519 seed size", format: AccessTokenFormatJWT, algorithm: SigningAlgorithmEd25519, keys: map[string][]byte{"k1": testEd25519Key[:16]}, active: "k1", wantErr: true},
+		{name: "active key missing", format: AccessTokenFormatJWT, algorithm: SigningAlgorithmHS256, keys: map[string][]byte{"k1": testHMACKey}, active: "k2", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAccessTokenCodec(tt.format, tt.algorithm, tt.keys, tt.active)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewAccessTokenCodec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAccessTokenRoundTrip(t *testing.T) {
+	now := time.Now()
+	want := testClaims(now)
+	for name, codec := range testCodecs(t) {
+		t.Run(name, func(t *testing.T) {
+			token, err := codec.Sign(want)
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+			got, err := codec.Verify(token, now)
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if got.ID != want.ID || got.UserID != want.UserID || got.Role != want.Role {
+				t.Errorf("Verify() = %+v, want %+v", got, want)
+			}
+			// Issue times keep millisecond precision, which revocation checks rely on.
+			if got.IssuedAt.Sub(want.IssuedAt).Abs() >= time.Millisecond {
+				t.Errorf("IssuedAt = %v, want %v", got.IssuedAt, want.IssuedAt)
+			}
+			if got.ExpiresAt.Unix() != want.ExpiresAt.Unix() {
+				t.Errorf("ExpiresAt = %v, want %v", got.ExpiresAt, want.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestAccessTokenExpiry(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	claims := testClaims(now)
+	for name, codec := range testCodecs(t) {
+		t.Run(name, func(t *testing.T) {
+			token, err := codec.Sign(claims)
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+
+			tests := []struct {
+				name    string
+				at      time.Time
+				wantErr error
+			}{
+				{name: "just before expiry", at: claims.ExpiresAt.Add(-time.Second)},
+				{name: "at expiry", at: claims.ExpiresAt, wantErr: ErrAccessTokenExpired},
+				{name: "after expiry", at: claims.ExpiresAt.Add(time.Hour), wantErr: ErrAccessTokenExpired},
+			}
+			for _, tt := range tests {
+				_, err := codec.Verify(token, tt.at)
+				if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+					t.Errorf("%s: Verify() error = %v, want %v", tt.name, err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestJWTRejectsTampering(t *testing.T) {
+	now := time.Now()
+	codec := mustCodec(t, AccessTokenFormatJWT, SigningAlgorithmHS256, map[string][]byte{"k1": testHMACKey}, "k1")
+	token, err := codec.Sign(testClaims(now))
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	parts := strings.Split(token, ".")
+	enc := base64.RawURLEncoding.EncodeToString
+
+	escalated := strings.Replace(decodeSegment(t, parts[1]), `"role":"admin"`, `"role":"owner"`, 1)
+	otherKey := mustCodec(t, AccessTokenFormatJWT, SigningAlgorithmHS256, map[string][]byte{"k1": bytes.Repeat([]byte{9}, 32)}, "k1")
+	forged, err := otherKey.Sign(testClaims(now))
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "modified claims", token: parts[0] + "." + enc([]byte(escalated)) + "." + parts[2]},
+		{name: "modified signature", token: parts[0] + "." + parts[1] + "." + enc([]byte("not the signature"))},
+		{name: "no signature", token: parts[0] + "." + parts[1] + "."},
+		{name: "alg none", token: enc([]byte(`{"alg":"none","typ":"JWT","kid":"k1"}`)) + "." + parts[1] + "."},
+		{name: "alg switched to EdDSA", token: enc([]byte(`{"alg":"EdDSA","typ":"JWT","kid":"k1"}`)) + "." + parts[1] + "." + parts[2]},
+		{name: "unknown key id", token: enc([]byte(`{"alg":"HS256","typ":"JWT","kid":"k9"}`)) + "." + parts[1] + "." + parts[2]},
+		{name: "signed with another key", token: forged},
+		{name: "too few segments", token: parts[0] + "." + parts[1]},
+		{name: "garbage", token: "not.a.token"},
+		{name: "paseto token", token: pasetoHeader + parts[1] + "." + parts[0]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := codec.Verify(tt.token, now)
+			if !errors.Is(err, ErrInvalidAccessToken) {
+				t.Errorf("Verify() error = %v, want ErrInvalidAccessToken", err)
+			}
+		})
+	}
+}
+
+func TestPASETORejectsTampering(t *testing.T) {
+	now := time.Now()
+	codec := mustCodec(t, AccessTokenFormatPASETO, SigningAlgorithmEd25519, map[string][]byte{"k1": testEd25519Key}, "k1")
+	token, err := codec.Sign(testClaims(now))
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	parts := strings.Split(strings.TrimPrefix(token, pasetoHeader), ".")
+	body, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	enc := base64.RawURLEncoding.EncodeToString
+
+	escalated := bytes.Replace(bytes.Clone(body), []byte(`"role":"admin"`), []byte(`"role":"owner"`), 1)
+	flippedSig := bytes.Clone(body)
+	flippedSig[len(flippedSig)-1] ^= 1
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "modified claims", token: pasetoHeader + enc(escalated) + "." + parts[1]},
+		{name: "modified signature", token: pasetoHeader + enc(flippedSig) + "." + parts[1]},
+		{name: "modified footer", token: pasetoHeader + parts[0] + "." + enc([]byte(`{"kid":"k1","x":1}`))},
+		{name: "unknown key id", token: pasetoHeader + parts[0] + "." + enc([]byte(`{"kid":"k9"}`))},
+		{name: "wrong version", token: "v2.public." + parts[0] + "." + parts[1]},
+		{name: "body shorter than signature", token: pasetoHeader + enc(body[:10]) + "." + parts[1]},
+		{name: "missing footer", token: pasetoHeader + parts[0]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := codec.Verify(tt.token, now)
+			if !errors.Is(err, ErrInvalidAccessToken) {
+				t.Errorf("Verify() error = %v, want ErrInvalidAccessToken", err)
+			}
+		})
+	}
+}
+
+func TestAccessTokenKeyRotation(t *testing.T) {
+	now := time.Now()
+	oldKey := bytes.Repeat([]byte{3}, 32)
+	newKey := bytes.Repeat([]byte{4}, 32)
+
+	before := mustCodec(t, AccessTokenFormatJWT, SigningAlgorithmHS256, map[string][]byte{"old": oldKey}, "old")
+	during := mustCodec(t, AccessTokenFormatJWT, SigningAlgorithmHS256, map[string][]byte{"old": oldKey, "new": newKey}, "new")
+	after := mustCodec(t, AccessTokenFormatJWT, SigningAlgorithmHS256, map[string][]byte{"new": newKey}, "new")
+
+	oldToken, err := before.Sign(testClaims(now))
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	newToken, err := during.Sign(testClaims(now))
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		codec   *AccessTokenCodec
+		token   string
+		wantErr bool
+	}{
+		{name: "old token while both keys are in the ring", codec: during, token: oldToken},
+		{name: "new token while both keys are in the ring", codec: during, token: newToken},
+		{name: "new token after the old key is retired", codec: after, token: newToken},
+		{name: "old token after the old key is retired", codec: after, token: oldToken, wantErr: true},
+		{name: "new token before the new key is added", codec: before, token: newToken, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.codec.Verify(tt.token, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPASETOPAE(t *testing.T) {
+	// Test vectors from the PASETO specification.
+	tests := []struct {
+		name   string
+		pieces [][]byte
+		want   string
+	}{
+		{name: "no pieces", pieces: nil, want: "\x00\x00\x00\x00\x00\x00\x00\x00"},
+		{name: "one empty piece", pieces: [][]byte{{}}, want: "\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"},
+		{name: "one piece", pieces: [][]byte{[]byte("test")}, want: "\x01\x00\x00\x00\x00\x00\x00\x00\x04\x00\x00\x00\x00\x00\x00\x00test"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pasetoPAE(tt.pieces...); string(got) != tt.want {
+				t.Errorf("pasetoPAE() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func decodeSegment(t *testing.T, s string) string {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("decode segment: %v", err)
+	}
+	return string(b)
+}
@@ -0,0 +1,157 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+
+	argon2SaltSize = 16
+	argon2KeySize  = 32
+)
+
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// Argon2Params are the tuning parameters for argon2id.
+type Argon2Params struct {
+	// MemoryKiB is the memory cost in kibibytes.
+	MemoryKiB   uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// PasswordHasher hashes and verifies passwords with a configured algorithm. Stored hashes are self describing, so
+// hashes produced under older settings, or another algorithm entirely, still verify:
+//
+//	bcrypt:   $2a$10$...
+//	argon2id: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+type PasswordHasher struct {
+	// Algorithm is the algorithm new hashes are produced with, either AlgorithmBcrypt or AlgorithmArgon2id.
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+// Validate checks that the hasher is usable.
+func (h PasswordHasher) Validate() error {
+	switch h.Algorithm {
+	case AlgorithmBcrypt:
+		if h.BcryptCost < bcrypt.MinCost || h.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case AlgorithmArgon2id:
+		if h.Argon2.MemoryKiB == 0 || h.Argon2.Iterations == 0 || h.Argon2.Parallelism == 0 {
+			return errors.New("argon2id memory, iterations and parallelism must all be set")
+		}
+	default:
+		return fmt.Errorf("unknown password hash algorithm %q", h.Algorithm)
+	}
+	return nil
+}
+
+// Hash derives a storable hash of password using the configured algorithm.
+func (h PasswordHasher) Hash(password string) (string, error) {
+	switch h.Algorithm {
+	case AlgorithmBcrypt:
+		b, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("bcrypt hash: %w", err)
+		}
+		return string(b), nil
+	case AlgorithmArgon2id:
+		salt := make([]byte, argon2SaltSize)
+		_, err := rand.Read(salt)
+		if err != nil {
+			return "", fmt.Errorf("generate salt: %w", err)
+		}
+		p := h.Argon2
+		key := argon2.IDKey([]byte(password), salt, p.Iterations, p.MemoryKiB, p.Parallelism, argon2KeySize)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.MemoryKiB, p.Iterations,
+			p.Parallelism, base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	default:
+		return "", fmt.Errorf("unknown password hash algorithm %q", h.Algorithm)
+	}
+}
+
+// Verify reports whether password matches the stored hash, whichever algorithm produced it.
+func (h PasswordHasher) Verify(hash string, password string) (bool, error) {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		p, salt, key, err := parseArgon2id(hash)
+		if err != nil {
+			return false, err
+		}
+		candidate := argon2.IDKey([]byte(password), salt, p.Iterations, p.MemoryKiB, p.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(candidate, key) == 1, nil
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrUnknownHashFormat, err)
+	}
+	return true, nil
+}
+
+// NeedsRehash reports whether a stored hash was produced by a different algorithm, or with weaker parameters, than the
+// hasher is configured with.
+func (h PasswordHasher) NeedsRehash(hash string) bool {
+	switch h.Algorithm {
+	case AlgorithmBcrypt:
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost < h.BcryptCost
+	case AlgorithmArgon2id:
+		if !strings.HasPrefix(hash, "$argon2id$") {
+			return true
+		}
+		p, _, _, err := parseArgon2id(hash)
+		if err != nil {
+			return true
+		}
+		return p.MemoryKiB < h.Argon2.MemoryKiB || p.Iterations < h.Argon2.Iterations || p.Parallelism < h.Argon2.Parallelism
+	default:
+		return false
+	}
+}
+
+// parseArgon2id splits a PHC formatted argon2id hash into its parameters, salt and key.
+func parseArgon2id(hash string) (Argon2Params, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	if len(parts) != 6 {
+		return Argon2Params{}, nil, nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: unsupported argon2 version", ErrUnknownHashFormat)
+	}
+
+	p := Argon2Params{}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.MemoryKiB, &p.Iterations, &p.Parallelism)
+	if err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: %w", ErrUnknownHashFormat, err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: %w", ErrUnknownHashFormat, err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: %w", ErrUnknownHashFormat, err)
+	}
+
+	return p, salt, key, nil
+}
@@ -0,0 +1,232 @@
+package security
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Cheap parameters keep the tests fast. The algorithms behave the same at any cost.
+var (
+	testBcrypt = PasswordHasher{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}
+	testArgon2 = PasswordHasher{Algorithm: AlgorithmArgon2id, Argon2: Argon2Params{MemoryKiB: 64, Iterations: 1, Parallelism: 1}}
+)
+
+func TestPasswordHasherValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		hasher  PasswordHasher
+		wantErr bool
+	}{
+		{name: "bcrypt", hasher: testBcrypt},
+		{name: "argon2id", hasher: testArgon2},
+		{name: "bcrypt cost too low", hasher: PasswordHasher{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost - 1}, wantErr: true},
+		{name: "bcrypt cost too high", hasher: PasswordHasher{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MaxCost + 1}, wantErr: true},
+		{name: "argon2id missing params", hasher: PasswordHasher{Algorithm: AlgorithmArgon2id, Argon2: Argon2Params{MemoryKiB: 64}}, wantErr: true},
+		{name: "unknown algorithm", hasher: PasswordHasher{Algorithm: "md5"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.hasher.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPasswordHasherRoundTrip(t *testing.T) {
+	for _, hasher := range []PasswordHasher{testBcrypt, testArgon2} {
+		t.Run(hasher.Algorithm, func(t *testing.T) {
+			hash, err := hasher.Hash("correct horse")
+			if err != nil {
+				t.Fatalf("Hash() error = %v", err)
+			}
+
+			tests := []struct {
+				password string
+				want     bool
+			}{
+				{password: "correct horse", want: true},
+				{password: "correct horse ", want: false},
+				{password: "Correct horse", want: false},
+				{password: "", want: false},
+			}
+			for _, tt := range tests {
+				got, err := hasher.Verify(hash, tt.password)
+				if err != nil {
+					t.Fatalf("Verify(%q) error = %v", tt.password, err)
+				}
+				if got != tt.want {
+					t.Errorf("Verify(%q) = %v, want %v", tt.password, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPasswordHasherHashIsSalted(t *testing.T) {
+	for _, hasher := range []PasswordHasher{testBcrypt, testArgon2} {
+		t.Run(hasher.Algorithm, func(t *testing.T) {
+			a, err := hasher.Hash("password")
+			if err != nil {
+				t.Fatalf("Hash() error = %v", err)
+			}
+			b, err := hasher.Hash("password")
+			if err != nil {
+				t.Fatalf("Hash() error = %v", err)
+			}
+			if a == b {
+				t.Errorf("two hashes of the same password are identical: %s", a)
+			}
+		})
+	}
+}
+
+func TestPasswordHasherVerifiesOtherAlgorithms(t *testing.T) {
+	bcryptHash, err := testBcrypt.Hash("password")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	argon2Hash, err := testArgon2.Hash("password")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	// A hasher configured for one algorithm still verifies hashes from the other, so switching isn't a lockout.
+	for _, tt := range []struct {
+		name   string
+		hasher PasswordHasher
+		hash   string
+	}{
+		{name: "bcrypt hasher, argon2id hash", hasher: testBcrypt, hash: argon2Hash},
+		{name: "argon2id hasher, bcrypt hash", hasher: testArgon2, hash: bcryptHash},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := tt.hasher.Verify(tt.hash, "password")
+			if err != nil || !ok {
+				t.Errorf("Verify() = %v, %v, want true, nil", ok, err)
+			}
+		})
+	}
+}
+
+func TestPasswordHasherVerifyMalformed(t *testing.T) {
+	valid, err := testArgon2.Hash("password")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	parts := strings.Split(valid, "$")
+
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{name: "empty", hash: ""},
+		{name: "plain text", hash: "password"},
+		{name: "truncated argon2id", hash: strings.Join(parts[:5], "$")},
+		{name: "argon2id wrong version", hash: strings.Replace(valid, "v=19", "v=16", 1)},
+		{name: "argon2id bad params", hash: strings.Replace(valid, parts[3], "m=x,t=1,p=1", 1)},
+		{name: "argon2id bad salt", hash: strings.Replace(valid, parts[4], "!!!", 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := testArgon2.Verify(tt.hash, "password")
+			if ok {
+				t.Errorf("Verify() = true for a malformed hash")
+			}
+			if !errors.Is(err, ErrUnknownHashFormat) {
+				t.Errorf("Verify() error = %v, want ErrUnknownHashFormat", err)
+			}
+		})
+	}
+}
+
+func TestPasswordHasherNeedsRehash(t *testing.T) {
+	weakBcrypt, err := testBcrypt.Hash("password")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	weakArgon2, err := testArgon2.Hash("password")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	strongerBcrypt := testBcrypt
+	strongerBcrypt.BcryptCost++
+	for _, tt := range []struct {
+		name   string
+		change func(p *Argon2Params)
+	}{
+		{name: "memory", change: func(p *Argon2Params) { p.MemoryKiB *= 2 }},
+		{name: "iterations", change: func(p *Argon2Params) { p.Iterations++ }},
+		{name: "parallelism", change: func(p *Argon2Params) { p.Parallelism++ }},
+	} {
+		stronger := testArgon2
+		tt.change(&stronger.Argon2)
+		if !stronger.NeedsRehash(weakArgon2) {
+			t.Errorf("NeedsRehash() = false after raising argon2id %s", tt.name)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		hasher PasswordHasher
+		hash   string
+		want   bool
+	}{
+		{name: "bcrypt same cost", hasher: testBcrypt, hash: weakBcrypt, want: false},
+		{name: "bcrypt higher cost", hasher: strongerBcrypt, hash: weakBcrypt, want: true},
+		{name: "bcrypt hasher, argon2id hash", hasher: testBcrypt, hash: weakArgon2, want: true},
+		{name: "argon2id same params", hasher: testArgon2, hash: weakArgon2, want: false},
+		{name: "argon2id hasher, bcrypt hash", hasher: testArgon2, hash: weakBcrypt, want: true},
+		{name: "argon2id malformed hash", hasher: testArgon2, hash: "$argon2id$v=19$", want: true},
+		{name: "bcrypt lower cost", hasher: testBcrypt, hash: mustHash(t, strongerBcrypt), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hasher.NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func mustHash(t *testing.T, h PasswordHasher) string {
+	t.Helper()
+	hash, err := h.Hash("password")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	return hash
+}
+
+func TestHashToken(t *testing.T) {
+	// SHA-256 of "abc".
+	const want = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := HashToken("abc"); got != want {
+		t.Errorf("HashToken() = %s, want %s", got, want)
+	}
+	if HashToken("abc") == HashToken("abd") {
+		t.Error("HashToken() collides for different tokens")
+	}
+}
+
+func TestCreateSecureSessionToken(t *testing.T) {
+	a, err := CreateSecureSessionToken(32)
+	if err != nil {
+		t.Fatalf("CreateSecureSessionToken() error = %v", err)
+	}
+	if len(a) != 64 {
+		t.Errorf("len = %d, want 64 hex characters for 32 bytes", len(a))
+	}
+	b, err := CreateSecureSessionToken(32)
+	if err != nil {
+		t.Fatalf("CreateSecureSessionToken() error = %v", err)
+	}
+	if a == b {
+		t.Error("two tokens are identical")
+	}
+}
@@ -0,0 +1,95 @@
+package security
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+func TestNewSecretBoxKeySize(t *testing.T) {
+	for _, size := range []int{0, 16, 24, 31, 33} {
+		_, err := NewSecretBox(make([]byte, size))
+		if err == nil {
+			t.Errorf("NewSecretBox() accepted a %d byte key", size)
+		}
+	}
+	_, err := NewSecretBox(make([]byte, 32))
+	if err != nil {
+		t.Errorf("NewSecretBox() error = %v", err)
+	}
+}
+
+func TestSecretBoxRoundTrip(t *testing.T) {
+	box := newTestSecretBox(t, 1)
+	for _, plaintext := range []string{"", "JBSWY3DPEHPK3PXP", "unicode ✓"} {
+		sealed, err := box.Seal(plaintext)
+		if err != nil {
+			t.Fatalf("Seal() error = %v", err)
+		}
+		got, err := box.Open(sealed)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		if got != plaintext {
+			t.Errorf("Open() = %q, want %q", got, plaintext)
+		}
+	}
+}
+
+func TestSecretBoxSealIsRandomised(t *testing.T) {
+	box := newTestSecretBox(t, 1)
+	a, err := box.Seal("secret")
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	b, err := box.Seal("secret")
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if a == b {
+		t.Error("sealing the same value twice gave the same output")
+	}
+}
+
+func TestSecretBoxOpenRejectsTampering(t *testing.T) {
+	box := newTestSecretBox(t, 1)
+	sealed, err := box.Seal("secret")
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	flipped := bytes.Clone(raw)
+	flipped[len(flipped)-1] ^= 1
+
+	tests := []struct {
+		name   string
+		box    *SecretBox
+		sealed string
+	}{
+		{name: "wrong key", box: newTestSecretBox(t, 2), sealed: sealed},
+		{name: "flipped bit", box: box, sealed: base64.StdEncoding.EncodeToString(flipped)},
+		{name: "truncated", box: box, sealed: base64.StdEncoding.EncodeToString(raw[:len(raw)-1])},
+		{name: "shorter than nonce", box: box, sealed: base64.StdEncoding.EncodeToString(raw[:4])},
+		{name: "not base64", box: box, sealed: "not base64!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.box.Open(tt.sealed)
+			if err == nil {
+				t.Error("Open() accepted a tampered value")
+			}
+		})
+	}
+}
+
+func newTestSecretBox(t *testing.T, fill byte) *SecretBox {
+	t.Helper()
+	box, err := NewSecretBox(bytes.Repeat([]byte{fill}, 32))
+	if err != nil {
+		t.Fatalf("NewSecretBox() error = %v", err)
+	}
+	return box
+}
@@ -0,0 +1,158 @@
+package security
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the RFC 4226 and RFC 6238 SHA-1 test secret, "12345678901234567890", base32 encoded.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestHOTPVectors(t *testing.T) {
+	// RFC 4226 appendix D.
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, code := range want {
+		if got := hotp([]byte("12345678901234567890"), int64(counter)); got != code {
+			t.Errorf("hotp(%d) = %s, want %s", counter, got, code)
+		}
+	}
+}
+
+func TestValidateTOTPVectors(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1, truncated to six digits.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1111111111, code: "050471"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 2000000000, code: "279037"},
+		{unix: 20000000000, code: "353130"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			step, ok := ValidateTOTP(rfcSecret, tt.code, time.Unix(tt.unix, 0))
+			if !ok {
+				t.Fatalf("ValidateTOTP() rejected the RFC code")
+			}
+			if want := tt.unix / totpPeriod; step != want {
+				t.Errorf("step = %d, want %d", step, want)
+			}
+		})
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	// At t=59 the current step is 1. Codes for steps 0-2 are within the allowed skew.
+	at := time.Unix(59, 0)
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current step", secret: rfcSecret, code: "287082", wantStep: 1, wantOK: true},
+		{name: "previous step", secret: rfcSecret, code: "755224", wantStep: 0, wantOK: true},
+		{name: "next step", secret: rfcSecret, code: "359152", wantStep: 2, wantOK: true},
+		{name: "beyond skew", secret: rfcSecret, code: "969429"},
+		{name: "lower case secret", secret: strings.ToLower(rfcSecret), code: "287082", wantStep: 1, wantOK: true},
+		{name: "wrong code", secret: rfcSecret, code: "000000"},
+		{name: "short code", secret: rfcSecret, code: "28708"},
+		{name: "long code", secret: rfcSecret, code: "2870820"},
+		{name: "empty code", secret: rfcSecret, code: ""},
+		{name: "invalid secret", secret: "not base32!", code: "287082"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(tt.secret, tt.code, at)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("ValidateTOTP() = %d, %v, want %d, %v", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret() error = %v", err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret isn't base32: %v", err)
+	}
+	if len(key) != totpSecretSize {
+		t.Errorf("key is %d bytes, want %d", len(key), totpSecretSize)
+	}
+
+	// A code made from the secret validates.
+	now := time.Now()
+	code := hotp(key, now.Unix()/totpPeriod)
+	if _, ok := ValidateTOTP(secret, code, now); !ok {
+		t.Error("ValidateTOTP() rejected a code from a generated secret")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("Dating Service", "alice@example.com", rfcSecret)
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("parse uri: %v", err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" {
+		t.Errorf("uri = %s, want otpauth://totp/...", uri)
+	}
+	if u.Path != "/Dating Service:alice@example.com" {
+		t.Errorf("label = %q", u.Path)
+	}
+	if strings.Contains(uri, "+") {
+		t.Errorf("uri %s encodes spaces as +", uri)
+	}
+
+	q := u.Query()
+	for key, want := range map[string]string{
+		"secret":    rfcSecret,
+		"issuer":    "Dating Service",
+		"algorithm": "SHA1",
+		"digits":    "6",
+		"period":    "30",
+	} {
+		if got := q.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	code, err := GenerateRecoveryCode()
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCode() error = %v", err)
+	}
+	if len(code) != 11 || code[5] != '-' || code != strings.ToLower(code) {
+		t.Errorf("code = %q, want xxxxx-xxxxx in lower case", code)
+	}
+	if NormaliseRecoveryCode(code) != code {
+		t.Errorf("NormaliseRecoveryCode() changed a canonical code")
+	}
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "abcde-fghij", want: "abcde-fghij"},
+		{in: "ABCDE-FGHIJ", want: "abcde-fghij"},
+		{in: "abcdefghij", want: "abcde-fghij"},
+		{in: " abcde fghij ", want: "abcde-fghij"},
+		{in: "abc-de", want: "abcde"},
+	}
+	for _, tt := range tests {
+		if got := NormaliseRecoveryCode(tt.in); got != tt.want {
+			t.Errorf("NormaliseRecoveryCode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
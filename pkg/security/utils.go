@@ -2,6 +2,7 @@ package security
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 )
@@ -17,3 +18,10 @@ func CreateSecureSessionToken(n int) (string, error) {
 
 	return hex.EncodeToString(token), nil
 }
+
+// HashToken returns the hex encoded SHA-256 of a token, for storing tokens that are sent to users so they can be
+// looked up without being kept in the clear.
+func HashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
@@ -13,6 +13,12 @@ type RankedMatch struct {
 	Ranking int `json:"ranking"`
 	// DistanceFromMe specifies distance in KM from the user
 	DistanceFromMe int `json:"distanceFromMe"`
+	// SharedInterests is the number of interests the profile has in common with the user.
+	SharedInterests int `json:"sharedInterests"`
+	// SuperLikedMe is set when the profile has super liked the user.
+	SuperLikedMe bool `json:"superLikedMe,omitempty"`
+	// City is the nearest known city to the profile, left out if none is close enough to describe where they are.
+	City string `json:"city,omitempty"`
 }
 
 // RankedResultSet set of results to be returned to user
@@ -38,3 +44,16 @@ func (r *RankedResultSet) AddMatch(input RankedMatch) {
 	copy(r.Matches[index+1:], r.Matches[index:])
 	r.Matches[index] = input
 }
+
+// boostRanking is added to the ranking of a candidate with an active boost. It is less than a super like adds, so a
+// profile that super liked the user still comes before one that is merely boosted.
+const boostRanking = 5
+
+// ApplyBoost returns a candidate's ranking raised for an active boost. A ranking of -1 marks a mismatch, which a boost
+// never overrides.
+func ApplyBoost(ranking int, boosted bool) int {
+	if !boosted || ranking == -1 {
+		return ranking
+	}
+	return ranking + boostRanking
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// AuditEntry records a privileged action taken against an account, such as a moderator suspending a user.
+type AuditEntry struct {
+	ID int `json:"id"`
+	// ActorID is nil when the action was performed outside the API, i.e. from the CLI.
+	ActorID      *int      `json:"actorId,omitempty"`
+	Action       string    `json:"action"`
+	TargetUserID *int      `json:"targetUserId,omitempty"`
+	ReportID     *int      `json:"reportId,omitempty"`
+	Detail       string    `json:"detail,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// TableName overrides the GORM default, as "audit_entries" reads poorly against the rest of the schema.
+func (AuditEntry) TableName() string {
+	return "audit_log"
+}
+
+func (r *Repository) CreateAuditEntry(ctx context.Context, entry *AuditEntry) error {
+	res := r.db.WithContext(ctx).Create(entry)
+	if res.Error != nil {
+		return fmt.Errorf("create audit entry: %w", res.Error)
+	}
+	return nil
+}
+
+func (r *Repository) GetAuditEntriesForUser(ctx context.Context, userID int) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	res := r.db.WithContext(ctx).Where("target_user_id = ?", userID).Order("created_at DESC").Find(&entries)
+	if res.Error != nil {
+		return nil, fmt.Errorf("retrieve audit entries for user (%d): %w", userID, res.Error)
+	}
+	return entries, nil
+}
@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+const (
+	// BoostSourceCredit boosts spend one of the user's boost credits.
+	BoostSourceCredit = "credit"
+	// BoostSourcePremium boosts come from the weekly allowance included in premium.
+	BoostSourcePremium = "premium"
+)
+
+// boostBaselineDays is how many whole days before a boost its baseline is taken from.
+const boostBaselineDays = 7
+
+var (
+	ErrBoostActive    = errors.New("a boost is already active")
+	ErrNoBoostCredits = errors.New("no boosts available")
+)
+
+// Boost is a window in which a user is ranked higher in other users' discover results. The baselines are the
+// impressions and likes the user would have expected over a window of the same length, judged from the week before it
+// started.
+type Boost struct {
+	ID                  int       `json:"id"`
+	UserID              int       `json:"-"`
+	Source              string    `json:"source"`
+	StartsAt            time.Time `json:"startsAt"`
+	EndsAt              time.Time `json:"endsAt"`
+	Impressions         int       `json:"impressions"`
+	BaselineImpressions float64   `json:"baselineImpressions"`
+	BaselineLikes       float64   `json:"baselineLikes"`
+}
+
+// profileImpression counts how many times a user was shown in others' discover results on a day.
+type profileImpression struct {
+	UserID      int       `gorm:"primaryKey"`
+	Day         time.Time `gorm:"primaryKey;type:date"`
+	Impressions int
+}
+
+// StartBoost starts a boost for a user lasting duration from now. It uses the premium allowance if premiumWeekly is
+// above zero and fewer premium boosts than that were started in the past week, and otherwise spends a boost credit. The
+// user's row is locked throughout, so concurrent calls can't both spend the last credit or start overlapping boosts.
+func (r *Repository) StartBoost(ctx context.Context, userID int, duration time.Duration, premiumWeekly int) (Boost, error) {
+	boost := Boost{}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		user := User{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "boost_credits").Where("id = ?", userID).
+			First(&user).Error
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var active int64
+		err = tx.Model(&Boost{}).Where("user_id = ? AND ends_at > ?", userID, now).Count(&active).Error
+		if err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrBoostActive
+		}
+
+		source := BoostSourceCredit
+		if premiumWeekly > 0 {
+			var used int64
+			err = tx.Model(&Boost{}).Where("user_id = ? AND source = ? AND starts_at > ?", userID, BoostSourcePremium,
+				now.Add(-7*24*time.Hour)).Count(&used).Error
+			if err != nil {
+				return err
+			}
+			if int(used) < premiumWeekly {
+				source = BoostSourcePremium
+			}
+		}
+		if source == BoostSourceCredit {
+			if user.BoostCredits < 1 {
+				return ErrNoBoostCredits
+			}
+			err = tx.Model(&User{}).Where("id = ?", userID).
+				Update("boost_credits", gorm.Expr("boost_credits - 1")).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		// The baseline is taken from the whole UTC days before today, matching how impressions are bucketed.
+		today := impressionDay(now)
+		baselineStart := today.AddDate(0, 0, -boostBaselineDays)
+		var pastImpressions, pastLikes int64
+		err = tx.Model(&profileImpression{}).Select("COALESCE(SUM(impressions), 0)").
+			Where("user_id = ? AND day >= ? AND day < ?", userID, baselineStart, today).
+			Scan(&pastImpressions).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&Swipe{}).Where("candidate_id = ? AND likes = ? AND created_at >= ? AND created_at < ?",
+			userID, true, baselineStart, today).Count(&pastLikes).Error
+		if err != nil {
+			return err
+		}
+		share := float64(duration) / float64(today.Sub(baselineStart))
+
+		boost = Boost{
+			UserID:              userID,
+			Source:              source,
+			StartsAt:            now,
+			EndsAt:              now.Add(duration),
+			BaselineImpressions: float64(pastImpressions) * share,
+			BaselineLikes:       float64(pastLikes) * share,
+		}
+		return tx.Create(&boost).Error
+	})
+	if err != nil {
+		return Boost{}, fmt.Errorf("start boost: %w", err)
+	}
+	return boost, nil
+}
+
+// GetBoostedUsers returns which of the given users have a boost active at now.
+func (r *Repository) GetBoostedUsers(ctx context.Context, userIDs []int, now time.Time) (map[int]bool, error) {
+	result := map[int]bool{}
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	var boosted []int
+	err := r.db.WithContext(ctx).Model(&Boost{}).
+		Where("user_id IN ? AND starts_at <= ? AND ends_at > ?", userIDs, now, now).
+		Pluck("user_id", &boosted).Error
+	if err != nil {
+		return nil, fmt.Errorf("retrieve boosted users: %w", err)
+	}
+	for _, id := range boosted {
+		result[id] = true
+	}
+	return result, nil
+}
+
+// RecordImpressions counts one impression for each of the given users, both in their daily totals and against any
+// boost active at now.
+func (r *Repository) RecordImpressions(ctx context.Context, userIDs []int, now time.Time) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	day := impressionDay(now)
+	rows := make([]profileImpression, 0, len(userIDs))
+	for _, id := range userIDs {
+		rows = append(rows, profileImpression{UserID: id, Day: day, Impressions: 1})
+	}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			DoUpdates: clause.Assignments(map[string]interface{}{"impressions": gorm.Expr("impressions + 1")}),
+		}).Create(&rows).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&Boost{}).Where("user_id IN ? AND starts_at <= ? AND ends_at > ?", userIDs, now, now).
+			Update("impressions", gorm.Expr("impressions + 1")).Error
+	})
+	if err != nil {
+		return fmt.Errorf("record impressions: %w", err)
+	}
+	return nil
+}
+
+// GetBoosts returns a user's most recent boosts, newest first.
+func (r *Repository) GetBoosts(ctx context.Context, userID int, limit int) ([]Boost, error) {
+	var boosts []Boost
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("starts_at DESC").Limit(limit).Find(&boosts).Error
+	if err != nil {
+		return nil, fmt.Errorf("retrieve boosts: %w", err)
+	}
+	return boosts, nil
+}
+
+// CountLikesReceivedBetween returns how many likes a user received in [from, to).
+func (r *Repository) CountLikesReceivedBetween(ctx context.Context, userID int, from time.Time, to time.Time) (int, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Swipe{}).
+		Where("candidate_id = ? AND likes = ? AND created_at >= ? AND created_at < ?", userID, true, from, to).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("count likes received: %w", err)
+	}
+	return int(count), nil
+}
+
+// AddBoostCredits adds credits to a user's boost balance.
+func (r *Repository) AddBoostCredits(ctx context.Context, userID int, credits int) error {
+	res := r.db.WithContext(ctx).Model(&User{}).Where("id = ?", userID).
+		Update("boost_credits", gorm.Expr("boost_credits + ?", credits))
+	if res.Error != nil {
+		return fmt.Errorf("add boost credits: %w", res.Error)
+	}
+	return nil
+}
+
+// impressionDay returns the UTC day impressions made at t are counted under.
+func impressionDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+)
+
+// GetStoredGenders returns every distinct gender value stored, on users and in preferences. Values differing only in
+// case are returned separately, as the column collation would otherwise merge them.
+func (r *Repository) GetStoredGenders(ctx context.Context) ([]string, error) {
+	var genders []string
+	res := r.db.WithContext(ctx).Raw("SELECT CAST(gender AS BINARY) FROM users WHERE gender IS NOT NULL AND gender != '' " +
+		"UNION SELECT CAST(gender AS BINARY) FROM user_preference_genders " +
+		"UNION SELECT CAST(gender AS BINARY) FROM user_preference_show_me_to").Scan(&genders)
+	if res.Error != nil {
+		return nil, fmt.Errorf("retrieve stored genders: %w", res.Error)
+	}
+	return genders, nil
+}
+
+// RenameGender replaces a stored gender value, matched exactly, with another on users and in preferences. Where a
+// preference already holds the new value, the old one is dropped rather than duplicated. It returns how many rows were
+// changed.
+func (r *Repository) RenameGender(ctx context.Context, from string, to string) (int, error) {
+	var changed int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Exec("UPDATE users SET gender = ? WHERE BINARY gender = ?", to, from)
+		if res.Error != nil {
+			return res.Error
+		}
+		changed += res.RowsAffected
+
+		for _, table := range []string{"user_preference_genders", "user_preference_show_me_to"} {
+			res = tx.Exec("UPDATE IGNORE "+table+" SET gender = ? WHERE BINARY gender = ?", to, from)
+			if res.Error != nil {
+				return res.Error
+			}
+			changed += res.RowsAffected
+			// Rows left behind were ignored as duplicates of the new value.
+			res = tx.Exec("DELETE FROM "+table+" WHERE BINARY gender = ?", from)
+			if res.Error != nil {
+				return res.Error
+			}
+			changed += res.RowsAffected
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("rename gender: %w", err)
+	}
+	return int(changed), nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+// UserIdentity links a user to an account at an external identity provider.
+type UserIdentity struct {
+	ID       int    `json:"-"`
+	UserID   int    `json:"-"`
+	Provider string `json:"provider"`
+	// Subject is the provider's stable identifier for the account. Emails can change, so aren't used for linking.
+	Subject string `json:"-"`
+	// Email is the address the provider gave when the identity was linked, for display only.
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// OIDCAuthRequest holds the secrets for an OIDC login in progress, between sending the user to the provider and them
+// returning. It is looked up by a hash of the state parameter.
+type OIDCAuthRequest struct {
+	StateHash    string `gorm:"primaryKey"`
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	// LinkUserID is the logged-in user who started the request to link the provider account, or nil for a login.
+	LinkUserID *int
+	ExpiresAt  time.Time
+}
+
+// GetUserIdentity returns the identity for a provider account, or nil if it isn't linked to anyone.
+func (r *Repository) GetUserIdentity(ctx context.Context, provider string, subject string) (*UserIdentity, error) {
+	identity := &UserIdentity{}
+	res := r.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(identity)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if res.Error != nil {
+		return nil, fmt.Errorf("retrieve user identity: %w", res.Error)
+	}
+	return identity, nil
+}
+
+func (r *Repository) GetUserIdentities(ctx context.Context, userID int) ([]UserIdentity, error) {
+	var identities []UserIdentity
+	res := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&identities)
+	if res.Error != nil {
+		return nil, fmt.Errorf("retrieve user identities: %w", res.Error)
+	}
+	return identities, nil
+}
+
+func (r *Repository) CreateUserIdentity(ctx context.Context, identity *UserIdentity) error {
+	res := r.db.WithContext(ctx).Create(identity)
+	if res.Error != nil {
+		return fmt.Errorf("create user identity: %w", res.Error)
+	}
+	return nil
+}
+
+// CreateUserWithIdentity creates a user signing up through a provider, along with the identity linking them.
+func (r *Repository) CreateUserWithIdentity(ctx context.Context, user *User, identity *UserIdentity) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(user).Error
+		if err != nil {
+			return fmt.Errorf("create user: %w", err)
+		}
+		identity.UserID = user.ID
+		err = tx.Create(identity).Error
+		if err != nil {
+			return fmt.Errorf("create user identity: %w", err)
+		}
+		return nil
+	})
+}
+
+// CreateOIDCAuthRequest stores a new auth request, clearing out any abandoned ones while it's at it.
+func (r *Repository) CreateOIDCAuthRequest(ctx context.Context, req *OIDCAuthRequest) error {
+	res := r.db.WithContext(ctx).Where("expires_at <= ?", time.Now()).Delete(&OIDCAuthRequest{})
+	if res.Error != nil {
+		return fmt.Errorf("delete expired oidc auth requests: %w", res.Error)
+	}
+	res = r.db.WithContext(ctx).Create(req)
+	if res.Error != nil {
+		return fmt.Errorf("create oidc auth request: %w", res.Error)
+	}
+	return nil
+}
+
+// ConsumeOIDCAuthRequest returns and deletes an unexpired auth request, so each can only be completed once.
+func (r *Repository) ConsumeOIDCAuthRequest(ctx context.Context, stateHash string) (OIDCAuthRequest, error) {
+	req := OIDCAuthRequest{}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("state_hash = ? AND expires_at > ?", stateHash, time.Now()).First(&req).Error
+		if err != nil {
+			return err
+		}
+		res := tx.Where("state_hash = ?", stateHash).Delete(&OIDCAuthRequest{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return OIDCAuthRequest{}, fmt.Errorf("consume oidc auth request: %w", err)
+	}
+	return req, nil
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+)
+
+// Interest is an entry in the managed catalogue of interests a user can tag their profile with.
+type Interest struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Category string `json:"category,omitempty"`
+}
+
+// Prompt is a question from the managed catalogue that users can answer on their profile, i.e. "My ideal Sunday is..."
+type Prompt struct {
+	ID     int    `json:"id"`
+	Text   string `json:"text"`
+	Active bool   `json:"-"`
+}
+
+// PromptAnswer is a user's answer to a Prompt.
+type PromptAnswer struct {
+	UserID   int    `json:"-"`
+	PromptID int    `json:"promptId"`
+	Prompt   string `json:"prompt,omitempty" gorm:"-"`
+	Answer   string `json:"answer"`
+}
+
+// userInterest is the join row between users and interests.
+type userInterest struct {
+	UserID     int
+	InterestID int
+}
+
+func (userInterest) TableName() string {
+	return "user_interests"
+}
+
+func (PromptAnswer) TableName() string {
+	return "user_prompts"
+}
+
+func (r *Repository) GetInterestCatalogue(ctx context.Context) ([]Interest, error) {
+	var interests []Interest
+	res := r.db.WithContext(ctx).Order("category, name").Find(&interests)
+	if res.Error != nil {
+		return nil, fmt.Errorf("retrieve interest catalogue: %w", res.Error)
+	}
+	return interests, nil
+}
+
+func (r *Repository) GetActivePrompts(ctx context.Context) ([]Prompt, error) {
+	var prompts []Prompt
+	res := r.db.WithContext(ctx).Where("active = ?", true).Order("id").Find(&prompts)
+	if res.Error != nil {
+		return nil, fmt.Errorf("retrieve prompts: %w", res.Error)
+	}
+	return prompts, nil
+}
+
+// CountInterests returns how many of the given ids exist in the catalogue.
+func (r *Repository) CountInterests(ctx context.Context, ids []int) (int, error) {
+	var count int64
+	res := r.db.WithContext(ctx).Model(&Interest{}).Where("id IN ?", ids).Count(&count)
+	if res.Error != nil {
+		return 0, fmt.Errorf("count interests: %w", res.Error)
+	}
+	return int(count), nil
+}
+
+// CountActivePrompts returns how many of the given ids are active prompts.
+func (r *Repository) CountActivePrompts(ctx context.Context, ids []int) (int, error) {
+	var count int64
+	res := r.db.WithContext(ctx).Model(&Prompt{}).Where("id IN ? AND active = ?", ids, true).Count(&count)
+	if res.Error != nil {
+		return 0, fmt.Errorf("count prompts: %w", res.Error)
+	}
+	return int(count), nil
+}
+
+// GetInterestsForUsers returns the interests of each of the given users, keyed by user ID.
+func (r *Repository) GetInterestsForUsers(ctx context.Context, userIDs []int) (map[int][]Interest, error) {
+	rows := []struct {
+		UserID int
+		Interest
+	}{}
+	res := r.db.WithContext(ctx).Table("user_interests").
+		Select("user_interests.user_id, interests.id, interests.name, interests.category").
+		Joins("JOIN interests ON interests.id = user_interests.interest_id").
+		Where("user_interests.user_id IN ?", userIDs).
+		Order("interests.name").
+		Scan(&rows)
+	if res.Error != nil {
+		return nil, fmt.Errorf("retrieve interests for users: %w", res.Error)
+	}
+
+	result := make(map[int][]Interest, len(userIDs))
+	for _, row := range rows {
+		result[row.UserID] = append(result[row.UserID], row.Interest)
+	}
+	return result, nil
+}
+
+// SetUserInterests replaces a user's interests with the given set, marking their profile as changed.
+func (r *Repository) SetUserInterests(ctx context.Context, userID int, interestIDs []int) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("user_id = ?", userID).Delete(&userInterest{})
+		if res.Error != nil {
+			return res.Error
+		}
+		err := touchProfile(tx, userID)
+		if err != nil {
+			return err
+		}
+		if len(interestIDs) == 0 {
+			return nil
+		}
+
+		rows := make([]userInterest, 0, len(interestIDs))
+		for _, id := range interestIDs {
+			rows = append(rows, userInterest{UserID: userID, InterestID: id})
+		}
+		return tx.Create(&rows).Error
+	})
+	if err != nil {
+		return fmt.Errorf("set user interests: %w", err)
+	}
+	return nil
+}
+
+// GetPromptAnswersForUsers returns the prompt answers of each of the given users, keyed by user ID.
+func (r *Repository) GetPromptAnswersForUsers(ctx context.Context, userIDs []int) (map[int][]PromptAnswer, error) {
+	rows := []struct {
+		UserID   int
+		PromptID int
+		Text     string
+		Answer   string
+	}{}
+	res := r.db.WithContext(ctx).Table("user_prompts").
+		Select("user_prompts.user_id, user_prompts.prompt_id, prompts.text, user_prompts.answer").
+		Joins("JOIN prompts ON prompts.id = user_prompts.prompt_id").
+		Where("user_prompts.user_id IN ?", userIDs).
+		Order("user_prompts.position").
+		Scan(&rows)
+	if res.Error != nil {
+		return nil, fmt.Errorf("retrieve prompt answers for users: %w", res.Error)
+	}
+
+	result := make(map[int][]PromptAnswer, len(userIDs))
+	for _, row := range rows {
+		result[row.UserID] = append(result[row.UserID], PromptAnswer{
+			UserID:   row.UserID,
+			PromptID: row.PromptID,
+			Prompt:   row.Text,
+			Answer:   row.Answer,
+		})
+	}
+	return result, nil
+}
+
+// SetUserPromptAnswers replaces a user's prompt answers with the given set, preserving their order, and marks their
+// profile as changed.
+func (r *Repository) SetUserPromptAnswers(ctx context.Context, userID int, answers []PromptAnswer) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("user_id = ?", userID).Delete(&PromptAnswer{})
+		if res.Error != nil {
+			return res.Error
+		}
+		err := touchProfile(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		for i, a := range answers {
+			res = tx.Exec("INSERT INTO user_prompts (user_id, prompt_id, answer, position) VALUES (?, ?, ?, ?)",
+				userID, a.PromptID, a.Answer, i)
+			if res.Error != nil {
+				return res.Error
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("set user prompt answers: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+// receivedLikes selects the likes userID has received from users they haven't yet swiped on, or whose pass has expired
+// (see expiredPass). Likes from unverified, banned or suspended users are left out, as discover hides them, as are
+// those from users the two sides' gender settings wouldn't pair, as in User.GendersSuit. The lookup by candidate is
+// served by idx_swipes_candidate_likes.
+func (r *Repository) receivedLikes(ctx context.Context, userID int, passesExpireBefore time.Time) *gorm.DB {
+	swiped := r.db.WithContext(ctx).Table("swipes").Select("swipes.candidate_id").
+		Where("swipes.user_id = ? AND NOT (?)", userID, expiredPass(passesExpireBefore))
+	userGender := r.db.WithContext(ctx).Table("users").Select("gender").Where("id = ?", userID)
+	return r.db.WithContext(ctx).Model(&Swipe{}).
+		Joins("JOIN users ON users.id = swipes.user_id").
+		Where("swipes.candidate_id = ? AND swipes.likes = ? AND users.banned = ? AND users.email_verified = ?",
+			userID, true, false, true).
+		Where("users.suspended_until IS NULL OR users.suspended_until <= ?", time.Now()).
+		Where("swipes.user_id NOT IN (?)", swiped).
+		Where("EXISTS (SELECT 1 FROM user_preference_genders AS g WHERE g.user_id = ? AND g.gender = users.gender)", userID).
+		Where("EXISTS (SELECT 1 FROM user_preference_genders AS g WHERE g.user_id = users.id AND g.gender = (?))", userGender).
+		Where("(NOT EXISTS (SELECT 1 FROM user_preference_show_me_to AS m WHERE m.user_id = ?) OR "+
+			"EXISTS (SELECT 1 FROM user_preference_show_me_to AS m WHERE m.user_id = ? AND m.gender = users.gender))",
+			userID, userID).
+		Where("(NOT EXISTS (SELECT 1 FROM user_preference_show_me_to AS m WHERE m.user_id = users.id) OR "+
+			"EXISTS (SELECT 1 FROM user_preference_show_me_to AS m WHERE m.user_id = users.id AND m.gender = (?)))",
+			userGender)
+}
+
+// GetReceivedLikes returns up to limit likes received by userID from users they haven't swiped on, newest first.
+// Passing the ID of the last like from the previous page as beforeID returns the next page. A beforeID of 0 starts from
+// the newest.
+func (r *Repository) GetReceivedLikes(ctx context.Context, userID int, passesExpireBefore time.Time, beforeID int, limit int) ([]Swipe, error) {
+	var likes []Swipe
+	q := r.receivedLikes(ctx, userID, passesExpireBefore)
+	if beforeID > 0 {
+		q = q.Where("swipes.id < ?", beforeID)
+	}
+	err := q.Select("swipes.*").Order("swipes.id DESC").Limit(limit).Find(&likes).Error
+	if err != nil {
+		return nil, fmt.Errorf("retrieve received likes: %w", err)
+	}
+	return likes, nil
+}
+
+// CountReceivedLikes returns how many likes GetReceivedLikes would return across all pages.
+func (r *Repository) CountReceivedLikes(ctx context.Context, userID int, passesExpireBefore time.Time) (int, error) {
+	var count int64
+	err := r.receivedLikes(ctx, userID, passesExpireBefore).Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("count received likes: %w", err)
+	}
+	return int(count), nil
+}
+
+// GetUsersByID returns the users with the given IDs, in no particular order.
+func (r *Repository) GetUsersByID(ctx context.Context, ids []int) ([]User, error) {
+	var users []User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&users).Error
+	if err != nil {
+		return nil, fmt.Errorf("retrieve users: %w", err)
+	}
+	return users, nil
+}
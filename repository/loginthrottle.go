@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	ThrottleScopeAccount = "account"
+	ThrottleScopeIP      = "ip"
+)
+
+// LoginFailure counts consecutive failed logins for a subject, which is either an email address or a client IP.
+type LoginFailure struct {
+	Scope         string
+	Subject       string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+// Lockout records a subject being locked out after too many failed logins, so that admins can review and clear it.
+type Lockout struct {
+	ID          int        `json:"id"`
+	Scope       string     `json:"scope"`
+	Subject     string     `json:"subject"`
+	LockedAt    time.Time  `json:"lockedAt"`
+	LockedUntil time.Time  `json:"lockedUntil"`
+	ClearedAt   *time.Time `json:"clearedAt,omitempty"`
+	ClearedBy   *int       `json:"clearedBy,omitempty"`
+}
+
+// GetLoginFailures returns the failure count for a subject. A subject with no failures is returned with a zero count.
+func (r *Repository) GetLoginFailures(ctx context.Context, scope string, subject string) (LoginFailure, error) {
+	lf := LoginFailure{}
+	res := r.db.WithContext(ctx).Where("scope = ? AND subject = ?", scope, subject).First(&lf)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return LoginFailure{Scope: scope, Subject: subject}, nil
+	}
+	if res.Error != nil {
+		return LoginFailure{}, fmt.Errorf("retrieve login failures: %w", res.Error)
+	}
+	return lf, nil
+}
+
+// IncrementLoginFailures atomically adds a failure for the subject and returns the new state. Failures older than
+// decayAfter are forgotten, so the count restarts from one.
+func (r *Repository) IncrementLoginFailures(ctx context.Context, scope string, subject string, decayAfter time.Duration) (LoginFailure, error) {
+	now := time.Now()
+	res := r.db.WithContext(ctx).Exec(
+		`INSERT INTO login_failures (scope, subject, failures, last_failure_at) VALUES (?, ?, 1, ?)
+		ON DUPLICATE KEY UPDATE failures = IF(last_failure_at < ?, 1, failures + 1), last_failure_at = VALUES(last_failure_at)`,
+		scope, subject, now, now.Add(-decayAfter))
+	if res.Error != nil {
+		return LoginFailure{}, fmt.Errorf("increment login failures: %w", res.Error)
+	}
+	return r.GetLoginFailures(ctx, scope, subject)
+}
+
+// LockSubject locks a subject out until the given time, recording the lockout.
+func (r *Repository) LockSubject(ctx context.Context, scope string, subject string, until time.Time) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&LoginFailure{}).Where("scope = ? AND subject = ?", scope, subject).Update("locked_until", until)
+		if res.Error != nil {
+			return res.Error
+		}
+		return tx.Create(&Lockout{
+			Scope:       scope,
+			Subject:     subject,
+			LockedAt:    time.Now(),
+			LockedUntil: until,
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("lock subject: %w", err)
+	}
+	return nil
+}
+
+// ClearLoginFailures forgets every failure, and any lock, for a subject.
+func (r *Repository) ClearLoginFailures(ctx context.Context, scope string, subject string) error {
+	res := r.db.WithContext(ctx).Where("scope = ? AND subject = ?", scope, subject).Delete(&LoginFailure{})
+	if res.Error != nil {
+		return fmt.Errorf("clear login failures: %w", res.Error)
+	}
+	return nil
+}
+
+// GetActiveLockouts returns lockouts that haven't yet expired or been cleared.
+func (r *Repository) GetActiveLockouts(ctx context.Context) ([]Lockout, error) {
+	var lockouts []Lockout
+	res := r.db.WithContext(ctx).Where("locked_until > ? AND cleared_at IS NULL", time.Now()).
+		Order("locked_at DESC").Find(&lockouts)
+	if res.Error != nil {
+		return nil, fmt.Errorf("retrieve active lockouts: %w", res.Error)
+	}
+	return lockouts, nil
+}
+
+func (r *Repository) GetLockoutByID(ctx context.Context, id int) (Lockout, error) {
+	lockout := Lockout{}
+	res := r.db.WithContext(ctx).Where("id = ?", id).First(&lockout)
+	if res.Error != nil {
+		return Lockout{}, fmt.Errorf("retrieve lockout by id: %w", res.Error)
+	}
+	return lockout, nil
+}
+
+// ClearLockout marks a lockout as cleared by an admin and lifts the lock on its subject.
+func (r *Repository) ClearLockout(ctx context.Context, id int, clearedBy int) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		lockout := Lockout{}
+		res := tx.Where("id = ?", id).First(&lockout)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		res = tx.Model(&Lockout{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"cleared_at": time.Now(),
+			"cleared_by": clearedBy,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+
+		return tx.Where("scope = ? AND subject = ?", lockout.Scope, lockout.Subject).Delete(&LoginFailure{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("clear lockout: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+// ErrReportNotOpen is returned when a moderation change would close a report which has already been resolved.
+var ErrReportNotOpen = errors.New("report is not open")
+
+// ModerationChange is a moderator's decision against a user. ApplyModeration writes it in a single transaction, so an
+// account is never changed without its audit entry, and the report it answers is never left open.
+type ModerationChange struct {
+	UserID int
+	// Standing holds the users columns to change, such as suspended_until or banned. Empty leaves the account as it is.
+	Standing map[string]interface{}
+	// RevokeSessions ends the user's sessions. Revocation, if set, also denies their signed access tokens.
+	RevokeSessions bool
+	Revocation     *TokenRevocation
+	Audit          AuditEntry
+	// ResolveReportID, if set, closes that report with ReportStatus, which fails with ErrReportNotOpen if it has
+	// already been closed.
+	ResolveReportID *int
+	ReportStatus    string
+}
+
+// ApplyModeration applies a moderation change and records it in the audit log, all or nothing.
+func (r *Repository) ApplyModeration(ctx context.Context, change ModerationChange) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(change.Standing) > 0 {
+			res := tx.Model(&User{}).Where("id = ?", change.UserID).Updates(change.Standing)
+			if res.Error != nil {
+				return fmt.Errorf("update user standing: %w", res.Error)
+			}
+		}
+
+		if change.RevokeSessions {
+			res := tx.Where("user_id = ?", change.UserID).Delete(&Session{})
+			if res.Error != nil {
+				return fmt.Errorf("revoke user sessions: %w", res.Error)
+			}
+		}
+		if change.Revocation != nil {
+			res := tx.Create(change.Revocation)
+			if res.Error != nil {
+				return fmt.Errorf("create token revocation: %w", res.Error)
+			}
+		}
+
+		res := tx.Create(&change.Audit)
+		if res.Error != nil {
+			return fmt.Errorf("create audit entry: %w", res.Error)
+		}
+
+		if change.ResolveReportID != nil {
+			res = tx.Model(&Report{}).
+				Where("id = ? AND status = ?", *change.ResolveReportID, ReportStatusOpen).
+				Updates(map[string]interface{}{
+					"status":      change.ReportStatus,
+					"resolved_at": time.Now(),
+					"resolved_by": change.Audit.ActorID,
+				})
+			if res.Error != nil {
+				return fmt.Errorf("resolve report: %w", res.Error)
+			}
+			if res.RowsAffected == 0 {
+				return ErrReportNotOpen
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("apply moderation: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// NotificationUnmatched tells a user that a match has ended because the other user undid their like.
+	NotificationUnmatched = "unmatched"
+)
+
+// Notification is an in-app message to a user about something another user did.
+type Notification struct {
+	ID     int    `json:"id"`
+	UserID int    `json:"-"`
+	Kind   string `json:"kind"`
+	// ActorID is the user whose action caused the notification.
+	ActorID   *int       `json:"actorId,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ReadAt    *time.Time `json:"readAt,omitempty"`
+}
+
+func (r *Repository) CreateNotification(ctx context.Context, n *Notification) error {
+	res := r.db.WithContext(ctx).Create(n)
+	if res.Error != nil {
+		return fmt.Errorf("create notification: %w", res.Error)
+	}
+	return nil
+}
+
+// GetNotifications returns a user's most recent notifications, newest first.
+func (r *Repository) GetNotifications(ctx context.Context, userID int, limit int) ([]Notification, error) {
+	var notifications []Notification
+	res := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC, id DESC").Limit(limit).
+		Find(&notifications)
+	if res.Error != nil {
+		return nil, fmt.Errorf("retrieve notifications: %w", res.Error)
+	}
+	return notifications, nil
+}
+
+// MarkNotificationsRead marks all of a user's unread notifications as read.
+func (r *Repository) MarkNotificationsRead(ctx context.Context, userID int) error {
+	res := r.db.WithContext(ctx).Model(&Notification{}).Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	if res.Error != nil {
+		return fmt.Errorf("mark notifications read: %w", res.Error)
+	}
+	return nil
+}
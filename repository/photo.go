@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// Photo is a picture on a user's profile. The image itself lives in a blob store, referenced by key.
+type Photo struct {
+	ID           int       `json:"id"`
+	UserID       int       `json:"-"`
+	ImageKey     string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	ContentType  string    `json:"-"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	Position     int       `json:"position"`
+	CreatedAt    time.Time `json:"-"`
+	// URL and ThumbnailURL are resolved from the blob store when the photo is read.
+	URL          string `json:"url" gorm:"-"`
+	ThumbnailURL string `json:"thumbnailUrl" gorm:"-"`
+}
+
+// ErrTooManyPhotos is returned when adding a photo would take a user over their limit.
+var ErrTooManyPhotos = errors.New("too many photos")
+
+// CreatePhoto adds a photo after the user's others, provided they have fewer than limit, and marks their profile as
+// changed. The user's row is locked while counting, so concurrent uploads can't both take the last place or the same
+// position.
+func (r *Repository) CreatePhoto(ctx context.Context, photo *Photo, limit int) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", photo.UserID).First(&User{}).Error
+		if err != nil {
+			return err
+		}
+
+		var existing struct {
+			PhotoCount   int
+			NextPosition int
+		}
+		err = tx.Model(&Photo{}).Select("COUNT(*) AS photo_count, COALESCE(MAX(position) + 1, 0) AS next_position").
+			Where("user_id = ?", photo.UserID).Scan(&existing).Error
+		if err != nil {
+			return err
+		}
+		if existing.PhotoCount >= limit {
+			return ErrTooManyPhotos
+		}
+
+		photo.Position = existing.NextPosition
+		err = tx.Create(photo).Error
+		if err != nil {
+			return err
+		}
+		return touchProfile(tx, photo.UserID)
+	})
+	if err != nil {
+		return fmt.Errorf("create photo: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetUserPhoto(ctx context.Context, userID int, photoID int) (Photo, error) {
+	photo := Photo{}
+	res := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", photoID, userID).First(&photo)
+	if res.Error != nil {
+		return Photo{}, fmt.Errorf("retrieve photo: %w", res.Error)
+	}
+	return photo, nil
+}
+
+func (r *Repository) CountUserPhotos(ctx context.Context, userID int) (int, error) {
+	var count int64
+	res := r.db.WithContext(ctx).Model(&Photo{}).Where("user_id = ?", userID).Count(&count)
+	if res.Error != nil {
+		return 0, fmt.Errorf("count user photos: %w", res.Error)
+	}
+	return int(count), nil
+}
+
+// GetPhotosForUsers returns the photos of each of the given users in display order, keyed by user ID.
+func (r *Repository) GetPhotosForUsers(ctx context.Context, userIDs []int) (map[int][]Photo, error) {
+	var photos []Photo
+	res := r.db.WithContext(ctx).Where("user_id IN ?", userIDs).Order("user_id, position, id").Find(&photos)
+	if res.Error != nil {
+		return nil, fmt.Errorf("retrieve photos for users: %w", res.Error)
+	}
+
+	result := make(map[int][]Photo, len(userIDs))
+	for _, p := range photos {
+		result[p.UserID] = append(result[p.UserID], p)
+	}
+	return result, nil
+}
+
+// DeleteUserPhoto removes a photo from a user's profile, marking the profile as changed.
+func (r *Repository) DeleteUserPhoto(ctx context.Context, userID int, photoID int) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ? AND user_id = ?", photoID, userID).Delete(&Photo{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return touchProfile(tx, userID)
+	})
+	if err != nil {
+		return fmt.Errorf("delete photo: %w", err)
+	}
+	return nil
+}
+
+// SetPhotoOrder sets the display position of each of a user's photos to its index in photoIDs, marking their profile
+// as changed.
+func (r *Repository) SetPhotoOrder(ctx context.Context, userID int, photoIDs []int) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, id := range photoIDs {
+			res := tx.Model(&Photo{}).Where("id = ? AND user_id = ?", id, userID).Update("position", i)
+			if res.Error != nil {
+				return res.Error
+			}
+		}
+		return touchProfile(tx, userID)
+	})
+	if err != nil {
+		return fmt.Errorf("set photo order: %w", err)
+	}
+	return nil
+}
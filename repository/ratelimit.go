@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/chackett/dating-service/pkg/ratelimit"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sync"
+	"time"
+)
+
+// rateLimitPruneInterval is how often buckets untouched for a day are deleted.
+const rateLimitPruneInterval = time.Hour
+
+type rateLimitBucket struct {
+	BucketKey string `gorm:"primaryKey"`
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// RateLimitStore keeps rate limit buckets in the DB, so limits are shared between replicas.
+type RateLimitStore struct {
+	db *gorm.DB
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+// RateLimitStore returns a rate limit store backed by this repository's DB.
+func (r *Repository) RateLimitStore() *RateLimitStore {
+	return &RateLimitStore{db: r.db}
+}
+
+func (s *RateLimitStore) Take(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error) {
+	s.prune(ctx, now)
+
+	var res ratelimit.Result
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Make sure the row exists, so it can be locked even on a key's first request.
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rateLimitBucket{
+			BucketKey: key,
+			Tokens:    float64(limit.Burst),
+			UpdatedAt: now,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		row := rateLimitBucket{}
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("bucket_key = ?", key).First(&row).Error
+		if err != nil {
+			return err
+		}
+
+		var b ratelimit.Bucket
+		b, res = ratelimit.Take(ratelimit.Bucket{Tokens: row.Tokens, UpdatedAt: row.UpdatedAt}, limit, now)
+		return tx.Model(&rateLimitBucket{}).Where("bucket_key = ?", key).
+			Updates(map[string]interface{}{"tokens": b.Tokens, "updated_at": b.UpdatedAt}).Error
+	})
+	if err != nil {
+		return ratelimit.Result{}, fmt.Errorf("take rate limit token: %w", err)
+	}
+	return res, nil
+}
+
+// prune deletes long idle buckets. Any bucket untouched for a day is full for every limit in use, so is no different to
+// a missing one.
+func (s *RateLimitStore) prune(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastPrune) < rateLimitPruneInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastPrune = now
+	s.mu.Unlock()
+
+	s.db.WithContext(ctx).Where("updated_at < ?", now.Add(-24*time.Hour)).Delete(&rateLimitBucket{})
+}
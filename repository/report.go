@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	ReportStatusOpen      = "open"
+	ReportStatusActioned  = "actioned"
+	ReportStatusDismissed = "dismissed"
+)
+
+// Report is raised by a user against another user's profile or behaviour, and sits in the moderation queue until an
+// admin resolves it.
+type Report struct {
+	ID         int        `json:"id"`
+	ReporterID int        `json:"reporterId"`
+	ReportedID int        `json:"reportedId"`
+	Reason     string     `json:"reason"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+	ResolvedBy *int       `json:"resolvedBy,omitempty"`
+}
+
+func (r *Repository) CreateReport(ctx context.Context, report *Report) error {
+	res := r.db.WithContext(ctx).Create(report)
+	if res.Error != nil {
+		return fmt.Errorf("create report: %w", res.Error)
+	}
+	return nil
+}
+
+func (r *Repository) GetOpenReports(ctx context.Context) ([]Report, error) {
+	var reports []Report
+	res := r.db.WithContext(ctx).Where("status = ?", ReportStatusOpen).Order("created_at").Find(&reports)
+	if res.Error != nil {
+		return nil, fmt.Errorf("retrieve open reports: %w", res.Error)
+	}
+	return reports, nil
+}
+
+func (r *Repository) GetReportByID(ctx context.Context, id int) (Report, error) {
+	report := Report{}
+	res := r.db.WithContext(ctx).Where("id = ?", id).First(&report)
+	if res.Error != nil {
+		return Report{}, fmt.Errorf("retrieve report by id: %w", res.Error)
+	}
+	return report, nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	_ "github.com/go-sql-driver/mysql"
 	"gorm.io/driver/mysql"
@@ -9,6 +10,7 @@ import (
 	"gorm.io/gorm/clause"
 	"log/slog"
 	"os"
+	"time"
 )
 
 type Repository struct {
@@ -18,7 +20,10 @@ type Repository struct {
 
 func New(user string, pass string, host string, port int, dbName string) (*Repository, error) {
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=True", user, pass, host, port, dbName)
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+		// Translate driver errors into GORM errors such as gorm.ErrDuplicatedKey, so callers can check them with errors.Is.
+		TranslateError: true,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("unable to connect to DB: %w", err)
 	}
@@ -39,18 +44,6 @@ func (r *Repository) CreateUser(ctx context.Context, newUser *User) (*User, erro
 	return newUser, nil
 }
 
-func (r *Repository) UpsertUserPreferences(ctx context.Context, prefs UserPreferences) error {
-	res := r.db.WithContext(ctx).Clauses(
-		clause.OnConflict{
-			UpdateAll: true,
-		},
-	).Create(prefs)
-	if res.Error != nil {
-		return fmt.Errorf("upsert user preferences: %w", res.Error)
-	}
-	return nil
-}
-
 func (r *Repository) GetUserByID(ctx context.Context, id int) (User, error) {
 	u := User{}
 	res := r.db.WithContext(ctx).Where("id = ?", id).First(&u)
@@ -79,12 +72,20 @@ func (r *Repository) CreateUserAuthSession(ctx context.Context, session Session)
 	return nil
 }
 
-func (r *Repository) GetUnratedUsers(ctx context.Context, userID int) ([]User, error) {
+// GetUnratedUsers returns the users that userID has yet to swipe on, or whose pass has expired (see expiredPass). Users
+// who haven't verified their email address, who are banned or suspended, or who have no date of birth to rank their age
+// by, are excluded, as in User.IsDiscoverable.
+func (r *Repository) GetUnratedUsers(ctx context.Context, userID int, passesExpireBefore time.Time) ([]User, error) {
 	var unratedUsers []User
 
-	subquery := r.db.WithContext(ctx).Table("swipes").Select("candidate_id").Where("user_id = ?", userID)
+	subquery := r.db.WithContext(ctx).Table("swipes").Select("swipes.candidate_id").
+		Where("swipes.user_id = ? AND NOT (?)", userID, expiredPass(passesExpireBefore))
 
-	res := r.db.WithContext(ctx).Where("id NOT IN (?) AND id != ?", subquery, userID).Find(&unratedUsers)
+	res := r.db.WithContext(ctx).
+		Where("id NOT IN (?) AND id != ? AND email_verified = ? AND banned = ?", subquery, userID, true, false).
+		Where("suspended_until IS NULL OR suspended_until <= ?", time.Now()).
+		Where("date_of_birth IS NOT NULL").
+		Find(&unratedUsers)
 	if res.Error != nil {
 		return nil, fmt.Errorf("error retrieving unrated users: %w", res.Error)
 	}
@@ -92,15 +93,141 @@ func (r *Repository) GetUnratedUsers(ctx context.Context, userID int) ([]User, e
 	return unratedUsers, nil
 }
 
-func (r *Repository) SubmitSwipe(ctx context.Context, input Swipe) error {
-	res := r.db.WithContext(ctx).Create(input)
+// ErrQuotaExceeded is returned when a like would take a user over their daily quota.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// SubmitSwipeWithinQuota records a swipe, provided the user has made fewer than limit swipes of the same kind since the
+// given time. Likes and super likes are counted separately, and passes are never limited. The user's row is locked
+// while counting, so concurrent likes can't both take the last one. It returns how many swipes of the kind the user has
+// made since then, including this one. An expired pass on the same candidate is replaced, as in SubmitSwipe.
+func (r *Repository) SubmitSwipeWithinQuota(ctx context.Context, input Swipe, since time.Time, limit int, passesExpireBefore time.Time) (int, error) {
+	var used int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", input.UserID).First(&User{}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&Swipe{}).
+			Where("user_id = ? AND likes = ? AND super_like = ? AND created_at >= ?", input.UserID, true, input.SuperLike, since).
+			Count(&used).Error
+		if err != nil {
+			return err
+		}
+		if input.Likes && int(used) >= limit {
+			return ErrQuotaExceeded
+		}
+
+		err = deleteExpiredPass(tx, input, passesExpireBefore)
+		if err != nil {
+			return err
+		}
+		err = tx.Create(&input).Error
+		if err != nil {
+			return err
+		}
+		if input.Likes {
+			used++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("submit swipe to db: %w", err)
+	}
+	return int(used), nil
+}
+
+// CountLikesSince returns how many likes, or super likes, a user has made since the given time.
+func (r *Repository) CountLikesSince(ctx context.Context, userID int, since time.Time, superLikes bool) (int, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Swipe{}).
+		Where("user_id = ? AND likes = ? AND super_like = ? AND created_at >= ?", userID, true, superLikes, since).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("count likes: %w", err)
+	}
+	return int(count), nil
+}
+
+// SetUserPlan changes a user's subscription plan.
+func (r *Repository) SetUserPlan(ctx context.Context, userID int, plan string, expiresAt *time.Time) error {
+	res := r.db.WithContext(ctx).Model(&User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"plan": plan, "plan_expires_at": expiresAt})
 	if res.Error != nil {
-		return fmt.Errorf("submit swipe to db: %w", res.Error)
+		return fmt.Errorf("set user plan: %w", res.Error)
+	}
+	return nil
+}
+
+// SubmitSwipe records a swipe. An expired pass on the candidate (see expiredPass) is replaced, so the candidate can be
+// swiped on again. Any other existing swipe is a gorm.ErrDuplicatedKey.
+func (r *Repository) SubmitSwipe(ctx context.Context, input Swipe, passesExpireBefore time.Time) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := deleteExpiredPass(tx, input, passesExpireBefore)
+		if err != nil {
+			return err
+		}
+		return tx.Create(&input).Error
+	})
+	if err != nil {
+		return fmt.Errorf("submit swipe to db: %w", err)
 	}
 
 	return nil
 }
 
+// deleteExpiredPass removes the user's pass on the swipe's candidate if it has expired, making room for the new swipe
+// under the unique key on swipes.
+func deleteExpiredPass(tx *gorm.DB, input Swipe, passesExpireBefore time.Time) error {
+	return tx.Where("swipes.user_id = ? AND swipes.candidate_id = ? AND ?",
+		input.UserID, input.CandidateID, expiredPass(passesExpireBefore)).Delete(&Swipe{}).Error
+}
+
+// expiredPass matches passes which no longer keep their candidate out of discover: those made before
+// passesExpireBefore, whose candidate has changed their profile since. Likes never expire. It expects the swipes table
+// to be in scope as "swipes".
+func expiredPass(passesExpireBefore time.Time) clause.Expr {
+	return gorm.Expr("swipes.likes = ? AND swipes.created_at < ? AND EXISTS (SELECT 1 FROM users AS candidates "+
+		"WHERE candidates.id = swipes.candidate_id AND candidates.profile_updated_at > swipes.created_at)",
+		false, passesExpireBefore)
+}
+
+// GetSwipesByUser returns up to limit of a user's swipes, newest first. Passing the ID of the last swipe from the
+// previous page as beforeID returns the next page. A beforeID of 0 starts from the newest.
+func (r *Repository) GetSwipesByUser(ctx context.Context, userID int, beforeID int, limit int) ([]Swipe, error) {
+	var swipes []Swipe
+	q := r.db.WithContext(ctx).Where("user_id = ?", userID)
+	if beforeID > 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+	err := q.Order("id DESC").Limit(limit).Find(&swipes).Error
+	if err != nil {
+		return nil, fmt.Errorf("retrieve swipes: %w", err)
+	}
+	return swipes, nil
+}
+
+// GetSuperLikers returns which of the given users have super liked userID.
+func (r *Repository) GetSuperLikers(ctx context.Context, userID int, candidateIDs []int) (map[int]bool, error) {
+	result := map[int]bool{}
+	if len(candidateIDs) == 0 {
+		return result, nil
+	}
+
+	var likers []int
+	err := r.db.WithContext(ctx).Model(&Swipe{}).
+		Where("candidate_id = ? AND super_like = ? AND user_id IN ?", userID, true, candidateIDs).
+		Pluck("user_id", &likers).Error
+	if err != nil {
+		return nil, fmt.Errorf("retrieve super likers: %w", err)
+	}
+	for _, id := range likers {
+		result[id] = true
+	}
+	return result, nil
+}
+
+// IsUserMatch reports whether two users have each liked the other. Super likes are likes too, so count towards a match.
 func (r *Repository) IsUserMatch(ctx context.Context, userID int, candidateID int) (bool, error) {
 	var count int64
 	err := r.db.WithContext(ctx).Table("swipes").
@@ -114,25 +241,94 @@ func (r *Repository) IsUserMatch(ctx context.Context, userID int, candidateID in
 	return count == 2, nil
 }
 
-func (r *Repository) GetUserFromAuthToken(ctx context.Context, token string) (*User, error) {
-	user := &User{}
-	res := r.db.WithContext(ctx).Joins("JOIN sessions ON sessions.user_id = users.id").
-		Where("sessions.token = ?", token).
-		First(user)
+// GetSessionByToken returns the session for a token, provided it hasn't expired.
+func (r *Repository) GetSessionByToken(ctx context.Context, token string) (Session, error) {
+	session := Session{}
+	res := r.db.WithContext(ctx).Where("token = ? AND expires_at > ?", token, time.Now()).First(&session)
+	if res.Error != nil {
+		return Session{}, fmt.Errorf("session not found for auth token: %w", res.Error)
+	}
+	return session, nil
+}
+
+// RevokeUserSessions deletes every session belonging to a user, forcing them to log in again.
+func (r *Repository) RevokeUserSessions(ctx context.Context, userID int) error {
+	res := r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&Session{})
+	if res.Error != nil {
+		return fmt.Errorf("revoke user sessions: %w", res.Error)
+	}
+	return nil
+}
+
+// DeleteSession deletes a single session, logging it out.
+func (r *Repository) DeleteSession(ctx context.Context, sessionID int) error {
+	res := r.db.WithContext(ctx).Where("id = ?", sessionID).Delete(&Session{})
+	if res.Error != nil {
+		return fmt.Errorf("delete session: %w", res.Error)
+	}
+	return nil
+}
+
+// UpdateUser writes the given columns to a user's row. Keys are column names.
+func (r *Repository) UpdateUser(ctx context.Context, userID int, fields map[string]interface{}) error {
+	res := r.db.WithContext(ctx).Model(&User{}).Where("id = ?", userID).Updates(fields)
+	if res.Error != nil {
+		return fmt.Errorf("update user: %w", res.Error)
+	}
+	return nil
+}
+
+// GetUsersWithExactLocations returns up to limit users, in ID order after afterID, whose location or travel location is
+// still stored as "lat,long" rather than as a geohash.
+func (r *Repository) GetUsersWithExactLocations(ctx context.Context, afterID int, limit int) ([]User, error) {
+	var users []User
+	res := r.db.WithContext(ctx).
+		Where("id > ? AND (location LIKE ? OR travel_location LIKE ?)", afterID, "%,%", "%,%").
+		Order("id").Limit(limit).Find(&users)
 	if res.Error != nil {
-		return nil, fmt.Errorf("user not found for auth token: %w", res.Error)
+		return nil, fmt.Errorf("retrieve users with exact locations: %w", res.Error)
+	}
+	return users, nil
+}
+
+// touchProfile records that a user's profile changed just now, so passes made on them before can expire.
+func touchProfile(tx *gorm.DB, userID int) error {
+	return tx.Model(&User{}).Where("id = ?", userID).Update("profile_updated_at", time.Now()).Error
+}
+
+// HasSwiped reports whether a user has already swiped on a candidate, in either direction. An expired pass (see
+// expiredPass) no longer counts.
+func (r *Repository) HasSwiped(ctx context.Context, userID int, candidateID int, passesExpireBefore time.Time) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Table("swipes").
+		Where("swipes.user_id = ? AND swipes.candidate_id = ? AND NOT (?)", userID, candidateID, expiredPass(passesExpireBefore)).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("query for existing swipe: %w", err)
 	}
-	return user, nil
+	return count > 0, nil
 }
 
-func (r *Repository) GetUserPreferences(ctx context.Context, userID int) (UserPreferences, error) {
-	var preferences UserPreferences
-	res := r.db.WithContext(ctx).Table("user_preferences").Joins("JOIN users ON user_id = users.id").
-		Where("users.id = ?", userID).
-		First(&preferences)
+func (r *Repository) SetUserRole(ctx context.Context, userID int, role string) error {
+	res := r.db.WithContext(ctx).Model(&User{}).Where("id = ?", userID).Update("role", role)
 	if res.Error != nil {
-		return UserPreferences{}, fmt.Errorf("user preferences not found for user (%d): %w", userID, res.Error)
+		return fmt.Errorf("set user role: %w", res.Error)
 	}
+	return nil
+}
 
-	return preferences, nil
+// GetSwipesBetween returns the swipes exchanged in either direction between two users, oldest first.
+func (r *Repository) GetSwipesBetween(ctx context.Context, userID int, otherUserID int) ([]Swipe, error) {
+	var swipes []Swipe
+	res := r.db.WithContext(ctx).
+		Where("(user_id = ? AND candidate_id = ?) OR (user_id = ? AND candidate_id = ?)",
+			userID, otherUserID, otherUserID, userID).
+		Order("created_at").
+		Find(&swipes)
+	if res.Error != nil {
+		return nil, fmt.Errorf("retrieve swipes between users: %w", res.Error)
+	}
+	return swipes, nil
 }
+
+// SetUserSuspension suspends a user until the given time. A nil value lifts any existing suspension.
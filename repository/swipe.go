@@ -1,7 +1,15 @@
 package repository
 
+import "time"
+
 type Swipe struct {
+	// ID is never accepted from the client.
+	ID          int  `json:"-"`
 	UserID      int  `json:"userId,omitempty"`
 	CandidateID int  `json:"candidateId"`
 	Likes       bool `json:"likes"`
+	// SuperLike marks a like the candidate is shown, and which puts the user near the top of their discover results.
+	// A super like is always also a like.
+	SuperLike bool      `json:"superLike,omitempty"`
+	CreatedAt time.Time `json:"createdAt,omitempty"`
 }
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TokenRevocation denylists signed access tokens before they expire. It names either a single token, by its ID, or a
+// user, in which case every token issued to them up to RevokedAt is revoked. Entries are only needed until the tokens
+// they cover would have expired anyway, so the list stays small.
+type TokenRevocation struct {
+	ID        int
+	TokenID   *string
+	UserID    *int
+	RevokedAt time.Time
+	ExpiresAt time.Time
+}
+
+func (r *Repository) CreateTokenRevocation(ctx context.Context, revocation *TokenRevocation) error {
+	res := r.db.WithContext(ctx).Create(revocation)
+	if res.Error != nil {
+		return fmt.Errorf("create token revocation: %w", res.Error)
+	}
+	return nil
+}
+
+// GetActiveTokenRevocations returns revocations which still cover unexpired tokens.
+func (r *Repository) GetActiveTokenRevocations(ctx context.Context) ([]TokenRevocation, error) {
+	var revocations []TokenRevocation
+	res := r.db.WithContext(ctx).Where("expires_at > ?", time.Now()).Find(&revocations)
+	if res.Error != nil {
+		return nil, fmt.Errorf("retrieve token revocations: %w", res.Error)
+	}
+	return revocations, nil
+}
+
+// DeleteExpiredTokenRevocations removes revocations whose tokens have all expired.
+func (r *Repository) DeleteExpiredTokenRevocations(ctx context.Context) error {
+	res := r.db.WithContext(ctx).Where("expires_at <= ?", time.Now()).Delete(&TokenRevocation{})
+	if res.Error != nil {
+		return fmt.Errorf("delete expired token revocations: %w", res.Error)
+	}
+	return nil
+}
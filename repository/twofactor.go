@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// UserTOTP is a user's TOTP enrolment. The secret is stored encrypted, and the enrolment only takes effect once
+// confirmed with a valid code.
+type UserTOTP struct {
+	UserID          int `gorm:"primaryKey"`
+	SecretEncrypted string
+	ConfirmedAt     *time.Time
+	// LastUsedStep is the time step of the last accepted code, so a code can't be replayed within its window.
+	LastUsedStep *int64
+	CreatedAt    time.Time
+}
+
+func (UserTOTP) TableName() string {
+	return "user_totp"
+}
+
+// RecoveryCode is a one-time code that can stand in for a TOTP code. Only a hash is stored.
+type RecoveryCode struct {
+	ID       int
+	UserID   int
+	CodeHash string
+	UsedAt   *time.Time
+}
+
+func (RecoveryCode) TableName() string {
+	return "user_recovery_codes"
+}
+
+// GetUserTOTP returns a user's TOTP enrolment, or nil if they have none.
+func (r *Repository) GetUserTOTP(ctx context.Context, userID int) (*UserTOTP, error) {
+	totp := &UserTOTP{}
+	res := r.db.WithContext(ctx).Where("user_id = ?", userID).First(totp)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if res.Error != nil {
+		return nil, fmt.Errorf("retrieve user totp: %w", res.Error)
+	}
+	return totp, nil
+}
+
+// UpsertUserTOTP starts, or restarts, an unconfirmed enrolment with a new secret.
+func (r *Repository) UpsertUserTOTP(ctx context.Context, userID int, secretEncrypted string) error {
+	res := r.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(&UserTOTP{
+		UserID:          userID,
+		SecretEncrypted: secretEncrypted,
+		CreatedAt:       time.Now(),
+	})
+	if res.Error != nil {
+		return fmt.Errorf("upsert user totp: %w", res.Error)
+	}
+	return nil
+}
+
+// ConfirmUserTOTP activates an enrolment and replaces the user's recovery codes, all at once.
+func (r *Repository) ConfirmUserTOTP(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&UserTOTP{}).Where("user_id = ?", userID).Updates(map[string]interface{}{
+			"confirmed_at":   time.Now(),
+			"last_used_step": step,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+
+		res = tx.Where("user_id = ?", userID).Delete(&RecoveryCode{})
+		if res.Error != nil {
+			return res.Error
+		}
+
+		codes := make([]RecoveryCode, 0, len(recoveryCodeHashes))
+		for _, h := range recoveryCodeHashes {
+			codes = append(codes, RecoveryCode{UserID: userID, CodeHash: h})
+		}
+		return tx.Create(&codes).Error
+	})
+	if err != nil {
+		return fmt.Errorf("confirm user totp: %w", err)
+	}
+	return nil
+}
+
+// AdvanceTOTPStep records a code's time step as used. It fails if that step, or a later one, has already been used.
+func (r *Repository) AdvanceTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&UserTOTP{}).
+		Where("user_id = ? AND (last_used_step IS NULL OR last_used_step < ?)", userID, step).
+		Update("last_used_step", step)
+	if res.Error != nil {
+		return false, fmt.Errorf("advance totp step: %w", res.Error)
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// UseRecoveryCode marks a recovery code as used, reporting whether it was valid and unused.
+func (r *Repository) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return false, fmt.Errorf("use recovery code: %w", res.Error)
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// DeleteUserTOTP removes a user's enrolment and recovery codes, turning 2FA off.
+func (r *Repository) DeleteUserTOTP(ctx context.Context, userID int) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{})
+		if res.Error != nil {
+			return res.Error
+		}
+		return tx.Where("user_id = ?", userID).Delete(&UserTOTP{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("delete user totp: %w", err)
+	}
+	return nil
+}
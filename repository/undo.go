@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// ErrNothingToUndo is returned when a user has no swipe recent enough to undo.
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// SwipeUndo records a swipe being undone, so undos can be limited per day.
+type SwipeUndo struct {
+	ID          int
+	UserID      int
+	CandidateID int
+	CreatedAt   time.Time
+}
+
+// UndoResult describes a swipe which was undone.
+type UndoResult struct {
+	Swipe Swipe
+	// Unmatched is set if the swipe had made a match, which is now gone.
+	Unmatched bool
+	// Used is how many undos the user has made since the quota's start, including this one.
+	Used int
+}
+
+// UndoLatestSwipe deletes a user's most recent swipe, provided it was made at or after notBefore. If limit is not
+// negative, the user may only have undone limit swipes since quotaSince. The user's row is locked throughout, so a swipe
+// or undo made concurrently can't interleave. Matches are derived from mutual likes, so undoing a like which made one
+// ends it, and the other user is sent a NotificationUnmatched in the same transaction.
+func (r *Repository) UndoLatestSwipe(ctx context.Context, userID int, notBefore time.Time, quotaSince time.Time, limit int) (UndoResult, error) {
+	result := UndoResult{}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", userID).First(&User{}).Error
+		if err != nil {
+			return err
+		}
+
+		swipe := Swipe{}
+		err = tx.Where("user_id = ?", userID).Order("created_at DESC, id DESC").First(&swipe).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && swipe.CreatedAt.Before(notBefore)) {
+			return ErrNothingToUndo
+		}
+		if err != nil {
+			return err
+		}
+
+		var used int64
+		err = tx.Model(&SwipeUndo{}).Where("user_id = ? AND created_at >= ?", userID, quotaSince).Count(&used).Error
+		if err != nil {
+			return err
+		}
+		if limit >= 0 && int(used) >= limit {
+			return ErrQuotaExceeded
+		}
+
+		var reciprocal int64
+		if swipe.Likes {
+			err = tx.Model(&Swipe{}).Where("user_id = ? AND candidate_id = ? AND likes = ?", swipe.CandidateID, userID, true).
+				Count(&reciprocal).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		err = tx.Where("id = ?", swipe.ID).Delete(&Swipe{}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Create(&SwipeUndo{UserID: userID, CandidateID: swipe.CandidateID, CreatedAt: time.Now()}).Error
+		if err != nil {
+			return err
+		}
+		if reciprocal > 0 {
+			err = tx.Create(&Notification{
+				UserID:    swipe.CandidateID,
+				Kind:      NotificationUnmatched,
+				ActorID:   &userID,
+				CreatedAt: time.Now(),
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		result = UndoResult{Swipe: swipe, Unmatched: reciprocal > 0, Used: int(used) + 1}
+		return nil
+	})
+	if err != nil {
+		return UndoResult{}, fmt.Errorf("undo latest swipe: %w", err)
+	}
+	return result, nil
+}
+
+// CountUndosSince returns how many swipes a user has undone since the given time.
+func (r *Repository) CountUndosSince(ctx context.Context, userID int, since time.Time) (int, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&SwipeUndo{}).Where("user_id = ? AND created_at >= ?", userID, since).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("count undos: %w", err)
+	}
+	return int(count), nil
+}
@@ -1,24 +1,104 @@
 package repository
 
 import (
+	"errors"
+	"github.com/chackett/dating-service/pkg/geohash"
 	"github.com/umahmood/haversine"
+	"math"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// maxSharedInterestScore caps the contribution of shared interests to a candidate's ranking.
+const maxSharedInterestScore = 3
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+	RoleService   = "service"
+)
+
+const (
+	PlanFree    = "free"
+	PlanPremium = "premium"
+)
+
 type User struct {
-	ID          int        `json:"id,omitempty"`
-	Email       string     `json:"email,omitempty"`
-	Password    string     `json:"password,omitempty" `
-	Name        string     `json:"name,omitempty"`
-	Gender      string     `json:"gender,omitempty"`
-	DateOfBirth *time.Time `json:"dateOfBirth,omitempty"`
-	Age         int        `json:"age,omitempty" gorm:"-"`
-	Location    string     `json:"location,omitempty"`
+	ID          int            `json:"id,omitempty"`
+	Email       string         `json:"email,omitempty"`
+	Password    string         `json:"password,omitempty" `
+	Name        string         `json:"name,omitempty"`
+	Gender      string         `json:"gender,omitempty"`
+	DateOfBirth *time.Time     `json:"dateOfBirth,omitempty"`
+	Age         int            `json:"age,omitempty" gorm:"-"`
+	Location    string         `json:"location,omitempty"`
+	Bio         string         `json:"bio,omitempty"`
+	Interests   []Interest     `json:"interests,omitempty" gorm:"-"`
+	Prompts     []PromptAnswer `json:"prompts,omitempty" gorm:"-"`
+	Photos      []Photo        `json:"photos,omitempty" gorm:"-"`
+	// EmailVerified is cleared whenever the email address changes, until the new address is verified.
+	EmailVerified bool `json:"emailVerified,omitempty"`
+	// Role determines which route groups the user may access. New users are always RoleUser.
+	Role string `json:"role,omitempty"`
+	// SuspendedUntil is set by a moderator to temporarily block the account from logging in or using the API.
+	SuspendedUntil *time.Time `json:"suspendedUntil,omitempty"`
+	// Banned permanently blocks the account.
+	Banned bool `json:"banned,omitempty"`
+	// Plan is the user's subscription, PlanFree or PlanPremium. A premium plan lapses back to free at PlanExpiresAt, if
+	// set.
+	Plan          string     `json:"plan,omitempty"`
+	PlanExpiresAt *time.Time `json:"planExpiresAt,omitempty"`
+	// Timezone is an IANA zone name, used to decide when the user's day starts for daily quotas.
+	Timezone string `json:"timezone,omitempty"`
+	// BoostCredits is how many purchased boosts the user has left to start.
+	BoostCredits int `json:"boostCredits,omitempty"`
+	// TravelLocation is a "lat,long" a premium user will be at between TravelStartsAt and TravelEndsAt. While then, it
+	// stands in for Location in distances and ranking, both for the user and for those seeing them.
+	TravelLocation *string    `json:"travelLocation,omitempty"`
+	TravelStartsAt *time.Time `json:"travelStartsAt,omitempty"`
+	TravelEndsAt   *time.Time `json:"travelEndsAt,omitempty"`
+	// LocationUpdatedAt is when the user last changed their location or travel location.
+	LocationUpdatedAt *time.Time `json:"-"`
+	// ProfileUpdatedAt is when the user last changed what others see on their profile. Only passes made before then can
+	// expire.
+	ProfileUpdatedAt *time.Time `json:"-"`
+}
+
+// HasPremium reports whether the user is on an unexpired premium plan.
+func (u *User) HasPremium(now time.Time) bool {
+	return u.Plan == PlanPremium && (u.PlanExpiresAt == nil || u.PlanExpiresAt.After(now))
+}
+
+// LocalDay returns the start of the user's current day and of the next one, in their timezone. An unset or unknown
+// timezone is treated as UTC.
+func (u *User) LocalDay(now time.Time) (time.Time, time.Time) {
+	loc, err := time.LoadLocation(u.Timezone)
+	if err != nil || u.Timezone == "" {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// IsSuspended reports whether the account is currently serving a suspension.
+func (u *User) IsSuspended() bool {
+	return u.SuspendedUntil != nil && u.SuspendedUntil.After(time.Now())
+}
+
+// IsDiscoverable reports whether the user may be shown to others as a discover candidate: verified, neither banned nor
+// suspended, and with a date of birth to rank their age by. GetUnratedUsers applies the same rules in SQL.
+func (u *User) IsDiscoverable() bool {
+	return u.EmailVerified && !u.Banned && !u.IsSuspended() && u.DateOfBirth != nil
 }
 
+// CalculateAge returns the user's age in whole years, or 0 if their date of birth isn't known.
 func (u *User) CalculateAge() int {
+	if u.DateOfBirth == nil {
+		return 0
+	}
 	now := time.Now()
 	age := now.Year() - u.DateOfBirth.Year()
 
@@ -28,21 +108,58 @@ func (u *User) CalculateAge() int {
 	return age
 }
 
-func (u *User) ReadLocation() haversine.Coord {
-	spl := strings.Split(u.Location, ",")
+// ParseLocation reads a "lat,long" string as stored in `users.location`, validating that the values are in range.
+func ParseLocation(location string) (haversine.Coord, error) {
+	spl := strings.Split(location, ",")
+	if len(spl) != 2 {
+		return haversine.Coord{}, errors.New("location must be in the form lat,long")
+	}
 
-	fLat, err := strconv.ParseFloat(spl[0], 32)
-	if err != nil {
-		return haversine.Coord{}
+	// NaN fails every comparison, so would pass the range checks without its own. Infinities are out of range anyway,
+	// but are rejected explicitly too.
+	fLat, err := strconv.ParseFloat(strings.TrimSpace(spl[0]), 64)
+	if err != nil || math.IsNaN(fLat) || math.IsInf(fLat, 0) || fLat < -90 || fLat > 90 {
+		return haversine.Coord{}, errors.New("invalid latitude")
+	}
+	fLong, err := strconv.ParseFloat(strings.TrimSpace(spl[1]), 64)
+	if err != nil || math.IsNaN(fLong) || math.IsInf(fLong, 0) || fLong < -180 || fLong > 180 {
+		return haversine.Coord{}, errors.New("invalid longitude")
+	}
+
+	return haversine.Coord{Lat: fLat, Lon: fLong}, nil
+}
+
+// IsTravelling reports whether the user's travel location is in effect at now. It lapses with the travel dates, or if
+// the user loses premium.
+func (u *User) IsTravelling(now time.Time) bool {
+	return u.TravelLocation != nil && u.TravelStartsAt != nil && u.TravelEndsAt != nil &&
+		!now.Before(*u.TravelStartsAt) && now.Before(*u.TravelEndsAt) && u.HasPremium(now)
+}
+
+// ReadLocation returns the user's current coordinates, which are their travel location while travelling.
+func (u *User) ReadLocation() haversine.Coord {
+	location := u.Location
+	if u.IsTravelling(time.Now()) {
+		location = *u.TravelLocation
 	}
-	fLong, err := strconv.ParseFloat(spl[1], 32)
+	coord, err := DecodeLocation(location)
 	if err != nil {
 		return haversine.Coord{}
 	}
-	return haversine.Coord{
-		Lat: fLat,
-		Lon: fLong,
+	return coord
+}
+
+// DecodeLocation reads a location as stored, which is either "lat,long" or, when only coarse locations are kept, a
+// geohash. A geohash is read as the centre of its cell.
+func DecodeLocation(location string) (haversine.Coord, error) {
+	if strings.Contains(location, ",") {
+		return ParseLocation(location)
+	}
+	lat, lon, err := geohash.Decode(location)
+	if err != nil {
+		return haversine.Coord{}, err
 	}
+	return haversine.Coord{Lat: lat, Lon: lon}, nil
 }
 
 func (u *User) DistanceFromUser(candidate User) int {
@@ -54,34 +171,61 @@ func (u *User) DistanceFromUser(candidate User) int {
 func (u *User) RankCandidate(candidate User, userPrefs UserPreferences, canPrefs UserPreferences) (int, error) {
 
 	// Ranking is used to score a candidate. Note for total mismatched candidates, -1 is returned immediately.
-	// While other comparisons might not be a direct match, it doesn't indicate a total lack of suitability.
+	// While other comparisons might not be a direct match, it doesn't indicate a total lack of suitability, unless the
+	// user has marked that preference as a dealbreaker.
 	ranking := 0
-	if !contains(userPrefs.ReadGenders(), candidate.Gender) {
+	if !u.GendersSuit(candidate, userPrefs, canPrefs) {
 		return -1, nil
 	}
+	dealbreakers := userPrefs.Dealbreakers
 
 	candidateAge := candidate.CalculateAge()
 	if candidateAge >= userPrefs.MinAge && candidateAge <= userPrefs.MaxAge {
 		// TODO I want to improve this so that I can add the weight of the age gap.
 		// so that a smaller gap adds a higher score, and large is a lower score.
 		ranking++
+	} else if dealbreakers.Age {
+		return -1, nil
 	}
 
 	if userPrefs.EnjoysTravel && canPrefs.EnjoysTravel {
 		ranking++
+	} else if userPrefs.EnjoysTravel && dealbreakers.Travel {
+		return -1, nil
 	}
 
-	if userPrefs.EducationLevel == canPrefs.EducationLevel {
+	if userPrefs.AcceptsEducation(canPrefs) {
 		ranking++
+	} else if dealbreakers.Education {
+		return -1, nil
 	}
 
 	if userPrefs.WantsChildren && canPrefs.WantsChildren {
 		ranking++
 	}
+	if dealbreakers.Children && userPrefs.WantsChildren != canPrefs.WantsChildren {
+		return -1, nil
+	}
+
+	// Shared interests add to the score, capped so that they can't outweigh everything else.
+	shared := u.SharedInterests(candidate)
+	if shared > maxSharedInterestScore {
+		shared = maxSharedInterestScore
+	}
+	ranking += shared
 
 	_, km := haversine.Distance(u.ReadLocation(), candidate.ReadLocation())
 
-	// This ranking based on distance leaves a lot to be desired.. but it gives an idea.
+	withinMax := userPrefs.MaxDistanceKm == 0 || km <= float64(userPrefs.MaxDistanceKm)
+	if !withinMax && dealbreakers.Distance {
+		return -1, nil
+	}
+
+	// This ranking based on distance leaves a lot to be desired.. but it gives an idea. Candidates beyond the user's
+	// maximum distance get nothing for it.
+	if !withinMax {
+		return ranking, nil
+	}
 	if km < 1000 {
 		ranking += 3
 	} else if km < 2000 {
@@ -93,9 +237,41 @@ func (u *User) RankCandidate(candidate User, userPrefs UserPreferences, canPrefs
 	return ranking, nil
 }
 
-func contains(s []string, e string) bool {
+// GendersSuit reports whether both sides' gender settings allow the user and candidate to be paired: each wants to see
+// the other's gender, and each may be shown to the other's gender. An empty ShowMeTo may be shown to anyone.
+func (u *User) GendersSuit(candidate User, userPrefs UserPreferences, canPrefs UserPreferences) bool {
+	if !containsFold(userPrefs.Genders, candidate.Gender) || !containsFold(canPrefs.Genders, u.Gender) {
+		return false
+	}
+	if len(userPrefs.ShowMeTo) > 0 && !containsFold(userPrefs.ShowMeTo, candidate.Gender) {
+		return false
+	}
+	if len(canPrefs.ShowMeTo) > 0 && !containsFold(canPrefs.ShowMeTo, u.Gender) {
+		return false
+	}
+	return true
+}
+
+// SharedInterests counts the interests that both users have tagged. Interests must have been loaded onto both users.
+func (u *User) SharedInterests(candidate User) int {
+	mine := make(map[int]bool, len(u.Interests))
+	for _, i := range u.Interests {
+		mine[i.ID] = true
+	}
+
+	count := 0
+	for _, i := range candidate.Interests {
+		if mine[i.ID] {
+			count++
+		}
+	}
+	return count
+}
+
+// containsFold reports whether s contains e, ignoring case.
+func containsFold(s []string, e string) bool {
 	for _, a := range s {
-		if a == e {
+		if strings.EqualFold(a, e) {
 			return true
 		}
 	}
@@ -107,4 +283,15 @@ func (u *User) MaskPrivateFields() {
 	u.DateOfBirth = nil
 	u.Password = ""
 	u.Email = ""
+	u.EmailVerified = false
+	u.Role = ""
+	u.SuspendedUntil = nil
+	u.Banned = false
+	u.Plan = ""
+	u.PlanExpiresAt = nil
+	u.Timezone = ""
+	u.BoostCredits = 0
+	u.TravelLocation = nil
+	u.TravelStartsAt = nil
+	u.TravelEndsAt = nil
 }
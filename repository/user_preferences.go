@@ -1,17 +1,217 @@
 package repository
 
-import "strings"
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"slices"
+)
 
 type UserPreferences struct {
-	UserID         int    `json:"userId"`
-	WantsChildren  bool   `json:"wantsChildren"`
-	EnjoysTravel   bool   `json:"enjoysTravel"`
+	UserID        int  `json:"userId"`
+	WantsChildren bool `json:"wantsChildren"`
+	EnjoysTravel  bool `json:"enjoysTravel"`
+	// EducationLevel is the user's own education, one of the codes on the education scale.
 	EducationLevel string `json:"educationLevel"`
 	MinAge         int    `json:"minAge"`
 	MaxAge         int    `json:"maxAge"`
-	Genders        string `json:"genders"`
+	// Genders are the genders the user wants to see.
+	Genders []string `json:"genders" gorm:"-"`
+	// ShowMeTo limits who the user is shown to, by gender. Empty means everyone.
+	ShowMeTo []string `json:"showMeTo" gorm:"-"`
+	// AcceptedEducationLevels and MinEducationLevel limit the education candidates may have. A candidate must have one
+	// of the accepted levels, if any are given, and be at or above the minimum, if one is given.
+	AcceptedEducationLevels []string `json:"acceptedEducationLevels" gorm:"-"`
+	MinEducationLevel       string   `json:"minEducationLevel,omitempty"`
+	// MaxDistanceKm is how far away candidates may be. Zero means any distance.
+	MaxDistanceKm int `json:"maxDistanceKm"`
+	// Dealbreakers marks which preferences rule out candidates who don't meet them. The rest only add to a
+	// candidate's ranking when met. Gender is always a dealbreaker.
+	Dealbreakers PreferenceDealbreakers `json:"dealbreakers" gorm:"embedded;embeddedPrefix:dealbreaker_"`
+
+	// EducationRank and MinEducationRank place EducationLevel and MinEducationLevel on the education scale, with 0 for
+	// levels not on it. They are filled in by GetUserPreferences.
+	EducationRank    int `json:"-" gorm:"-"`
+	MinEducationRank int `json:"-" gorm:"-"`
+}
+
+// PreferenceDealbreakers flags each preference as either a dealbreaker or, when false, a weighted preference.
+type PreferenceDealbreakers struct {
+	// Age requires candidates to be within MinAge and MaxAge.
+	Age bool `json:"age"`
+	// Children requires candidates to agree on WantsChildren.
+	Children bool `json:"children"`
+	// Education requires candidates to meet AcceptedEducationLevels and MinEducationLevel.
+	Education bool `json:"education"`
+	// Distance requires candidates to be within MaxDistanceKm.
+	Distance bool `json:"distance"`
+	// Travel requires candidates to enjoy travel, if the user does.
+	Travel bool `json:"travel"`
+}
+
+// EducationLevel is a step on the education scale. Higher levels are further along it.
+type EducationLevel struct {
+	Code  string `json:"code" gorm:"primaryKey"`
+	Name  string `json:"name"`
+	Level int    `json:"level"`
+}
+
+// userPreferenceGender is the join row between a user's preferences and a gender they want to see.
+type userPreferenceGender struct {
+	UserID int
+	Gender string
+}
+
+func (userPreferenceGender) TableName() string {
+	return "user_preference_genders"
+}
+
+// userPreferenceShowMeTo is the join row between a user's preferences and a gender they may be shown to.
+type userPreferenceShowMeTo struct {
+	UserID int
+	Gender string
+}
+
+func (userPreferenceShowMeTo) TableName() string {
+	return "user_preference_show_me_to"
+}
+
+// userPreferenceEducation is the join row between a user's preferences and an education level they accept.
+type userPreferenceEducation struct {
+	UserID         int
+	EducationLevel string
 }
 
-func (u *UserPreferences) ReadGenders() []string {
-	return strings.Split(u.Genders, ",")
+func (userPreferenceEducation) TableName() string {
+	return "user_preference_education_levels"
+}
+
+// AcceptsEducation reports whether a candidate, going by their preferences, has the education the user asks for.
+func (u *UserPreferences) AcceptsEducation(candidate UserPreferences) bool {
+	if len(u.AcceptedEducationLevels) > 0 && !slices.Contains(u.AcceptedEducationLevels, candidate.EducationLevel) {
+		return false
+	}
+	return u.MinEducationLevel == "" || candidate.EducationRank >= u.MinEducationRank
+}
+
+// GetEducationLevels returns the education scale, lowest first.
+func (r *Repository) GetEducationLevels(ctx context.Context) ([]EducationLevel, error) {
+	var levels []EducationLevel
+	res := r.db.WithContext(ctx).Order("level").Find(&levels)
+	if res.Error != nil {
+		return nil, fmt.Errorf("retrieve education levels: %w", res.Error)
+	}
+	return levels, nil
+}
+
+func (r *Repository) UpsertUserPreferences(ctx context.Context, prefs UserPreferences) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(
+			clause.OnConflict{
+				UpdateAll: true,
+			},
+		).Create(&prefs)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		res = tx.Where("user_id = ?", prefs.UserID).Delete(&userPreferenceGender{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if len(prefs.Genders) > 0 {
+			rows := make([]userPreferenceGender, 0, len(prefs.Genders))
+			for _, g := range prefs.Genders {
+				rows = append(rows, userPreferenceGender{UserID: prefs.UserID, Gender: g})
+			}
+			res = tx.Create(&rows)
+			if res.Error != nil {
+				return res.Error
+			}
+		}
+
+		res = tx.Where("user_id = ?", prefs.UserID).Delete(&userPreferenceShowMeTo{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if len(prefs.ShowMeTo) > 0 {
+			rows := make([]userPreferenceShowMeTo, 0, len(prefs.ShowMeTo))
+			for _, g := range prefs.ShowMeTo {
+				rows = append(rows, userPreferenceShowMeTo{UserID: prefs.UserID, Gender: g})
+			}
+			res = tx.Create(&rows)
+			if res.Error != nil {
+				return res.Error
+			}
+		}
+
+		res = tx.Where("user_id = ?", prefs.UserID).Delete(&userPreferenceEducation{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if len(prefs.AcceptedEducationLevels) > 0 {
+			rows := make([]userPreferenceEducation, 0, len(prefs.AcceptedEducationLevels))
+			for _, level := range prefs.AcceptedEducationLevels {
+				rows = append(rows, userPreferenceEducation{UserID: prefs.UserID, EducationLevel: level})
+			}
+			res = tx.Create(&rows)
+			if res.Error != nil {
+				return res.Error
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("upsert user preferences: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetUserPreferences(ctx context.Context, userID int) (UserPreferences, error) {
+	var preferences UserPreferences
+	res := r.db.WithContext(ctx).Where("user_id = ?", userID).Take(&preferences)
+	if res.Error != nil {
+		return UserPreferences{}, fmt.Errorf("user preferences not found for user (%d): %w", userID, res.Error)
+	}
+
+	preferences.Genders = []string{}
+	res = r.db.WithContext(ctx).Model(&userPreferenceGender{}).Where("user_id = ?", userID).Order("gender").
+		Pluck("gender", &preferences.Genders)
+	if res.Error != nil {
+		return UserPreferences{}, fmt.Errorf("retrieve preferred genders: %w", res.Error)
+	}
+
+	preferences.ShowMeTo = []string{}
+	res = r.db.WithContext(ctx).Model(&userPreferenceShowMeTo{}).Where("user_id = ?", userID).Order("gender").
+		Pluck("gender", &preferences.ShowMeTo)
+	if res.Error != nil {
+		return UserPreferences{}, fmt.Errorf("retrieve show me to genders: %w", res.Error)
+	}
+
+	preferences.AcceptedEducationLevels = []string{}
+	res = r.db.WithContext(ctx).Model(&userPreferenceEducation{}).
+		Joins("JOIN education_levels ON education_levels.code = user_preference_education_levels.education_level").
+		Where("user_id = ?", userID).Order("education_levels.level").
+		Pluck("education_level", &preferences.AcceptedEducationLevels)
+	if res.Error != nil {
+		return UserPreferences{}, fmt.Errorf("retrieve accepted education levels: %w", res.Error)
+	}
+
+	var levels []EducationLevel
+	res = r.db.WithContext(ctx).Where("code IN ?", []string{preferences.EducationLevel, preferences.MinEducationLevel}).
+		Find(&levels)
+	if res.Error != nil {
+		return UserPreferences{}, fmt.Errorf("retrieve education levels: %w", res.Error)
+	}
+	for _, level := range levels {
+		if level.Code == preferences.EducationLevel {
+			preferences.EducationRank = level.Level
+		}
+		if level.Code == preferences.MinEducationLevel {
+			preferences.MinEducationRank = level.Level
+		}
+	}
+
+	return preferences, nil
 }
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGendersSuit(t *testing.T) {
+	woman := User{Gender: "Woman"}
+	man := User{Gender: "Man"}
+	nonBinary := User{Gender: "Non-binary"}
+
+	tests := []struct {
+		name      string
+		user      User
+		candidate User
+		userPrefs UserPreferences
+		canPrefs  UserPreferences
+		want      bool
+	}{
+		{
+			name:      "both want each other",
+			user:      woman,
+			candidate: man,
+			userPrefs: UserPreferences{Genders: []string{"Man"}},
+			canPrefs:  UserPreferences{Genders: []string{"Woman"}},
+			want:      true,
+		},
+		{
+			name:      "gender compared ignoring case",
+			user:      woman,
+			candidate: man,
+			userPrefs: UserPreferences{Genders: []string{"man"}},
+			canPrefs:  UserPreferences{Genders: []string{"WOMAN"}, ShowMeTo: []string{"woman"}},
+			want:      true,
+		},
+		{
+			name:      "user doesn't want the candidate's gender",
+			user:      woman,
+			candidate: nonBinary,
+			userPrefs: UserPreferences{Genders: []string{"Man"}},
+			canPrefs:  UserPreferences{Genders: []string{"Woman"}},
+		},
+		{
+			name:      "candidate doesn't want the user's gender",
+			user:      woman,
+			candidate: man,
+			userPrefs: UserPreferences{Genders: []string{"Man"}},
+			canPrefs:  UserPreferences{Genders: []string{"Man"}},
+		},
+		{
+			name:      "candidate with no preferred genders",
+			user:      woman,
+			candidate: man,
+			userPrefs: UserPreferences{Genders: []string{"Man"}},
+			canPrefs:  UserPreferences{},
+		},
+		{
+			name:      "candidate not shown to the user's gender",
+			user:      woman,
+			candidate: man,
+			userPrefs: UserPreferences{Genders: []string{"Man"}},
+			canPrefs:  UserPreferences{Genders: []string{"Woman"}, ShowMeTo: []string{"Non-binary"}},
+		},
+		{
+			name:      "user not shown to the candidate's gender",
+			user:      woman,
+			candidate: man,
+			userPrefs: UserPreferences{Genders: []string{"Man"}, ShowMeTo: []string{"Woman"}},
+			canPrefs:  UserPreferences{Genders: []string{"Woman"}},
+		},
+		{
+			name:      "shown to each other",
+			user:      nonBinary,
+			candidate: woman,
+			userPrefs: UserPreferences{Genders: []string{"Woman", "Non-binary"}, ShowMeTo: []string{"Woman", "Non-binary"}},
+			canPrefs:  UserPreferences{Genders: []string{"Non-binary"}, ShowMeTo: []string{"Non-binary"}},
+			want:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.user.GendersSuit(tt.candidate, tt.userPrefs, tt.canPrefs)
+			if got != tt.want {
+				t.Errorf("GendersSuit() = %v, want %v", got, tt.want)
+			}
+			// Whether a pair suits doesn't depend on who is looking.
+			if back := tt.candidate.GendersSuit(tt.user, tt.canPrefs, tt.userPrefs); back != got {
+				t.Errorf("GendersSuit() the other way = %v, want %v", back, got)
+			}
+
+			score, err := tt.user.RankCandidate(tt.candidate, tt.userPrefs, tt.canPrefs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (score != -1) != tt.want {
+				t.Errorf("RankCandidate() = %d, want a mismatch to be %v", score, !tt.want)
+			}
+		})
+	}
+}
+
+func TestIsDiscoverable(t *testing.T) {
+	dob := time.Date(1990, 5, 1, 0, 0, 0, 0, time.UTC)
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name string
+		user User
+		want bool
+	}{
+		{name: "discoverable", user: User{EmailVerified: true, DateOfBirth: &dob}, want: true},
+		{name: "suspension over", user: User{EmailVerified: true, DateOfBirth: &dob, SuspendedUntil: &past}, want: true},
+		{name: "unverified", user: User{DateOfBirth: &dob}},
+		{name: "banned", user: User{EmailVerified: true, DateOfBirth: &dob, Banned: true}},
+		{name: "suspended", user: User{EmailVerified: true, DateOfBirth: &dob, SuspendedUntil: &future}},
+		{name: "no date of birth", user: User{EmailVerified: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.user.IsDiscoverable(); got != tt.want {
+				t.Errorf("IsDiscoverable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,9 +3,11 @@ package repository
 import "time"
 
 type Session struct {
-	ID        int       `json:"id"`
-	UserID    int       `json:"userId"`
-	Token     string    `json:"token"`
+	ID     int    `json:"id"`
+	UserID int    `json:"userId"`
+	Token  string `json:"token"`
+	// Role is a snapshot of the user's role at the time of login.
+	Role      string    `json:"role"`
 	CreatedAt time.Time `json:"createdAt"`
 	ExpiresAt time.Time `json:"expiresAt"`
 }
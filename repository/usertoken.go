@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeLoginChallenge    = "login_challenge"
+)
+
+// UserToken is a single-use secret sent to a user, i.e. to verify their email address. Only a hash of the token is
+// stored.
+type UserToken struct {
+	ID        int
+	UserID    int
+	Purpose   string
+	TokenHash string
+	// Email is the address the token was sent to, where relevant.
+	Email     string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (r *Repository) CreateUserToken(ctx context.Context, token *UserToken) error {
+	res := r.db.WithContext(ctx).Create(token)
+	if res.Error != nil {
+		return fmt.Errorf("create user token: %w", res.Error)
+	}
+	return nil
+}
+
+// ConsumeUserToken marks an unused, unexpired token as used and returns it. Each token can only be consumed once, even
+// under concurrent requests.
+func (r *Repository) ConsumeUserToken(ctx context.Context, purpose string, tokenHash string) (UserToken, error) {
+	token := UserToken{}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		res := tx.Model(&UserToken{}).
+			Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", tokenHash, purpose, now).
+			Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("token_hash = ?", tokenHash).First(&token).Error
+	})
+	if err != nil {
+		return UserToken{}, fmt.Errorf("consume user token: %w", err)
+	}
+	return token, nil
+}
+
+// GetUserToken returns an unused, unexpired token without consuming it.
+func (r *Repository) GetUserToken(ctx context.Context, purpose string, tokenHash string) (UserToken, error) {
+	token := UserToken{}
+	res := r.db.WithContext(ctx).
+		Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", tokenHash, purpose, time.Now()).
+		First(&token)
+	if res.Error != nil {
+		return UserToken{}, fmt.Errorf("retrieve user token: %w", res.Error)
+	}
+	return token, nil
+}
+
+// InvalidateUserTokens marks every outstanding token of a purpose for a user as used, i.e. when a newer one is issued.
+func (r *Repository) InvalidateUserTokens(ctx context.Context, userID int, purpose string) error {
+	res := r.db.WithContext(ctx).Model(&UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return fmt.Errorf("invalidate user tokens: %w", res.Error)
+	}
+	return nil
+}